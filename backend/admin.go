@@ -0,0 +1,39 @@
+package main
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ══════════════════════════════════════════════
+//  ADMIN ACCESS
+// ══════════════════════════════════════════════
+
+// requireAdmin guards operator endpoints with the X-Admin-Token header.
+// Without ADMIN_API_TOKEN the routes stay open only in demo mode (no
+// database); with a database they fail closed with 503.
+func requireAdmin() gin.HandlerFunc {
+	token := os.Getenv("ADMIN_API_TOKEN")
+	if token == "" {
+		log.Println("⚠ ADMIN_API_TOKEN not set – admin endpoints are disabled unless running in demo mode")
+	}
+	return func(c *gin.Context) {
+		if token == "" {
+			if db != nil {
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "admin endpoints are disabled: ADMIN_API_TOKEN is not set"})
+				return
+			}
+			c.Next()
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Admin-Token")), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "admin token required"})
+			return
+		}
+		c.Next()
+	}
+}
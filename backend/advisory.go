@@ -0,0 +1,415 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ══════════════════════════════════════════════
+//  FIELD ADVISORY (Fertiliser & Irrigation)
+// ══════════════════════════════════════════════
+
+const hectaresPerAcre = 0.404686
+
+// Growth stages, in order. Fertiliser splits and crop water use follow them.
+var growthStages = []string{"sowing", "vegetative", "flowering", "maturity"}
+
+// stageSplits is the share of the seasonal N, P2O5 and K2O dose applied at
+// each stage: P up front, N in three equal splits, K half at sowing and half
+// at flowering.
+var stageSplits = map[string][3]float64{
+	"sowing":     {1.0 / 3, 1, 0.5},
+	"vegetative": {1.0 / 3, 0, 0},
+	"flowering":  {1.0 / 3, 0, 0.5},
+	"maturity":   {0, 0, 0},
+}
+
+// cropNeeds is a crop's recommended seasonal dose (kg/ha) and mid-season
+// crop coefficient (FAO-56 Kc).
+type cropNeeds struct {
+	N     float64 `db:"n_kg_ha"`
+	P2O5  float64 `db:"p2o5_kg_ha"`
+	K2O   float64 `db:"k2o_kg_ha"`
+	KcMid float64 `db:"kc_mid"`
+}
+
+// categoryNeeds backs crops without a crop_nutrients row.
+var categoryNeeds = map[string]cropNeeds{
+	"vegetable":       {N: 120, P2O5: 60, K2O: 60, KcMid: 1.05},
+	"leafy_vegetable": {N: 100, P2O5: 50, K2O: 50, KcMid: 1.0},
+	"root_tuber":      {N: 100, P2O5: 60, K2O: 80, KcMid: 1.05},
+	"bulb":            {N: 100, P2O5: 50, K2O: 50, KcMid: 1.0},
+	"fruit":           {N: 120, P2O5: 60, K2O: 120, KcMid: 0.9},
+	"grain":           {N: 120, P2O5: 60, K2O: 40, KcMid: 1.15},
+	"oilseed":         {N: 80, P2O5: 40, K2O: 40, KcMid: 1.05},
+	"cash_crop":       {N: 150, P2O5: 60, K2O: 80, KcMid: 1.1},
+	"spice":           {N: 60, P2O5: 40, K2O: 60, KcMid: 1.0},
+}
+
+// rootDepthMm is the effective rooting depth used for the soil water balance.
+var rootDepthMm = map[string]float64{
+	"vegetable":       500,
+	"leafy_vegetable": 300,
+	"root_tuber":      450,
+	"bulb":            400,
+	"fruit":           1000,
+	"grain":           900,
+	"oilseed":         900,
+	"cash_crop":       1000,
+	"spice":           500,
+}
+
+const (
+	// Loam water holding (volumetric %) for the water balance.
+	fieldCapacityPct = 30.0
+	wiltingPointPct  = 12.0
+	// depletionFraction of the available water can go before the crop is stressed (FAO-56 p).
+	depletionFraction = 0.5
+	// irrigationEfficiency is the share of pumped water that reaches the roots (surface irrigation).
+	irrigationEfficiency = 0.7
+	// effectiveRainFactor and minEffectiveRainMm discount runoff and interception.
+	effectiveRainFactor = 0.8
+	minEffectiveRainMm  = 2.0
+)
+
+// Soil test ratings (available kg/ha) used on Soil Health Cards.
+var nutrientRatings = map[string][2]float64{
+	"N": {280, 560},
+	"P": {10, 25},
+	"K": {110, 280},
+}
+
+// ratingFactor scales the recommended dose by soil test rating.
+var ratingFactor = map[string]float64{"low": 1.25, "medium": 1.0, "high": 0.75, "unknown": 1.0}
+
+// advisoryConfig holds input prices, read once from the environment.
+type advisoryConfig struct {
+	UreaPerKg       float64 // FERT_UREA_PER_KG (₹, subsidised 45 kg bag ÷ 45)
+	DAPPerKg        float64 // FERT_DAP_PER_KG
+	MOPPerKg        float64 // FERT_MOP_PER_KG
+	IrrigationPerKL float64 // IRRIGATION_COST_PER_KL: pumping cost per 1000 litres
+}
+
+var (
+	advisoryConfigOnce sync.Once
+	advisoryCfg        advisoryConfig
+)
+
+func loadAdvisoryConfig() advisoryConfig {
+	advisoryConfigOnce.Do(func() {
+		advisoryCfg = advisoryConfig{
+			UreaPerKg:       envFloat("FERT_UREA_PER_KG", 5.92),
+			DAPPerKg:        envFloat("FERT_DAP_PER_KG", 27),
+			MOPPerKg:        envFloat("FERT_MOP_PER_KG", 34),
+			IrrigationPerKL: envFloat("IRRIGATION_COST_PER_KL", 3),
+		}
+	})
+	return advisoryCfg
+}
+
+// fetchCropNeeds returns a crop's nutrient and water requirements, falling
+// back to its category's defaults.
+func fetchCropNeeds(crop Crop) cropNeeds {
+	if db != nil && isValidUUID(crop.ID) {
+		var n cropNeeds
+		err := db.Get(&n, "SELECT n_kg_ha, p2o5_kg_ha, k2o_kg_ha, kc_mid FROM crop_nutrients WHERE crop_id = $1", crop.ID)
+		if err == nil {
+			return n
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("⚠ DB fetch crop nutrients failed: %v", err)
+		}
+	}
+	if n, ok := categoryNeeds[crop.Category]; ok {
+		return n
+	}
+	return categoryNeeds["vegetable"]
+}
+
+// rateNutrient classes a soil test value as low, medium or high.
+func rateNutrient(nutrient string, v *float64) string {
+	if v == nil {
+		return "unknown"
+	}
+	bounds := nutrientRatings[nutrient]
+	switch {
+	case *v < bounds[0]:
+		return "low"
+	case *v > bounds[1]:
+		return "high"
+	default:
+		return "medium"
+	}
+}
+
+// soilStatus summarises moisture and any low nutrient for display.
+func soilStatus(s SoilHealth) string {
+	var issues []string
+	for _, n := range []struct {
+		key, name string
+		v         *float64
+	}{{"N", "Nitrogen", s.Nitrogen}, {"P", "Phosphorus", s.Phosphorus}, {"K", "Potassium", s.Potassium}} {
+		if rateNutrient(n.key, n.v) == "low" {
+			issues = append(issues, "Low "+n.name)
+		}
+	}
+	if s.MoisturePct < 20.0 {
+		issues = append(issues, "Low Moisture - Irrigate Soon")
+	}
+	if len(issues) == 0 {
+		return "Good"
+	}
+	return strings.Join(issues, ", ")
+}
+
+// ── Advisory ────────────────────────────────
+
+// buildFieldAdvisory turns soil readings, the crop's requirements and the
+// weather forecast into fertiliser doses and an irrigation schedule for
+// areaAcres at the given growth stage.
+func buildFieldAdvisory(crop Crop, stage string, areaAcres, lat float64, soil SoilHealth, forecast []DailyForecast) FieldAdvisory {
+	cfg := loadAdvisoryConfig()
+	needs := fetchCropNeeds(crop)
+	split := stageSplits[stage]
+
+	adv := FieldAdvisory{
+		CropName:      crop.Name,
+		GrowthStage:   stage,
+		AreaAcres:     areaAcres,
+		SoilSource:    soil.Source,
+		SoilEstimated: soil.Estimated,
+	}
+	if soil.Estimated {
+		adv.Notes = append(adv.Notes, "Soil nutrients are not from a test on your field; a soil test will make these doses more accurate.")
+	}
+
+	// ── Fertiliser: soil-test-adjusted dose due at this stage ──
+	type nutrientLine struct {
+		key      string
+		soil     *float64
+		required float64
+		share    float64
+	}
+	due := map[string]float64{}
+	for _, n := range []nutrientLine{
+		{"N", soil.Nitrogen, needs.N, split[0]},
+		{"P", soil.Phosphorus, needs.P2O5, split[1]},
+		{"K", soil.Potassium, needs.K2O, split[2]},
+	} {
+		rating := rateNutrient(n.key, n.soil)
+		if rating == "unknown" && n.share > 0 {
+			adv.Notes = append(adv.Notes, fmt.Sprintf("No soil reading for %s; the standard dose is used.", n.key))
+		}
+		dueKgHa := n.required * ratingFactor[rating] * n.share
+		due[n.key] = dueKgHa
+		adv.Nutrients = append(adv.Nutrients, NutrientStatus{
+			Nutrient:     n.key,
+			SoilKgHa:     n.soil,
+			Rating:       rating,
+			RequiredKgHa: n.required,
+			DueNowKgHa:   round2(dueKgHa),
+		})
+	}
+
+	// DAP (18-46-0) covers P and some N, MOP (0-0-60) covers K, urea (46-0-0) the rest of N.
+	dapKgHa := due["P"] / 0.46
+	mopKgHa := due["K"] / 0.60
+	ureaKgHa := math.Max(0, due["N"]-dapKgHa*0.18) / 0.46
+	for _, f := range []struct {
+		product string
+		kgHa    float64
+		bagKg   float64
+		perKg   float64
+	}{
+		{"Urea", ureaKgHa, 45, cfg.UreaPerKg},
+		{"DAP", dapKgHa, 50, cfg.DAPPerKg},
+		{"MOP", mopKgHa, 50, cfg.MOPPerKg},
+	} {
+		if f.kgHa <= 0 {
+			continue
+		}
+		perAcre := f.kgHa * hectaresPerAcre
+		total := perAcre * areaAcres
+		cost := total * f.perKg
+		adv.Fertilisers = append(adv.Fertilisers, FertiliserDose{
+			Product:   f.product,
+			KgPerAcre: round2(perAcre),
+			TotalKg:   round2(total),
+			Bags:      math.Ceil(total/f.bagKg*2) / 2, // half bags
+			Cost:      round2(cost),
+		})
+		adv.FertiliserCost += cost
+	}
+	if stage == "maturity" {
+		adv.Notes = append(adv.Notes, "No fertiliser is needed at maturity.")
+	}
+
+	// ── Irrigation: daily root-zone water balance over the forecast ──
+	adv.Irrigation = scheduleIrrigation(crop, stage, needs.KcMid, lat, soil, forecast)
+	litres := adv.Irrigation.TotalGrossMm * hectaresPerAcre * 10000 * areaAcres // 1 mm over 1 m² is 1 litre
+	adv.IrrigationCost = litres / 1000 * cfg.IrrigationPerKL
+	adv.Irrigation.TotalLitres = math.Round(litres)
+
+	adv.FertiliserCost = round2(adv.FertiliserCost)
+	adv.IrrigationCost = round2(adv.IrrigationCost)
+	adv.TotalCost = round2(adv.FertiliserCost + adv.IrrigationCost)
+	return adv
+}
+
+// scheduleIrrigation runs an FAO-56 style root-zone depletion balance: each
+// day the crop uses Kc × ET0, effective rain refills the root zone, and an
+// irrigation is scheduled when depletion passes the readily available water
+// unless the next day's rain will cover it.
+func scheduleIrrigation(crop Crop, stage string, kcMid, lat float64, soil SoilHealth, forecast []DailyForecast) IrrigationPlan {
+	plan := IrrigationPlan{Schedule: []IrrigationEvent{}}
+	if len(forecast) == 0 {
+		plan.Note = "No weather forecast is available; check soil moisture by hand before irrigating."
+		return plan
+	}
+
+	depth, ok := rootDepthMm[crop.Category]
+	if !ok {
+		depth = 500
+	}
+	taw := (fieldCapacityPct - wiltingPointPct) / 100 * depth
+	raw := depletionFraction * taw
+
+	kc := map[string]float64{
+		"sowing":     0.5,
+		"vegetative": (0.5 + kcMid) / 2,
+		"flowering":  kcMid,
+		"maturity":   0.75 * kcMid,
+	}[stage]
+	plan.Kc = round2(kc)
+
+	depletion := math.Min(taw, math.Max(0, (fieldCapacityPct-soil.MoisturePct)/100*depth))
+	effRain := func(mm float64) float64 {
+		if mm < minEffectiveRainMm {
+			return 0
+		}
+		return mm * effectiveRainFactor
+	}
+
+	for i, f := range forecast {
+		et0 := f.ET0Mm
+		if et0 <= 0 {
+			et0 = hargreavesET0(lat, f.Date, f.TempMaxC, f.TempMinC)
+		}
+		etc := kc * et0
+		rain := effRain(f.PrecipMm)
+		plan.TotalETcMm += etc
+		plan.EffectiveRainMm += rain
+		depletion = math.Min(taw, math.Max(0, depletion+etc-rain))
+
+		if depletion <= raw {
+			continue
+		}
+		if i+1 < len(forecast) && effRain(forecast[i+1].PrecipMm) >= depletion/2 {
+			continue // tomorrow's rain will refill most of it
+		}
+		net := depletion
+		plan.Schedule = append(plan.Schedule, IrrigationEvent{
+			Date:    f.Date,
+			NetMm:   round2(net),
+			GrossMm: round2(net / irrigationEfficiency),
+		})
+		plan.TotalGrossMm += net / irrigationEfficiency
+		depletion = 0
+	}
+
+	plan.TotalETcMm = round2(plan.TotalETcMm)
+	plan.EffectiveRainMm = round2(plan.EffectiveRainMm)
+	plan.TotalGrossMm = round2(plan.TotalGrossMm)
+	switch {
+	case len(plan.Schedule) == 0:
+		plan.Note = fmt.Sprintf("No irrigation needed over the next %d days; rain and soil moisture cover crop water use.", len(forecast))
+	case stage == "maturity":
+		plan.Note = "Keep irrigations light near harvest and stop a week before picking to avoid rots and cracking."
+	}
+	return plan
+}
+
+// hargreavesET0 estimates reference evapotranspiration (mm/day) from daily
+// temperature extremes when the forecast carries no ET0.
+func hargreavesET0(lat float64, date string, tmax, tmin float64) float64 {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil || tmax < tmin {
+		return 0
+	}
+	doy := float64(t.YearDay())
+	phi := lat * math.Pi / 180
+	dr := 1 + 0.033*math.Cos(2*math.Pi/365*doy)
+	decl := 0.409 * math.Sin(2*math.Pi/365*doy-1.39)
+	ws := math.Acos(math.Max(-1, math.Min(1, -math.Tan(phi)*math.Tan(decl))))
+	ra := 24 * 60 / math.Pi * 0.0820 * dr *
+		(ws*math.Sin(phi)*math.Sin(decl) + math.Cos(phi)*math.Cos(decl)*math.Sin(ws)) // MJ/m²/day
+	return math.Max(0, 0.0023*((tmax+tmin)/2+17.8)*math.Sqrt(tmax-tmin)*ra*0.408)
+}
+
+// ── Handlers ────────────────────────────────
+
+// parseAdvisoryParams reads ?growth_stage= and ?area_acres=, falling back to
+// the given defaults.
+func parseAdvisoryParams(c *gin.Context, defaultStage string, defaultAcres float64) (string, float64, error) {
+	stage := strings.ToLower(c.DefaultQuery("growth_stage", defaultStage))
+	if _, ok := stageSplits[stage]; !ok {
+		return "", 0, fmt.Errorf("growth_stage must be one of: %s", strings.Join(growthStages, ", "))
+	}
+	area, err := strconv.ParseFloat(c.DefaultQuery("area_acres", strconv.FormatFloat(defaultAcres, 'f', -1, 64)), 64)
+	if err != nil || area <= 0 || area > maxPlotAcres {
+		return "", 0, fmt.Errorf("area_acres must be a number above 0 and at most %d", maxPlotAcres)
+	}
+	return stage, area, nil
+}
+
+// handleFieldAdvisory returns fertiliser and irrigation advice for a
+// farmer's crop: ?farmer_id=&crop_id=&growth_stage=&area_acres=.
+func handleFieldAdvisory(c *gin.Context) {
+	farmerID, cropID := c.Query("farmer_id"), c.Query("crop_id")
+	if farmerID == "" || cropID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "farmer_id and crop_id are required"})
+		return
+	}
+	stage, area, err := parseAdvisoryParams(c, "vegetative", 1)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	farmer, err := fetchFarmer(farmerID)
+	if err != nil {
+		respondFarmerError(c, farmerID, err)
+		return
+	}
+	crop, err := fetchCrop(cropID)
+	if err != nil {
+		respondCropError(c, cropID, err)
+		return
+	}
+
+	var soil SoilHealth
+	var weather WeatherInfo
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		soil = fetchSoilHealth(farmer.ID, farmer.LocationLat, farmer.LocationLon)
+	}()
+	go func() {
+		defer wg.Done()
+		weather = fetchWeatherFromDB(farmer.LocationLat, farmer.LocationLon, crop.IdealTemp)
+	}()
+	wg.Wait()
+
+	c.JSON(http.StatusOK, buildFieldAdvisory(crop, stage, area, farmer.LocationLat, soil, weather.Forecast))
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// ══════════════════════════════════════════════
+//  SEVERE WEATHER ALERTS (Proactive Notifications)
+// ══════════════════════════════════════════════
+
+const (
+	alertMaxAttempts   = 3
+	alertActiveCropFor = 30 // days since the last recommendation for a crop to be watched
+)
+
+const alertColumns = `id, farmer_id, crop_id, crop_name, alert_type, to_char(alert_date, 'YYYY-MM-DD') AS alert_date,
+	message, action, channel, status, created_at, sent_at`
+
+// alertConfig holds the threat thresholds, read once from the environment.
+type alertConfig struct {
+	HeatMarginC float64 // ALERT_HEAT_MARGIN_C: max temp this far above the crop's field heat limit is a heatwave
+	FrostTempC  float64 // ALERT_FROST_TEMP_C: min temp at or below this is frost
+	HeavyRainMm float64 // ALERT_HEAVY_RAIN_MM: daily rain total that disrupts a harvest
+	HorizonDays int     // ALERT_HORIZON_DAYS: how far ahead to warn
+}
+
+var (
+	alertConfigOnce sync.Once
+	alertCfg        alertConfig
+)
+
+func envFloat(name string, def float64) float64 {
+	if s := os.Getenv(name); s != "" {
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return v
+		}
+		log.Printf("⚠ Invalid %s %q – using %.1f", name, s, def)
+	}
+	return def
+}
+
+func loadAlertConfig() alertConfig {
+	alertConfigOnce.Do(func() {
+		alertCfg = alertConfig{
+			HeatMarginC: envFloat("ALERT_HEAT_MARGIN_C", 5),
+			FrostTempC:  envFloat("ALERT_FROST_TEMP_C", 2),
+			HeavyRainMm: envFloat("ALERT_HEAVY_RAIN_MM", 20),
+			HorizonDays: int(envFloat("ALERT_HORIZON_DAYS", 3)),
+		}
+	})
+	return alertCfg
+}
+
+// weatherThreat is one detected hazard on a forecast day.
+type weatherThreat struct {
+	Type    string // "heavy_rain", "heatwave", "frost"
+	Day     DailyForecast
+	Message string
+}
+
+// detectThreats scans the first HorizonDays of a forecast. Heavy rain only
+// matters around a planned harvest day (±1 day); heat and frost always do.
+// A heatwave is a max temperature HeatMarginC above the crop's field heat
+// limit (the GDD upper cutoff in growth.go).
+func detectThreats(crop Crop, forecast []DailyForecast, harvestDate string, cfg alertConfig) []weatherThreat {
+	heatLimit := fetchCropPhenology(crop).UpperTempC
+	var planned time.Time
+	if harvestDate != "" {
+		planned, _ = time.Parse("2006-01-02", harvestDate)
+	}
+
+	var threats []weatherThreat
+	for i, f := range forecast {
+		if i >= cfg.HorizonDays {
+			break
+		}
+		day := f.Date
+		if t, err := time.Parse("2006-01-02", f.Date); err == nil {
+			day = t.Format("Mon 2 Jan")
+			if !planned.IsZero() && f.PrecipMm >= cfg.HeavyRainMm && absDays(t.Sub(planned)) <= 1 {
+				threats = append(threats, weatherThreat{Type: "heavy_rain", Day: f,
+					Message: fmt.Sprintf("Heavy rain (%.0f mm, %d%% chance) forecast on %s, around your planned %s harvest.",
+						f.PrecipMm, f.PrecipProbPct, day, crop.Name)})
+			}
+		}
+		if f.TempMaxC >= heatLimit+cfg.HeatMarginC {
+			threats = append(threats, weatherThreat{Type: "heatwave", Day: f,
+				Message: fmt.Sprintf("Heatwave on %s: up to %.0f°C, %.0f°C above what %s tolerates in the field.",
+					day, f.TempMaxC, f.TempMaxC-heatLimit, crop.Name)})
+		}
+		if f.TempMinC <= cfg.FrostTempC {
+			threats = append(threats, weatherThreat{Type: "frost", Day: f,
+				Message: fmt.Sprintf("Frost risk on %s: night temperature down to %.0f°C. %s in the field may be damaged.",
+					day, f.TempMinC, crop.Name)})
+		}
+	}
+	return threats
+}
+
+func absDays(d time.Duration) int {
+	days := int(d.Hours() / 24)
+	if days < 0 {
+		return -days
+	}
+	return days
+}
+
+// protectiveAction returns the preservation engine's action for a threat.
+func protectiveAction(t weatherThreat) PreservationAction {
+	action := weatherThreatActions[t.Type]
+	action.Rank = 1
+	return action
+}
+
+// ── Background Job ──────────────────────────
+
+func StartAlertJob(db *sqlx.DB) {
+	if db == nil {
+		log.Println("Alert job disabled: Database connection is nil.")
+		return
+	}
+
+	// Forecast cells refresh hourly; checking every 3h keeps alerts timely.
+	ticker := time.NewTicker(3 * time.Hour)
+	go func() {
+		runAlertJob(db)
+		for range ticker.C {
+			runAlertJob(db)
+		}
+	}()
+}
+
+// watchedCrop is a farmer's crop with its latest planned harvest day and
+// the farmer's notification preferences (defaults when none are stored).
+type watchedCrop struct {
+	FarmerID    string  `db:"farmer_id"`
+	Phone       string  `db:"phone"`
+	Lat         float64 `db:"location_lat"`
+	Lon         float64 `db:"location_lon"`
+	CropID      string  `db:"crop_id"`
+	HarvestDate string  `db:"harvest_date"`
+	Channel     string  `db:"channel"`
+	HeavyRain   bool    `db:"heavy_rain"`
+	Heatwave    bool    `db:"heatwave"`
+	Frost       bool    `db:"frost"`
+}
+
+func (w watchedCrop) wants(alertType string) bool {
+	switch alertType {
+	case "heavy_rain":
+		return w.HeavyRain
+	case "heatwave":
+		return w.Heatwave
+	case "frost":
+		return w.Frost
+	}
+	return false
+}
+
+func runAlertJob(db *sqlx.DB) {
+	log.Println("[alerts] Checking forecasts for registered farmers...")
+	cfg := loadAlertConfig()
+
+	// A farmer's crops are those they asked about recently; the latest
+	// recommendation carries the planned harvest day.
+	var watched []watchedCrop
+	err := db.Select(&watched, `
+		SELECT DISTINCT ON (r.farmer_id, r.crop_id)
+		       r.farmer_id, f.phone, f.location_lat, f.location_lon, r.crop_id,
+		       COALESCE(r.payload->>'harvest_date', '') AS harvest_date,
+		       COALESCE(p.channel, 'whatsapp') AS channel,
+		       COALESCE(p.heavy_rain, TRUE) AS heavy_rain,
+		       COALESCE(p.heatwave, TRUE) AS heatwave,
+		       COALESCE(p.frost, TRUE) AS frost
+		FROM recommendations r
+		JOIN farmers f ON f.id = r.farmer_id
+		LEFT JOIN notification_preferences p ON p.farmer_id = r.farmer_id
+		WHERE r.crop_id IS NOT NULL
+		  AND r.generated_at > NOW() - make_interval(days => $1)
+		  AND COALESCE(p.enabled, TRUE)
+		ORDER BY r.farmer_id, r.crop_id, r.generated_at DESC`, alertActiveCropFor)
+	if err != nil {
+		log.Printf("[alerts] Failed to load watched crops: %v", err)
+		return
+	}
+
+	crops := map[string]Crop{}
+	forecasts := map[string][]DailyForecast{}
+	sent, failed, undelivered := 0, 0, 0
+	for _, w := range watched {
+		crop, ok := crops[w.CropID]
+		if !ok {
+			if crop, err = fetchCrop(w.CropID); err != nil {
+				log.Printf("[alerts] Skipping crop %s: %v", w.CropID, err)
+				continue
+			}
+			crops[w.CropID] = crop
+		}
+		forecast, ok := forecasts[w.FarmerID]
+		if !ok {
+			forecast = fetchWeatherFromDB(w.Lat, w.Lon, crop.IdealTemp).Forecast
+			forecasts[w.FarmerID] = forecast
+		}
+
+		for _, t := range detectThreats(crop, forecast, w.HarvestDate, cfg) {
+			if !w.wants(t.Type) {
+				continue
+			}
+			switch err := raiseAlert(db, w, crop, t); {
+			case errors.Is(err, errAlertDuplicate):
+			case errors.Is(err, errAlertUndelivered):
+				undelivered++
+			case err != nil:
+				log.Printf("[alerts] %s alert for farmer %s failed: %v", t.Type, w.FarmerID, err)
+				failed++
+			default:
+				sent++
+			}
+		}
+	}
+	log.Printf("[alerts] Run complete: %d sent, %d failed, %d undelivered across %d farmer crops.", sent, failed, undelivered, len(watched))
+	if undelivered > 0 {
+		log.Printf("⚠ [alerts] %d alerts were only logged: set WHATSAPP_PHONE_NUMBER_ID and WHATSAPP_ACCESS_TOKEN to deliver them.", undelivered)
+	}
+}
+
+var (
+	errAlertDuplicate   = errors.New("alert already sent")
+	errAlertUndelivered = errors.New("alert logged: channel not configured")
+)
+
+// raiseAlert records the alert once per farmer, crop, threat and day, then
+// delivers it. Failed alerts are retried on later runs up to
+// alertMaxAttempts; sent ones never repeat. An alert only logged because
+// the farmer's channel is not configured is kept as undelivered and sent
+// once the channel works.
+func raiseAlert(db *sqlx.DB, w watchedCrop, crop Crop, t weatherThreat) error {
+	action := protectiveAction(t)
+	message := fmt.Sprintf("⚠ AgriChain alert: %s Recommended: %s (%s).", t.Message, action.ActionName, action.CostEstimate)
+	notifier, degraded := notifierFor(w.Channel)
+
+	var id string
+	err := db.QueryRow(`
+		INSERT INTO farmer_alerts (farmer_id, crop_id, crop_name, alert_type, alert_date, message, action, channel)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (farmer_id, crop_id, alert_type, alert_date) DO UPDATE
+			SET attempts = CASE WHEN farmer_alerts.status = 'undelivered' THEN 1 ELSE farmer_alerts.attempts + 1 END,
+			    message = EXCLUDED.message, action = EXCLUDED.action, channel = EXCLUDED.channel
+			WHERE (farmer_alerts.status = 'undelivered' AND NOT $10)
+			   OR (farmer_alerts.status NOT IN ('sent', 'undelivered') AND farmer_alerts.attempts < $9)
+		RETURNING id`,
+		w.FarmerID, w.CropID, crop.Name, t.Type, t.Day.Date, message, action.ActionName, w.Channel, alertMaxAttempts, degraded,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errAlertDuplicate
+	}
+	if err != nil {
+		return fmt.Errorf("record alert: %w", err)
+	}
+
+	sendErr := notifier.Send(w.Phone, message)
+	status := "sent"
+	switch {
+	case sendErr != nil:
+		status = "failed"
+	case degraded:
+		status = "undelivered"
+	}
+	if _, err := db.Exec(`
+		UPDATE farmer_alerts SET status = $2, sent_at = CASE WHEN $2 = 'sent' THEN NOW() END
+		WHERE id = $1`, id, status); err != nil {
+		log.Printf("[alerts] Failed to update alert %s: %v", id, err)
+	}
+	if sendErr == nil && degraded {
+		return errAlertUndelivered
+	}
+	return sendErr
+}
+
+// ── Handlers ────────────────────────────────
+
+// fetchNotificationPreferences returns stored preferences or the defaults.
+func fetchNotificationPreferences(farmerID string) (NotificationPreferences, error) {
+	prefs := NotificationPreferences{FarmerID: farmerID, Enabled: true, Channel: "whatsapp", HeavyRain: true, Heatwave: true, Frost: true}
+	err := db.Get(&prefs, `
+		SELECT farmer_id, enabled, channel, heavy_rain, heatwave, frost, updated_at
+		FROM notification_preferences WHERE farmer_id = $1`, farmerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return prefs, nil
+	}
+	return prefs, err
+}
+
+func handleGetNotificationPreferences(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	farmerID := c.Param("id")
+	if _, err := fetchFarmer(farmerID); err != nil {
+		respondFarmerError(c, farmerID, err)
+		return
+	}
+	prefs, err := fetchNotificationPreferences(farmerID)
+	if err != nil {
+		log.Printf("Error loading notification preferences for %s: %v", farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load notification preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+func handleUpdateNotificationPreferences(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	farmerID := c.Param("id")
+	if _, err := fetchFarmer(farmerID); err != nil {
+		respondFarmerError(c, farmerID, err)
+		return
+	}
+
+	var in NotificationPreferencesInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload format"})
+		return
+	}
+	if in.Channel != nil && !notificationChannels[*in.Channel] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "channel must be one of: whatsapp, log"})
+		return
+	}
+
+	prefs, err := fetchNotificationPreferences(farmerID)
+	if err != nil {
+		log.Printf("Error loading notification preferences for %s: %v", farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update notification preferences"})
+		return
+	}
+	if in.Enabled != nil {
+		prefs.Enabled = *in.Enabled
+	}
+	if in.Channel != nil {
+		prefs.Channel = *in.Channel
+	}
+	if in.HeavyRain != nil {
+		prefs.HeavyRain = *in.HeavyRain
+	}
+	if in.Heatwave != nil {
+		prefs.Heatwave = *in.Heatwave
+	}
+	if in.Frost != nil {
+		prefs.Frost = *in.Frost
+	}
+
+	err = db.Get(&prefs, `
+		INSERT INTO notification_preferences (farmer_id, enabled, channel, heavy_rain, heatwave, frost)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (farmer_id) DO UPDATE SET
+			enabled = EXCLUDED.enabled, channel = EXCLUDED.channel, heavy_rain = EXCLUDED.heavy_rain,
+			heatwave = EXCLUDED.heatwave, frost = EXCLUDED.frost, updated_at = NOW()
+		RETURNING farmer_id, enabled, channel, heavy_rain, heatwave, frost, updated_at`,
+		farmerID, prefs.Enabled, prefs.Channel, prefs.HeavyRain, prefs.Heatwave, prefs.Frost)
+	if err != nil {
+		log.Printf("Error saving notification preferences for %s: %v", farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update notification preferences"})
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+// handleListFarmerAlerts returns alerts raised for a farmer, newest first.
+func handleListFarmerAlerts(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	farmerID := c.Param("id")
+	if _, err := fetchFarmer(farmerID); err != nil {
+		respondFarmerError(c, farmerID, err)
+		return
+	}
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page := FarmerAlertPage{Items: []FarmerAlert{}, Limit: limit, Offset: offset}
+	if err := db.Get(&page.Total, "SELECT COUNT(*) FROM farmer_alerts WHERE farmer_id = $1", farmerID); err != nil {
+		log.Printf("Error counting alerts for %s: %v", farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list alerts"})
+		return
+	}
+	err = db.Select(&page.Items, "SELECT "+alertColumns+`
+		FROM farmer_alerts WHERE farmer_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`, farmerID, limit, offset)
+	if err != nil {
+		log.Printf("Error listing alerts for %s: %v", farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list alerts"})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
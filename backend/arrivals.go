@@ -0,0 +1,126 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ══════════════════════════════════════════════
+//  ARRIVAL VOLUMES (Measured Glut Detection)
+// ══════════════════════════════════════════════
+
+// A market is in surge (HIGH) when the last few days' arrivals exceed the
+// baseline by arrivalSurgeRatio, and short (LOW) below arrivalShortfallRatio.
+const (
+	arrivalSurgeRatio     = 1.3
+	arrivalShortfallRatio = 0.7
+	arrivalRecentDays     = 3  // window compared against the baseline
+	arrivalMinBaselineN   = 7  // observations needed for a trustworthy baseline
+	arrivalMaxStalenessD  = 7  // latest arrival older than this is ignored
+	arrivalSeasonWindowD  = 15 // ± days-of-year counted as "same season"
+)
+
+// ArrivalTrend is the measured arrival situation for one mandi and crop.
+type ArrivalTrend struct {
+	Trend          string  // HIGH, NORMAL, LOW
+	RecentTonnes   float64 // mean daily arrivals over arrivalRecentDays
+	BaselineTonnes float64 // seasonal (prior years) or trailing 4-week mean
+	Baseline       string  // "seasonal" or "trailing"
+}
+
+// Ratio is recent arrivals relative to the baseline.
+func (a ArrivalTrend) Ratio() float64 {
+	if a.BaselineTonnes <= 0 {
+		return 0
+	}
+	return a.RecentTonnes / a.BaselineTonnes
+}
+
+// parseArrivalDate parses data.gov.in's dd/mm/yyyy arrival_date.
+func parseArrivalDate(s string) (time.Time, error) {
+	return time.Parse("02/01/2006", strings.TrimSpace(s))
+}
+
+// storeArrival upserts one day's total arrivals for a mandi and crop, replacing
+// any earlier total for that day (see storeLivePrices).
+func storeArrival(db *sqlx.DB, mandiID int, cropName string, arrivalDate time.Time, tonnes float64) error {
+	_, err := db.Exec(`
+		INSERT INTO mandi_arrivals (mandi_id, crop_name, arrival_date, arrivals_tonnes)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (mandi_id, crop_name, arrival_date)
+		DO UPDATE SET arrivals_tonnes = EXCLUDED.arrivals_tonnes, recorded_at = NOW()`,
+		mandiID, cropName, arrivalDate.Format("2006-01-02"), tonnes)
+	return err
+}
+
+// fetchArrivalTrend compares recent measured arrivals with a rolling
+// seasonal baseline: the same ±15 days of year in earlier seasons, or the
+// trailing four weeks when no prior season is on record. ok is false when
+// there is no fresh, sufficiently long arrival series; callers then fall
+// back to the price-inferred heuristic.
+func fetchArrivalTrend(mandiName, cropName string) (ArrivalTrend, bool) {
+	if db == nil {
+		return ArrivalTrend{}, false
+	}
+
+	var s struct {
+		Latest      *time.Time `db:"latest"`
+		RecentAvg   float64    `db:"recent_avg"`
+		SeasonalAvg float64    `db:"seasonal_avg"`
+		SeasonalN   int        `db:"seasonal_n"`
+		TrailingAvg float64    `db:"trailing_avg"`
+		TrailingN   int        `db:"trailing_n"`
+	}
+	err := db.Get(&s, `
+		WITH series AS (
+			SELECT a.arrival_date AS d, a.arrivals_tonnes AS t
+			FROM mandi_arrivals a
+			JOIN mandis m ON m.id = a.mandi_id
+			WHERE m.name = $1 AND a.crop_name = $2
+		), latest AS (
+			SELECT MAX(d) AS d FROM series
+		), doy AS (
+			SELECT s.d, s.t,
+			       ABS(EXTRACT(DOY FROM s.d) - EXTRACT(DOY FROM l.d)) AS diff,
+			       l.d AS latest
+			FROM series s, latest l
+		)
+		SELECT
+			(SELECT d FROM latest) AS latest,
+			COALESCE(AVG(t) FILTER (WHERE d > latest - $3::int), 0) AS recent_avg,
+			COALESCE(AVG(t) FILTER (WHERE d < latest - 300 AND LEAST(diff, 365 - diff) <= $4::int), 0) AS seasonal_avg,
+			COUNT(*) FILTER (WHERE d < latest - 300 AND LEAST(diff, 365 - diff) <= $4::int) AS seasonal_n,
+			COALESCE(AVG(t) FILTER (WHERE d <= latest - $3::int AND d > latest - 31), 0) AS trailing_avg,
+			COUNT(*) FILTER (WHERE d <= latest - $3::int AND d > latest - 31) AS trailing_n
+		FROM doy`, mandiName, cropName, arrivalRecentDays, arrivalSeasonWindowD)
+	if err != nil {
+		log.Printf("⚠ DB fetch arrivals failed for %s/%s: %v", mandiName, cropName, err)
+		return ArrivalTrend{}, false
+	}
+	if s.Latest == nil || time.Since(*s.Latest) > arrivalMaxStalenessD*24*time.Hour {
+		return ArrivalTrend{}, false
+	}
+
+	a := ArrivalTrend{RecentTonnes: s.RecentAvg}
+	switch {
+	case s.SeasonalN >= arrivalMinBaselineN && s.SeasonalAvg > 0:
+		a.BaselineTonnes, a.Baseline = s.SeasonalAvg, "seasonal"
+	case s.TrailingN >= arrivalMinBaselineN && s.TrailingAvg > 0:
+		a.BaselineTonnes, a.Baseline = s.TrailingAvg, "trailing"
+	default:
+		return ArrivalTrend{}, false
+	}
+
+	switch ratio := a.Ratio(); {
+	case ratio >= arrivalSurgeRatio:
+		a.Trend = "HIGH"
+	case ratio <= arrivalShortfallRatio:
+		a.Trend = "LOW"
+	default:
+		a.Trend = "NORMAL"
+	}
+	return a, true
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// ══════════════════════════════════════════════
+//  HISTORICAL PRICE BACKFILL (`go run . backfill`)
+// ══════════════════════════════════════════════
+
+// backfillRequestGap spaces out data.gov.in calls; the public keys are
+// rate-limited and a year of 31 crops is ~11k requests.
+const backfillRequestGap = 250 * time.Millisecond
+
+// runBackfill loads one trading day at a time from the data.gov.in history
+// resource for every catalogue crop, so a fresh deployment has enough
+// daily_prices for forecasting and arrival baselines.
+func runBackfill(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	fromStr := fs.String("from", "", "first arrival date to load (YYYY-MM-DD, required)")
+	toStr := fs.String("to", time.Now().Format("2006-01-02"), "last arrival date to load (YYYY-MM-DD)")
+	cropFilter := fs.String("crop", "", "only backfill this crop (default: whole catalogue)")
+	resource := fs.String("resource", historyMandiResource, "data.gov.in resource ID to read from")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if db == nil {
+		return errors.New("DATABASE_URL is required for backfill")
+	}
+	apiKey := os.Getenv("DATA_GOV_API_KEY")
+	if apiKey == "" {
+		return errors.New("DATA_GOV_API_KEY is required for backfill")
+	}
+
+	from, err := time.Parse("2006-01-02", *fromStr)
+	if err != nil {
+		return errors.New("-from must be a date in YYYY-MM-DD format")
+	}
+	to, err := time.Parse("2006-01-02", *toStr)
+	if err != nil {
+		return errors.New("-to must be a date in YYYY-MM-DD format")
+	}
+	if to.Before(from) {
+		return errors.New("-to must not be before -from")
+	}
+
+	targets, err := loadIngestTargets(db, *cropFilter)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("no crop named %q in the catalogue", *cropFilter)
+	}
+
+	total := 0
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		dayTotal := 0
+		for _, t := range targets {
+			// One batch per crop and day so arrivals are totalled across aliases
+			var prices []LivePrice
+			for _, apiName := range t.APINames {
+				d := day
+				batch, err := fetchLiveMandiPrices(apiKey, *resource, apiName, &d)
+				if err != nil {
+					log.Printf("[backfill] %s %s (%s): %v", day.Format("2006-01-02"), t.CropName, apiName, err)
+				}
+				prices = append(prices, batch...)
+				time.Sleep(backfillRequestGap)
+			}
+			dayTotal += storeLivePrices(db, t.CropName, prices)
+		}
+		log.Printf("[backfill] %s: stored %d price records", day.Format("2006-01-02"), dayTotal)
+		total += dayTotal
+	}
+
+	fmt.Printf("Backfill complete: %d price records across %d crops from %s to %s\n",
+		total, len(targets), from.Format("2006-01-02"), to.Format("2006-01-02"))
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// ══════════════════════════════════════════════
+//  FORECAST BACKTESTING (`go run . backtest`)
+// ══════════════════════════════════════════════
+
+// backtestResult accumulates absolute percentage errors for one crop/model.
+type backtestResult struct {
+	sumAPE  float64
+	samples int
+}
+
+func (r backtestResult) mape() float64 {
+	if r.samples == 0 {
+		return math.NaN()
+	}
+	return r.sumAPE / float64(r.samples) * 100
+}
+
+// runBacktest replays daily_prices with a rolling origin: every model
+// forecasts from each prefix of a mandi's series and is scored on the price
+// observed `horizon` days later. MAPE is reported per crop and model.
+func runBacktest(args []string) error {
+	fs := flag.NewFlagSet("backtest", flag.ExitOnError)
+	cropFilter := fs.String("crop", "", "only backtest this crop (default: all crops with history)")
+	horizon := fs.Int("horizon", forecastHorizonDays, "forecast horizon in days to score")
+	minHistory := fs.Int("min-history", 14, "minimum observations before the first forecast")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if db == nil {
+		return errors.New("DATABASE_URL is required for backtesting")
+	}
+	if *horizon < 1 || *minHistory < 3 {
+		return errors.New("horizon must be >= 1 and min-history >= 3")
+	}
+
+	var rows []struct {
+		MandiID  int     `db:"mandi_id"`
+		CropName string  `db:"crop_name"`
+		Price    float64 `db:"price"`
+	}
+	err := db.Select(&rows, `
+		SELECT mandi_id, crop_name, AVG(price) AS price
+		FROM daily_prices
+		WHERE mandi_id IS NOT NULL AND ($1 = '' OR LOWER(crop_name) = LOWER($1))
+		GROUP BY crop_name, mandi_id, arrival_date
+		ORDER BY crop_name, mandi_id, arrival_date`, *cropFilter)
+	if err != nil {
+		return fmt.Errorf("load daily_prices: %w", err)
+	}
+
+	// crop -> mandi -> one modal price per trading day
+	series := map[string]map[int][]float64{}
+	for _, r := range rows {
+		if series[r.CropName] == nil {
+			series[r.CropName] = map[int][]float64{}
+		}
+		series[r.CropName][r.MandiID] = append(series[r.CropName][r.MandiID], r.Price)
+	}
+
+	crops := make([]string, 0, len(series))
+	for crop := range series {
+		crops = append(crops, crop)
+	}
+	sort.Strings(crops)
+
+	models := make([]string, 0, len(forecasters))
+	for name := range forecasters {
+		models = append(models, name)
+	}
+	sort.Strings(models)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "CROP\tMODEL\tMAPE %%\tSAMPLES\t\n")
+
+	var best []string
+	for _, crop := range crops {
+		bestModel, bestMAPE := "", math.Inf(1)
+		for _, name := range models {
+			res := backtestModel(forecasters[name], series[crop], *horizon, *minHistory)
+			mape := res.mape()
+			fmt.Fprintf(w, "%s\t%s\t%.2f\t%d\t\n", crop, name, mape, res.samples)
+			if res.samples > 0 && mape < bestMAPE {
+				bestModel, bestMAPE = name, mape
+			}
+		}
+		if bestModel != "" {
+			best = append(best, crop+"="+bestModel)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(best) == 0 {
+		fmt.Printf("\nNo series had more than %d observations; nothing to score.\n", *minHistory+*horizon-1)
+		return nil
+	}
+	fmt.Printf("\nSuggested configuration:\nFORECAST_MODELS=%s\n", strings.Join(best, ","))
+	return nil
+}
+
+func backtestModel(f Forecaster, mandis map[int][]float64, horizon, minHistory int) backtestResult {
+	var res backtestResult
+	for _, prices := range mandis {
+		for t := minHistory; t+horizon <= len(prices); t++ {
+			actual := prices[t+horizon-1]
+			if actual <= 0 {
+				continue
+			}
+			path, err := f.Forecast(prices[:t], horizon)
+			if err != nil {
+				continue
+			}
+			res.sumAPE += math.Abs(path[horizon-1]-actual) / actual
+			res.samples++
+		}
+	}
+	return res
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+)
+
+// ══════════════════════════════════════════════
+//  CLI SUBCOMMANDS (`go run . <command> [flags]`)
+// ══════════════════════════════════════════════
+
+// runCommand dispatches one-off maintenance commands. The HTTP server is
+// started only when no command is given.
+func runCommand(name string, args []string) error {
+	switch name {
+	case "backtest":
+		return runBacktest(args)
+	case "backfill":
+		return runBackfill(args)
+	case "import-mandis":
+		return runImportMandis(args)
+	case "import-soil":
+		return runImportSoil(args)
+	default:
+		return fmt.Errorf("unknown command %q (available: backtest, backfill, import-mandis, import-soil)", name)
+	}
+}
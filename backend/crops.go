@@ -0,0 +1,338 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ══════════════════════════════════════════════
+//  CROP CATALOGUE (schema.sql is the source of truth)
+// ══════════════════════════════════════════════
+
+var errCropNotFound = errors.New("crop not found")
+
+const cropColumns = "id, name, category, ideal_temp, baseline_spoilage_rate, shelf_life_days, humidity_min_pct, humidity_max_pct, unit_of_sale, created_at, updated_at"
+
+// cropCategories drives category-level rules (packaging, storage) so the
+// engines never need to special-case individual crop names.
+var cropCategories = map[string]bool{
+	"vegetable":       true,
+	"leafy_vegetable": true,
+	"root_tuber":      true,
+	"bulb":            true,
+	"fruit":           true,
+	"grain":           true,
+	"oilseed":         true,
+	"cash_crop":       true,
+	"spice":           true,
+}
+
+var saleUnits = map[string]bool{
+	"quintal": true,
+	"kg":      true,
+	"tonne":   true,
+	"dozen":   true,
+}
+
+func (in CropInput) validate() error {
+	if strings.TrimSpace(in.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !cropCategories[in.Category] {
+		return fmt.Errorf("category %q is not supported", in.Category)
+	}
+	if in.IdealTemp < -10 || in.IdealTemp > 50 {
+		return fmt.Errorf("ideal_temp must be between -10 and 50 °C")
+	}
+	if in.BaselineSpoilageRate < 0 || in.BaselineSpoilageRate > 100 {
+		return fmt.Errorf("baseline_spoilage_rate must be between 0 and 100")
+	}
+	if in.ShelfLifeDays <= 0 {
+		return fmt.Errorf("shelf_life_days must be positive")
+	}
+	if in.HumidityMinPct < 0 || in.HumidityMaxPct > 100 || in.HumidityMinPct > in.HumidityMaxPct {
+		return fmt.Errorf("humidity_min_pct and humidity_max_pct must satisfy 0 <= min <= max <= 100")
+	}
+	if !saleUnits[in.UnitOfSale] {
+		return fmt.Errorf("unit_of_sale %q is not supported", in.UnitOfSale)
+	}
+	return nil
+}
+
+// ── Crop ────────────────────────────────────
+
+// cropSeedHeader opens the crop seed INSERT in schema.sql.
+const cropSeedHeader = "INSERT INTO crops (id, name, category, ideal_temp, baseline_spoilage_rate, shelf_life_days, humidity_min_pct, humidity_max_pct, unit_of_sale) VALUES"
+
+// cropSeedRow matches one row of the crop seed.
+var cropSeedRow = regexp.MustCompile(`^\('([0-9a-f-]+)',\s*'([^']+)',\s*'(\w+)',\s*([\d.]+),\s*([\d.]+),\s*([\d.]+),\s*([\d.]+),\s*([\d.]+),\s*'(\w+)'\),?$`)
+
+var (
+	offlineCatalogue     map[string]Crop
+	offlineCatalogueOnce sync.Once
+)
+
+// offlineCrops is the crop seed read from schema.sql, keyed by ID, so demo
+// mode (no database) prices and scores each crop with its own profile.
+func offlineCrops() map[string]Crop {
+	offlineCatalogueOnce.Do(func() {
+		schema, err := os.ReadFile("schema.sql")
+		if err == nil {
+			offlineCatalogue, err = parseCropSeed(string(schema))
+		}
+		if err != nil {
+			log.Printf("⚠ Offline crop catalogue unavailable: %v", err)
+		}
+	})
+	return offlineCatalogue
+}
+
+// parseCropSeed reads the crop rows from the schema's seed INSERT. Every
+// row must parse, so a reformatted seed fails loudly rather than shrinking
+// the catalogue.
+func parseCropSeed(schema string) (map[string]Crop, error) {
+	_, seed, ok := strings.Cut(schema, cropSeedHeader)
+	if !ok {
+		return nil, fmt.Errorf("crop seed not found in schema.sql")
+	}
+	seed, _, _ = strings.Cut(seed, "ON CONFLICT")
+
+	crops := map[string]Crop{}
+	for _, line := range strings.Split(seed, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+		m := cropSeedRow.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("unrecognised crop seed row: %s", line)
+		}
+		var nums [5]float64
+		for k := range nums {
+			nums[k], _ = strconv.ParseFloat(m[4+k], 64) // the pattern admits only numbers
+		}
+		crops[m[1]] = Crop{
+			ID: m[1], Name: m[2], Category: m[3], IdealTemp: nums[0], BaselineSpoilageRate: nums[1],
+			ShelfLifeDays: nums[2], HumidityMinPct: nums[3], HumidityMaxPct: nums[4], UnitOfSale: m[9],
+		}
+	}
+	if len(crops) == 0 {
+		return nil, fmt.Errorf("crop seed in schema.sql has no rows")
+	}
+	return crops, nil
+}
+
+// fetchCrop loads a crop from the catalogue, or from the schema's seed when
+// no database is configured. An unknown ID yields errCropNotFound.
+func fetchCrop(id string) (Crop, error) {
+	if db == nil {
+		c, ok := offlineCrops()[id]
+		if !ok {
+			return Crop{}, errCropNotFound
+		}
+		return c, nil
+	}
+	if !isValidUUID(id) {
+		return Crop{}, errCropNotFound
+	}
+
+	var c Crop
+	err := db.Get(&c, "SELECT "+cropColumns+" FROM crops WHERE id = $1", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Crop{}, errCropNotFound
+	}
+	if err != nil {
+		return Crop{}, fmt.Errorf("fetch crop %s: %w", id, err)
+	}
+	return c, nil
+}
+
+// respondCropError maps a fetchCrop error onto an HTTP response.
+func respondCropError(c *gin.Context, id string, err error) {
+	if errors.Is(err, errCropNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("crop %s not found", id)})
+		return
+	}
+	log.Printf("⚠ DB fetch crop failed: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load crop"})
+}
+
+// updateCrop saves a catalogue edit. Prices, arrivals and crowd reports are
+// keyed by crop name, so a rename carries them over to the new name, and the
+// old name is kept as a commodity alias so ingestion still finds the crop
+// on data.gov.in.
+func updateCrop(id string, in CropInput) (Crop, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return Crop{}, fmt.Errorf("begin crop update: %w", err)
+	}
+	defer tx.Rollback()
+
+	var oldName string
+	err = tx.Get(&oldName, "SELECT name FROM crops WHERE id = $1 FOR UPDATE", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Crop{}, errCropNotFound
+	}
+	if err != nil {
+		return Crop{}, fmt.Errorf("lock crop %s: %w", id, err)
+	}
+
+	var crop Crop
+	err = tx.Get(&crop, `
+		UPDATE crops
+		SET name = $2, category = $3, ideal_temp = $4, baseline_spoilage_rate = $5,
+		    shelf_life_days = $6, humidity_min_pct = $7, humidity_max_pct = $8, unit_of_sale = $9,
+		    updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+cropColumns,
+		id, strings.TrimSpace(in.Name), in.Category, in.IdealTemp, in.BaselineSpoilageRate,
+		in.ShelfLifeDays, in.HumidityMinPct, in.HumidityMaxPct, in.UnitOfSale)
+	if err != nil {
+		return Crop{}, err // unique violations are reported to the caller as-is
+	}
+
+	if crop.Name != oldName {
+		for _, table := range []string{"daily_prices", "mandi_arrivals", "crowdsource_reports"} {
+			if _, err := tx.Exec("UPDATE "+table+" SET crop_name = $1 WHERE crop_name = $2", crop.Name, oldName); err != nil {
+				return Crop{}, fmt.Errorf("rename %s in %s: %w", oldName, table, err)
+			}
+		}
+		_, err = tx.Exec(`
+			INSERT INTO commodity_aliases (api_name, crop_id)
+			SELECT $1, $2
+			WHERE NOT EXISTS (SELECT 1 FROM commodity_aliases WHERE crop_id = $2)
+			ON CONFLICT (api_name) DO NOTHING`, oldName, id)
+		if err != nil {
+			return Crop{}, fmt.Errorf("keep %s as commodity alias: %w", oldName, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Crop{}, fmt.Errorf("commit crop update: %w", err)
+	}
+	if crop.Name != oldName {
+		log.Printf("✏️ Crop renamed: %s → %s (price history carried over)", oldName, crop.Name)
+	}
+	return crop, nil
+}
+
+// ── Handlers ────────────────────────────────
+
+func handleListCrops(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	crops := []Crop{}
+	var err error
+	if category := c.Query("category"); category != "" {
+		err = db.Select(&crops, "SELECT "+cropColumns+" FROM crops WHERE category = $1 ORDER BY name", category)
+	} else {
+		err = db.Select(&crops, "SELECT "+cropColumns+" FROM crops ORDER BY category, name")
+	}
+	if err != nil {
+		log.Printf("Error listing crops: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list crops"})
+		return
+	}
+	c.JSON(http.StatusOK, crops)
+}
+
+func handleGetCrop(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	id := c.Param("id")
+	crop, err := fetchCrop(id)
+	if err != nil {
+		respondCropError(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, crop)
+}
+
+func handleCreateCrop(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	var in CropInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload format"})
+		return
+	}
+	if err := in.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var crop Crop
+	err := db.Get(&crop, `
+		INSERT INTO crops (name, category, ideal_temp, baseline_spoilage_rate, shelf_life_days, humidity_min_pct, humidity_max_pct, unit_of_sale)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT ((LOWER(name))) DO NOTHING
+		RETURNING `+cropColumns,
+		strings.TrimSpace(in.Name), in.Category, in.IdealTemp, in.BaselineSpoilageRate,
+		in.ShelfLifeDays, in.HumidityMinPct, in.HumidityMaxPct, in.UnitOfSale)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("crop %q already exists", in.Name)})
+		return
+	}
+	if err != nil {
+		log.Printf("Error inserting crop: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create crop"})
+		return
+	}
+
+	log.Printf("✅ Crop added to catalogue: %s (%s)", crop.Name, crop.ID)
+	c.JSON(http.StatusCreated, crop)
+}
+
+func handleUpdateCrop(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	id := c.Param("id")
+	if !isValidUUID(id) {
+		respondCropError(c, id, errCropNotFound)
+		return
+	}
+
+	var in CropInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload format"})
+		return
+	}
+	if err := in.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	crop, err := updateCrop(id, in)
+	if errors.Is(err, errCropNotFound) {
+		respondCropError(c, id, err)
+		return
+	}
+	if isUniqueViolation(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("crop %q already exists", in.Name)})
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating crop %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update crop"})
+		return
+	}
+	c.JSON(http.StatusOK, crop)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseCropSeed(t *testing.T) {
+	schema, err := os.ReadFile("schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	crops, err := parseCropSeed(string(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(crops) < 30 {
+		t.Errorf("parsed %d crops from schema.sql, want the full seed", len(crops))
+	}
+
+	want := Crop{
+		ID: "c3d4e5f6-a7b8-9012-cdef-123456789012", Name: "Tomato", Category: "vegetable", IdealTemp: 25,
+		BaselineSpoilageRate: 2.5, ShelfLifeDays: 7, HumidityMinPct: 85, HumidityMaxPct: 95, UnitOfSale: "quintal",
+	}
+	if got := crops[want.ID]; got != want {
+		t.Errorf("tomato:\ngot  %+v\nwant %+v", got, want)
+	}
+	for id, c := range crops {
+		if !cropCategories[c.Category] || !saleUnits[c.UnitOfSale] {
+			t.Errorf("%s (%s): category %q, unit %q not supported", c.Name, id, c.Category, c.UnitOfSale)
+		}
+	}
+}
+
+func TestParseCropSeedRejectsUnknownRows(t *testing.T) {
+	schema := cropSeedHeader + "\n" +
+		"    -- Vegetables\n" +
+		"    ('c3d4e5f6-a7b8-9012-cdef-123456789012', 'Tomato', 'vegetable', 25.0, 2.5, 7, 85, 95, 'quintal'),\n" +
+		"    ('d4e5f6a7-b890-12cd-ef12-345678901234', 'Onion', 'bulb', 20.0, 1.0, 120, 65, 70)\n" +
+		"ON CONFLICT (id) DO NOTHING;"
+	if _, err := parseCropSeed(schema); err == nil || !strings.Contains(err.Error(), "Onion") {
+		t.Errorf("row without a unit: err %v, want it reported", err)
+	}
+	if _, err := parseCropSeed("CREATE TABLE crops ();"); err == nil {
+		t.Error("schema without a seed parsed")
+	}
+}
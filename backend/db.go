@@ -1,11 +1,16 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"strconv"
 
+	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 func InitDB() {
@@ -32,3 +37,33 @@ func InitDB() {
 	db = conn // assigning to the package-level db in main.go
 	log.Println("PostgreSQL connected successfully.")
 }
+
+// requireDB aborts the request with 503 when the server is running without
+// PostgreSQL. Used by endpoints that cannot fall back to demo data.
+func requireDB(c *gin.Context) bool {
+	if db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "database is not configured"})
+		return false
+	}
+	return true
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique constraint
+// violation (SQLSTATE 23505).
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// parsePagination reads ?limit= (1-100, default 20) and ?offset= (default 0).
+func parsePagination(c *gin.Context) (int, int, error) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		return 0, 0, fmt.Errorf("limit must be an integer between 1 and 100")
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+	}
+	return limit, offset, nil
+}
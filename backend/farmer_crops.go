@@ -0,0 +1,152 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ══════════════════════════════════════════════
+//  FARMER CROP RECORDS (Harvest Quantity)
+// ══════════════════════════════════════════════
+
+var errFarmerCropNotFound = errors.New("farmer crop not found")
+
+// kgPerUnit converts harvest quantities to kilograms. Count-based sale
+// units (dozen) cannot be weighed and are not accepted as quantities.
+var kgPerUnit = map[string]float64{
+	"kg":      1,
+	"quintal": 100,
+	"tonne":   1000,
+}
+
+const farmerCropColumns = "fc.farmer_id, fc.crop_id, c.name AS crop_name, fc.quantity, fc.unit, fc.created_at, fc.updated_at"
+
+// maxQuantity bounds a harvest quantity in its own unit.
+const maxQuantity = 1e6
+
+// quantityToKg validates a quantity and unit and returns kilograms.
+func quantityToKg(quantity float64, unit string) (float64, error) {
+	perUnit, ok := kgPerUnit[unit]
+	if !ok {
+		return 0, fmt.Errorf("unit must be one of: kg, quintal, tonne")
+	}
+	if quantity <= 0 {
+		return 0, fmt.Errorf("quantity must be greater than 0")
+	}
+	if quantity > maxQuantity {
+		return 0, fmt.Errorf("quantity must be at most 1,000,000 %s", unit)
+	}
+	return quantity * perUnit, nil
+}
+
+// fetchFarmerCrop returns the farmer's stored harvest record for a crop.
+func fetchFarmerCrop(farmerID, cropID string) (FarmerCrop, error) {
+	if db == nil || !isValidUUID(farmerID) || !isValidUUID(cropID) {
+		return FarmerCrop{}, errFarmerCropNotFound
+	}
+	var fc FarmerCrop
+	err := db.Get(&fc, "SELECT "+farmerCropColumns+`
+		FROM farmer_crops fc JOIN crops c ON c.id = fc.crop_id
+		WHERE fc.farmer_id = $1 AND fc.crop_id = $2`, farmerID, cropID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return FarmerCrop{}, errFarmerCropNotFound
+	}
+	return fc, err
+}
+
+// ── Handlers ────────────────────────────────
+
+func handleListFarmerCrops(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	farmerID := c.Param("id")
+	if _, err := fetchFarmer(farmerID); err != nil {
+		respondFarmerError(c, farmerID, err)
+		return
+	}
+
+	crops := []FarmerCrop{}
+	err := db.Select(&crops, "SELECT "+farmerCropColumns+`
+		FROM farmer_crops fc JOIN crops c ON c.id = fc.crop_id
+		WHERE fc.farmer_id = $1
+		ORDER BY c.name`, farmerID)
+	if err != nil {
+		log.Printf("Error listing crops for farmer %s: %v", farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list farmer crops"})
+		return
+	}
+	c.JSON(http.StatusOK, crops)
+}
+
+// handleUpsertFarmerCrop records how much of a crop the farmer has to sell.
+func handleUpsertFarmerCrop(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	farmerID, cropID := c.Param("id"), c.Param("crop_id")
+	if _, err := fetchFarmer(farmerID); err != nil {
+		respondFarmerError(c, farmerID, err)
+		return
+	}
+	if _, err := fetchCrop(cropID); err != nil {
+		respondCropError(c, cropID, err)
+		return
+	}
+
+	var in FarmerCropInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload format"})
+		return
+	}
+	if _, err := quantityToKg(in.Quantity, in.Unit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO farmer_crops (farmer_id, crop_id, quantity, unit)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (farmer_id, crop_id) DO UPDATE SET
+			quantity = EXCLUDED.quantity, unit = EXCLUDED.unit, updated_at = NOW()`,
+		farmerID, cropID, in.Quantity, in.Unit)
+	if err != nil {
+		log.Printf("Error saving crop %s for farmer %s: %v", cropID, farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save farmer crop"})
+		return
+	}
+	fc, err := fetchFarmerCrop(farmerID, cropID)
+	if err != nil {
+		log.Printf("Error reloading crop %s for farmer %s: %v", cropID, farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save farmer crop"})
+		return
+	}
+	c.JSON(http.StatusOK, fc)
+}
+
+func handleDeleteFarmerCrop(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	farmerID, cropID := c.Param("id"), c.Param("crop_id")
+	if !isValidUUID(farmerID) || !isValidUUID(cropID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "farmer crop not found"})
+		return
+	}
+	res, err := db.Exec("DELETE FROM farmer_crops WHERE farmer_id = $1 AND crop_id = $2", farmerID, cropID)
+	if err != nil {
+		log.Printf("Error deleting crop %s for farmer %s: %v", cropID, farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete farmer crop"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "farmer crop not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
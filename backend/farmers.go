@@ -0,0 +1,223 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ══════════════════════════════════════════════
+//  FARMER REGISTRY (Profile CRUD)
+// ══════════════════════════════════════════════
+
+var errFarmerNotFound = errors.New("farmer not found")
+
+var (
+	uuidPattern  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	phonePattern = regexp.MustCompile(`^\+[1-9][0-9]{9,14}$`)
+)
+
+const farmerColumns = "id, location_lat, location_lon, phone, created_at, updated_at"
+
+func isValidUUID(s string) bool {
+	return uuidPattern.MatchString(s)
+}
+
+// normalizePhone strips formatting characters and returns an E.164 number.
+// Bare 10-digit Indian mobile numbers are prefixed with +91.
+func normalizePhone(raw string) (string, error) {
+	phone := strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(strings.TrimSpace(raw))
+	if len(phone) == 10 && phone[0] >= '6' && phone[0] <= '9' {
+		phone = "+91" + phone
+	}
+	if !phonePattern.MatchString(phone) {
+		return "", fmt.Errorf("phone must be an E.164 number such as +919876543210")
+	}
+	return phone, nil
+}
+
+func validateLocation(lat, lon float64) error {
+	if lat < -90 || lat > 90 {
+		return fmt.Errorf("location_lat must be between -90 and 90")
+	}
+	if lon < -180 || lon > 180 {
+		return fmt.Errorf("location_lon must be between -180 and 180")
+	}
+	return nil
+}
+
+// validate checks the payload and returns the normalised phone number.
+func (in FarmerInput) validate() (string, error) {
+	phone, err := normalizePhone(in.Phone)
+	if err != nil {
+		return "", err
+	}
+	if in.LocationLat == nil || in.LocationLon == nil {
+		return "", fmt.Errorf("location_lat and location_lon are required")
+	}
+	if err := validateLocation(*in.LocationLat, *in.LocationLon); err != nil {
+		return "", err
+	}
+	return phone, nil
+}
+
+// ── Farmer ──────────────────────────────────
+
+// fetchFarmer loads a farmer profile. Without a database the demo farmer is
+// returned so the app keeps working offline; with a database an unknown ID
+// yields errFarmerNotFound.
+func fetchFarmer(id string) (Farmer, error) {
+	if db == nil {
+		return Farmer{
+			ID:          id,
+			LocationLat: 28.6139,
+			LocationLon: 77.2090,
+			Phone:       "+919876543210",
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}, nil
+	}
+	if !isValidUUID(id) {
+		return Farmer{}, errFarmerNotFound
+	}
+
+	var f Farmer
+	err := db.Get(&f, "SELECT "+farmerColumns+" FROM farmers WHERE id = $1", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Farmer{}, errFarmerNotFound
+	}
+	if err != nil {
+		return Farmer{}, fmt.Errorf("fetch farmer %s: %w", id, err)
+	}
+	return f, nil
+}
+
+// respondFarmerError maps a fetchFarmer error onto an HTTP response.
+func respondFarmerError(c *gin.Context, id string, err error) {
+	if errors.Is(err, errFarmerNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("farmer %s not found", id)})
+		return
+	}
+	log.Printf("⚠ DB fetch farmer failed: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load farmer"})
+}
+
+// ── Handlers ────────────────────────────────
+
+func handleCreateFarmer(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	var in FarmerInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload format"})
+		return
+	}
+	phone, err := in.validate()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var f Farmer
+	err = db.Get(&f, `
+		INSERT INTO farmers (location_lat, location_lon, phone)
+		VALUES ($1, $2, $3)
+		RETURNING `+farmerColumns,
+		*in.LocationLat, *in.LocationLon, phone)
+	if err != nil {
+		log.Printf("Error inserting farmer: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create farmer"})
+		return
+	}
+
+	log.Printf("✅ Farmer registered: %s (%s)", f.ID, f.Phone)
+	c.JSON(http.StatusCreated, f)
+}
+
+func handleGetFarmer(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	id := c.Param("id")
+	f, err := fetchFarmer(id)
+	if err != nil {
+		respondFarmerError(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, f)
+}
+
+func handleUpdateFarmer(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	id := c.Param("id")
+	if !isValidUUID(id) {
+		respondFarmerError(c, id, errFarmerNotFound)
+		return
+	}
+
+	var in FarmerInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload format"})
+		return
+	}
+	phone, err := in.validate()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var f Farmer
+	err = db.Get(&f, `
+		UPDATE farmers
+		SET location_lat = $2, location_lon = $3, phone = $4, updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+farmerColumns,
+		id, *in.LocationLat, *in.LocationLon, phone)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondFarmerError(c, id, errFarmerNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating farmer %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update farmer"})
+		return
+	}
+	c.JSON(http.StatusOK, f)
+}
+
+func handleDeleteFarmer(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	id := c.Param("id")
+	if !isValidUUID(id) {
+		respondFarmerError(c, id, errFarmerNotFound)
+		return
+	}
+
+	res, err := db.Exec("DELETE FROM farmers WHERE id = $1", id)
+	if err != nil {
+		log.Printf("Error deleting farmer %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete farmer"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondFarmerError(c, id, errFarmerNotFound)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
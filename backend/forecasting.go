@@ -0,0 +1,321 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ══════════════════════════════════════════════
+//  PRICE FORECASTING MODELS
+// ══════════════════════════════════════════════
+
+// forecastHorizonDays is the horizon behind MandiPrice.PriceTrendPct.
+const forecastHorizonDays = 7
+
+var errInsufficientHistory = errors.New("not enough price history to forecast")
+
+// Forecaster projects a chronological daily price series forward. Forecast
+// returns one point forecast per day for days 1..horizon.
+type Forecaster interface {
+	Name() string
+	Forecast(prices []float64, horizon int) ([]float64, error)
+}
+
+// ── Linear Regression ───────────────────────
+
+// LinearRegressionForecaster fits a least-squares line over the last Window
+// prices and extends its slope from the latest observed price.
+type LinearRegressionForecaster struct {
+	Window int
+}
+
+func (f LinearRegressionForecaster) Name() string { return "linear" }
+
+func (f LinearRegressionForecaster) Forecast(prices []float64, horizon int) ([]float64, error) {
+	if len(prices) < 3 {
+		return nil, errInsufficientHistory // Need at least 3 points for a meaningful trend
+	}
+	if len(prices) > f.Window {
+		prices = prices[len(prices)-f.Window:]
+	}
+
+	n := float64(len(prices))
+	sumX, sumY, sumXY, sumX2 := 0.0, 0.0, 0.0, 0.0
+	for i, y := range prices {
+		x := float64(i)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumX2 += x * x
+	}
+
+	slope := 0.0
+	if denom := n*sumX2 - sumX*sumX; denom != 0 {
+		slope = (n*sumXY - sumX*sumY) / denom
+	}
+
+	current := prices[len(prices)-1]
+	path := make([]float64, horizon)
+	for h := range path {
+		path[h] = current + slope*float64(h+1)
+	}
+	return path, nil
+}
+
+// ── Moving Average ──────────────────────────
+
+// MovingAverageForecaster is the naive baseline: a flat projection of the
+// mean of the last Window prices.
+type MovingAverageForecaster struct {
+	Window int
+}
+
+func (f MovingAverageForecaster) Name() string { return "moving_average" }
+
+func (f MovingAverageForecaster) Forecast(prices []float64, horizon int) ([]float64, error) {
+	if len(prices) == 0 {
+		return nil, errInsufficientHistory
+	}
+	if len(prices) > f.Window {
+		prices = prices[len(prices)-f.Window:]
+	}
+
+	sum := 0.0
+	for _, p := range prices {
+		sum += p
+	}
+	mean := sum / float64(len(prices))
+
+	path := make([]float64, horizon)
+	for h := range path {
+		path[h] = mean
+	}
+	return path, nil
+}
+
+// ── Holt-Winters ────────────────────────────
+
+// HoltWintersForecaster is additive triple exponential smoothing with a
+// weekly season. With fewer than two full seasons of history it degrades to
+// Holt's linear (double) smoothing.
+type HoltWintersForecaster struct {
+	Alpha, Beta, Gamma float64
+	Season             int
+}
+
+func (f HoltWintersForecaster) Name() string { return "holt_winters" }
+
+func (f HoltWintersForecaster) Forecast(prices []float64, horizon int) ([]float64, error) {
+	if len(prices) < 3 {
+		return nil, errInsufficientHistory
+	}
+	if len(prices) < 2*f.Season {
+		return f.holt(prices, horizon), nil
+	}
+
+	m := f.Season
+
+	// Initial trend from the first two seasons' means, seasonal indices from
+	// the first season's deviations around that trend line, and the level at
+	// the end of the first season (the mean sits mid-season).
+	mean1, mean2 := 0.0, 0.0
+	for i := 0; i < m; i++ {
+		mean1 += prices[i]
+		mean2 += prices[m+i]
+	}
+	mean1 /= float64(m)
+	mean2 /= float64(m)
+
+	trend := (mean2 - mean1) / float64(m)
+	mid := float64(m-1) / 2
+	level := mean1 + trend*mid
+	seasonal := make([]float64, m)
+	for i := 0; i < m; i++ {
+		seasonal[i] = prices[i] - (mean1 + trend*(float64(i)-mid))
+	}
+
+	for t := m; t < len(prices); t++ {
+		s := seasonal[t%m]
+		prevLevel := level
+		level = f.Alpha*(prices[t]-s) + (1-f.Alpha)*(level+trend)
+		trend = f.Beta*(level-prevLevel) + (1-f.Beta)*trend
+		seasonal[t%m] = f.Gamma*(prices[t]-level) + (1-f.Gamma)*s
+	}
+
+	path := make([]float64, horizon)
+	for h := range path {
+		path[h] = level + trend*float64(h+1) + seasonal[(len(prices)+h)%m]
+	}
+	return path, nil
+}
+
+func (f HoltWintersForecaster) holt(prices []float64, horizon int) []float64 {
+	// Seed the trend with the average step so one noisy day doesn't dominate.
+	level := prices[0]
+	trend := (prices[len(prices)-1] - prices[0]) / float64(len(prices)-1)
+	for _, p := range prices[1:] {
+		prevLevel := level
+		level = f.Alpha*p + (1-f.Alpha)*(level+trend)
+		trend = f.Beta*(level-prevLevel) + (1-f.Beta)*trend
+	}
+
+	path := make([]float64, horizon)
+	for h := range path {
+		path[h] = level + trend*float64(h+1)
+	}
+	return path
+}
+
+// ── Model Registry ──────────────────────────
+
+// forecasters lists every model available to FORECAST_MODELS and the backtest command.
+var forecasters = map[string]Forecaster{
+	"linear":         LinearRegressionForecaster{Window: 15},
+	"moving_average": MovingAverageForecaster{Window: 7},
+	"holt_winters":   HoltWintersForecaster{Alpha: 0.4, Beta: 0.1, Gamma: 0.3, Season: 7},
+}
+
+var (
+	forecastConfigOnce sync.Once
+	defaultForecaster  Forecaster
+	cropForecasters    map[string]Forecaster
+)
+
+// loadForecastConfig reads FORECAST_DEFAULT_MODEL (default "linear") and
+// FORECAST_MODELS, a comma-separated list of Crop=model pairs, e.g.
+// "Tomato=holt_winters,Wheat=moving_average". Crop names are case-insensitive.
+func loadForecastConfig() {
+	defaultForecaster = forecasters["linear"]
+	if name := os.Getenv("FORECAST_DEFAULT_MODEL"); name != "" {
+		if f, ok := forecasters[name]; ok {
+			defaultForecaster = f
+		} else {
+			log.Printf("⚠ Unknown FORECAST_DEFAULT_MODEL %q – using linear", name)
+		}
+	}
+
+	cropForecasters = map[string]Forecaster{}
+	for _, pair := range strings.Split(os.Getenv("FORECAST_MODELS"), ",") {
+		crop, name, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		f, known := forecasters[strings.TrimSpace(name)]
+		if !known {
+			log.Printf("⚠ Unknown forecast model %q for %s – using default", name, crop)
+			continue
+		}
+		cropForecasters[strings.ToLower(strings.TrimSpace(crop))] = f
+	}
+}
+
+// forecasterLabels names each model in farmer-facing text.
+var forecasterLabels = map[string]string{
+	"linear":         "linear regression",
+	"moving_average": "moving average",
+	"holt_winters":   "Holt-Winters",
+}
+
+// forecasterLabel returns the display name of a crop's configured model.
+func forecasterLabel(cropName string) string {
+	name := forecasterForCrop(cropName).Name()
+	if label, ok := forecasterLabels[name]; ok {
+		return label
+	}
+	return name
+}
+
+// forecasterForCrop returns the configured model for a crop.
+func forecasterForCrop(cropName string) Forecaster {
+	forecastConfigOnce.Do(loadForecastConfig)
+	if f, ok := cropForecasters[strings.ToLower(cropName)]; ok {
+		return f
+	}
+	return defaultForecaster
+}
+
+// Prediction intervals are ±z·σ·√h where σ is the standard deviation of the
+// model's one-step-ahead residuals on the market's own history.
+const (
+	predictionIntervalZ = 1.645 // 90% two-sided
+	maxForecastDays     = 30
+	residualOrigins     = 30   // most recent one-step forecasts used to estimate σ
+	fallbackBandPct     = 0.10 // day-1 band when history is too short to estimate σ
+)
+
+// forecastPrices projects a market's price path for `horizon` days with
+// prediction intervals, and returns the percentage change expected over
+// forecastHorizonDays (which drives PriceTrendPct).
+func forecastPrices(cropName string, prices []float64, horizon int) (float64, []ForecastPoint) {
+	if len(prices) == 0 {
+		return 0, nil
+	}
+	current := prices[len(prices)-1]
+
+	steps := horizon
+	if steps < forecastHorizonDays {
+		steps = forecastHorizonDays
+	}
+
+	f := forecasterForCrop(cropName)
+	path, err := f.Forecast(prices, steps)
+	if err != nil {
+		// Too little history: hold the latest price flat.
+		path = make([]float64, steps)
+		for h := range path {
+			path[h] = current
+		}
+	}
+
+	sigma := residualSigma(f, prices)
+	if sigma <= 0 {
+		sigma = current * fallbackBandPct / predictionIntervalZ
+	}
+
+	today := todayIST() // the same calendar as storage, growth and the optimiser
+	points := make([]ForecastPoint, horizon)
+	for h := range points {
+		margin := predictionIntervalZ * sigma * math.Sqrt(float64(h+1))
+		points[h] = ForecastPoint{
+			Day:   h + 1,
+			Date:  today.AddDate(0, 0, h+1).Format("2006-01-02"),
+			Price: math.Round(path[h]*100) / 100,
+			Lower: math.Round(math.Max(0, path[h]-margin)*100) / 100,
+			Upper: math.Round((path[h]+margin)*100) / 100,
+		}
+	}
+
+	trendPct := 0.0
+	if current > 0 {
+		trendPct = (path[forecastHorizonDays-1] - current) / current * 100.0
+	}
+	return math.Round(trendPct*100) / 100, points
+}
+
+// residualSigma replays one-step-ahead forecasts over the recent history and
+// returns the RMS error, or 0 when fewer than three residuals are available.
+func residualSigma(f Forecaster, prices []float64) float64 {
+	start := len(prices) - residualOrigins
+	if start < 3 {
+		start = 3
+	}
+
+	sumSq, n := 0.0, 0
+	for t := start; t < len(prices); t++ {
+		path, err := f.Forecast(prices[:t], 1)
+		if err != nil {
+			continue
+		}
+		r := prices[t] - path[0]
+		sumSq += r * r
+		n++
+	}
+	if n < 3 {
+		return 0
+	}
+	return math.Sqrt(sumSq / float64(n))
+}
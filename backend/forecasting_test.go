@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// series builds n daily prices from f(day).
+func series(n int, f func(t int) float64) []float64 {
+	prices := make([]float64, n)
+	for t := range prices {
+		prices[t] = f(t)
+	}
+	return prices
+}
+
+func flat(t int) float64     { return 2000 }
+func trending(t int) float64 { return 2000 + 25*float64(t) }
+
+// weekly adds a Monday spike and a weekend dip to a rising price.
+func weekly(t int) float64 {
+	return trending(t) + []float64{120, 40, 0, -20, -30, -60, -50}[t%7]
+}
+
+func TestForecasters(t *testing.T) {
+	hw := forecasters["holt_winters"].(HoltWintersForecaster)
+	tests := []struct {
+		name   string
+		f      Forecaster
+		prices []float64
+		want   func(h int) float64 // price on forecast day h (1-based)
+		tol    float64
+	}{
+		{"linear/flat", forecasters["linear"], series(30, flat), func(int) float64 { return 2000 }, 1e-6},
+		{"linear/trending", forecasters["linear"], series(30, trending), func(h int) float64 { return trending(29 + h) }, 1e-6},
+		{"moving_average/flat", forecasters["moving_average"], series(30, flat), func(int) float64 { return 2000 }, 1e-6},
+		{"moving_average/trending", forecasters["moving_average"], series(30, trending), func(int) float64 { return trending(26) }, 1e-6}, // mean of the last 7
+		{"holt_winters/flat", hw, series(28, flat), func(int) float64 { return 2000 }, 1e-6},
+		{"holt_winters/trending", hw, series(28, trending), func(h int) float64 { return trending(27 + h) }, 1e-6},
+		{"holt_winters/flat short", hw, series(10, flat), func(int) float64 { return 2000 }, 1e-6}, // Holt fallback
+		{"holt_winters/trending short", hw, series(10, trending), func(h int) float64 { return trending(9 + h) }, 1e-6},
+		{"holt_winters/weekly", hw, series(42, weekly), func(h int) float64 { return weekly(41 + h) }, 5},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path, err := tc.f.Forecast(tc.prices, 14)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(path) != 14 {
+				t.Fatalf("got %d points, want 14", len(path))
+			}
+			for h, got := range path {
+				if want := tc.want(h + 1); math.Abs(got-want) > tc.tol {
+					t.Errorf("day %d: %.2f, want %.2f ± %v", h+1, got, want, tc.tol)
+				}
+			}
+		})
+	}
+}
+
+func TestForecastersNeedHistory(t *testing.T) {
+	for name, f := range forecasters {
+		if _, err := f.Forecast(nil, 7); !errors.Is(err, errInsufficientHistory) {
+			t.Errorf("%s on no history: err %v, want errInsufficientHistory", name, err)
+		}
+	}
+}
+
+func TestResidualSigma(t *testing.T) {
+	for name, f := range forecasters {
+		if name == "moving_average" {
+			continue // lags any trend by design
+		}
+		if got := residualSigma(f, series(40, trending)); got > 1e-6 {
+			t.Errorf("%s on an exact trend: σ = %v, want 0", name, got)
+		}
+	}
+
+	// A moving average on a trend is off by the same lag every day
+	if got := residualSigma(forecasters["moving_average"], series(40, trending)); math.Abs(got-100) > 1e-6 {
+		t.Errorf("moving_average lag: σ = %v, want 100 (4 days × ₹25)", got)
+	}
+
+	// Alternating ±50 around 2000: every linear one-step forecast misses
+	noisy := series(40, func(t int) float64 { return 2000 + 50*float64(1-2*(t%2)) })
+	if got := residualSigma(forecasters["linear"], noisy); got < 50 {
+		t.Errorf("linear on ±50 noise: σ = %v, want at least 50", got)
+	}
+
+	if got := residualSigma(forecasters["linear"], series(5, trending)); got != 0 {
+		t.Errorf("five prices: σ = %v, want 0 (fewer than three residuals)", got)
+	}
+}
+
+func TestForecastPricesBands(t *testing.T) {
+	trendPct, points := forecastPrices("Tomato", series(40, trending), 3)
+	if len(points) != 3 {
+		t.Fatalf("got %d points, want 3", len(points))
+	}
+	// An exact trend leaves no residuals, so the day-1 band falls back to
+	// ±10% of today's price
+	if p, margin := points[0], trending(39)*fallbackBandPct; math.Abs(p.Upper-p.Price-margin) > 0.01 || math.Abs(p.Price-p.Lower-margin) > 0.01 {
+		t.Errorf("day 1 band %.2f–%.2f around %.2f, want ±%.2f", p.Lower, p.Upper, p.Price, margin)
+	}
+	for h, p := range points {
+		if want := todayIST().AddDate(0, 0, h+1).Format("2006-01-02"); p.Date != want {
+			t.Errorf("day %d dated %s, want %s (IST)", p.Day, p.Date, want)
+		}
+	}
+	if points[2].Upper-points[2].Price <= points[0].Upper-points[0].Price {
+		t.Error("bands should widen with the horizon")
+	}
+	if want := 25.0 * forecastHorizonDays / trending(39) * 100; math.Abs(trendPct-want) > 0.01 {
+		t.Errorf("trend %.2f%%, want %.2f%%", trendPct, want)
+	}
+}
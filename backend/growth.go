@@ -0,0 +1,489 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// ══════════════════════════════════════════════
+//  CROP GROWTH STAGE (Growing Degree Days)
+// ══════════════════════════════════════════════
+
+// cropPhenology is a crop's heat requirement: daily growing degree days
+// count the mean temperature above BaseTempC, with temperatures capped at
+// UpperTempC, and the crop is ready for harvest after GDDMaturity of them.
+type cropPhenology struct {
+	BaseTempC   float64 `db:"base_temp_c"`
+	UpperTempC  float64 `db:"upper_temp_c"`
+	GDDMaturity float64 `db:"gdd_maturity"`
+}
+
+// categoryPhenology backs crops without a crop_phenology row.
+var categoryPhenology = map[string]cropPhenology{
+	"vegetable":       {BaseTempC: 10, UpperTempC: 30, GDDMaturity: 1300},
+	"leafy_vegetable": {BaseTempC: 4, UpperTempC: 30, GDDMaturity: 800},
+	"root_tuber":      {BaseTempC: 7, UpperTempC: 30, GDDMaturity: 1500},
+	"bulb":            {BaseTempC: 5, UpperTempC: 30, GDDMaturity: 1900},
+	"fruit":           {BaseTempC: 10, UpperTempC: 35, GDDMaturity: 1800},
+	"grain":           {BaseTempC: 5, UpperTempC: 32, GDDMaturity: 1800},
+	"oilseed":         {BaseTempC: 5, UpperTempC: 30, GDDMaturity: 1600},
+	"cash_crop":       {BaseTempC: 12, UpperTempC: 35, GDDMaturity: 2500},
+	"spice":           {BaseTempC: 10, UpperTempC: 35, GDDMaturity: 2500},
+}
+
+const (
+	// growthRateWindowDays of recent weather set the pace used to project
+	// the harvest date beyond the forecast.
+	growthRateWindowDays = 14
+	// minDailyGDD keeps projections finite through cold spells.
+	minDailyGDD = 0.5
+	// maxSeasonDays bounds how far back a sowing date may be.
+	maxSeasonDays = 730
+	// archiveLagDays is how far behind today the reanalysis archive runs.
+	archiveLagDays = 5
+)
+
+var errNoTemperatureData = errors.New("no temperature history or forecast for the season")
+
+// fetchCropPhenology returns a crop's GDD parameters, falling back to its
+// category's defaults.
+func fetchCropPhenology(crop Crop) cropPhenology {
+	if db != nil && isValidUUID(crop.ID) {
+		var p cropPhenology
+		err := db.Get(&p, "SELECT base_temp_c, upper_temp_c, gdd_maturity FROM crop_phenology WHERE crop_id = $1", crop.ID)
+		if err == nil {
+			return p
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("⚠ DB fetch crop phenology failed: %v", err)
+		}
+	}
+	if p, ok := categoryPhenology[crop.Category]; ok {
+		return p
+	}
+	return categoryPhenology["vegetable"]
+}
+
+// dailyGDD is one day's growing degree days by the capped averaging method.
+func dailyGDD(p cropPhenology, tmax, tmin float64) float64 {
+	tmax = math.Min(tmax, p.UpperTempC)
+	tmin = math.Max(tmin, p.BaseTempC)
+	return math.Max(0, (tmax+tmin)/2-p.BaseTempC)
+}
+
+// stageForProgress maps the share of the season completed to a growth stage.
+func stageForProgress(progress float64) string {
+	switch {
+	case progress < 0.1:
+		return "sowing"
+	case progress < 0.45:
+		return "vegetative"
+	case progress < 0.8:
+		return "flowering"
+	default:
+		return "maturity"
+	}
+}
+
+// maturityForDays classes a crop by days left to harvest (negative once
+// past it) as "Early", "Optimal" or "Late".
+func maturityForDays(daysToHarvest int) string {
+	switch {
+	case daysToHarvest > maturityWindowDays:
+		return "Early"
+	case daysToHarvest < -maturityWindowDays:
+		return "Late"
+	default:
+		return "Optimal"
+	}
+}
+
+// ── Temperature History ─────────────────────
+
+// dailyTemp is one day's temperature extremes.
+type dailyTemp struct {
+	Date     string  `db:"date"`
+	TempMaxC float64 `db:"temp_max"`
+	TempMinC float64 `db:"temp_min"`
+}
+
+// fetchTempHistory returns the stored daily temperature extremes at a point
+// for every day from `from` to `to`: the grid cell's archived history, then
+// the cell's past forecasts (the last run for a day is close to what
+// happened). It never calls out on the request path; archive gaps are
+// queued for a background backfill and the growth model counts the missing
+// days as estimated in the meantime.
+func fetchTempHistory(lat, lon float64, from, to time.Time) []dailyTemp {
+	if db == nil || to.Before(from) {
+		return nil
+	}
+	cell := encodeGeohash(lat, lon, weatherCellPrecision)
+
+	var temps []dailyTemp
+	err := db.Select(&temps, `
+		SELECT date, temp_max, temp_min FROM (
+			SELECT to_char(obs_date, 'YYYY-MM-DD') AS date, temp_max, temp_min
+			FROM weather_daily_history
+			WHERE geohash = $1 AND obs_date BETWEEN $2 AND $3
+			UNION ALL
+			SELECT to_char(forecast_date, 'YYYY-MM-DD'), temp_max, temp_min
+			FROM weather_forecast_daily f
+			WHERE geohash = $1 AND forecast_date BETWEEN $2 AND $3
+			  AND NOT EXISTS (SELECT 1 FROM weather_daily_history h
+			                  WHERE h.geohash = f.geohash AND h.obs_date = f.forecast_date)
+		) t ORDER BY date`,
+		cell, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		log.Printf("⚠ DB fetch temperature history for cell %s failed: %v", cell, err)
+		return nil
+	}
+
+	// Days the archive could fill but nothing is stored for yet
+	archiveEnd := todayIST().AddDate(0, 0, -archiveLagDays)
+	if archiveEnd.After(to) {
+		archiveEnd = to
+	}
+	if want := int(archiveEnd.Sub(from).Hours()/24) + 1; want > 0 && countThrough(temps, archiveEnd) < want {
+		queueHistoryBackfill(cell, lat, lon, from)
+	}
+	return temps
+}
+
+// countThrough counts the (date-ordered) days up to and including `last`.
+func countThrough(temps []dailyTemp, last time.Time) int {
+	end := last.Format("2006-01-02")
+	n := 0
+	for _, t := range temps {
+		if t.Date > end {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// historyBackfills tracks cells with a backfill in flight so concurrent
+// requests for the same cell queue it once.
+var historyBackfills sync.Map
+
+// queueHistoryBackfill fills a cell's archive gaps from `from` in the
+// background.
+func queueHistoryBackfill(cell string, lat, lon float64, from time.Time) {
+	if _, running := historyBackfills.LoadOrStore(cell, true); running {
+		return
+	}
+	go func() {
+		defer historyBackfills.Delete(cell)
+		if n, err := backfillTempHistory(db, cell, lat, lon, from); err != nil {
+			log.Printf("⚠ Temperature backfill for cell %s failed: %v", cell, err)
+		} else if n > 0 {
+			log.Printf("🌡️ Backfilled %d days of temperature history for cell %s", n, cell)
+		}
+	}()
+}
+
+// backfillTempHistory fetches the days from `from` to the archive's end
+// that weather_daily_history lacks for a cell, in one archive request, and
+// stores them in one batch. It returns how many days were stored.
+func backfillTempHistory(db *sqlx.DB, cell string, lat, lon float64, from time.Time) (int, error) {
+	archiveEnd := todayIST().AddDate(0, 0, -archiveLagDays)
+	if archiveEnd.Before(from) {
+		return 0, nil
+	}
+
+	var span struct {
+		First *time.Time `db:"first"`
+		Last  *time.Time `db:"last"`
+	}
+	err := db.Get(&span, `
+		SELECT MIN(d)::date AS first, MAX(d)::date AS last
+		FROM generate_series($2::date, $3::date, INTERVAL '1 day') d
+		WHERE NOT EXISTS (SELECT 1 FROM weather_daily_history h WHERE h.geohash = $1 AND h.obs_date = d::date)`,
+		cell, from.Format("2006-01-02"), archiveEnd.Format("2006-01-02"))
+	if err != nil {
+		return 0, fmt.Errorf("find gaps: %w", err)
+	}
+	if span.First == nil {
+		return 0, nil
+	}
+
+	archived, err := fetchArchiveTemps(lat, lon, *span.First, *span.Last)
+	if err != nil {
+		return 0, err
+	}
+	return storeTempHistory(db, cell, archived)
+}
+
+// fetchArchiveTemps asks the Open-Meteo archive (ERA5 reanalysis) for daily
+// temperature extremes. Days the archive has not caught up on are skipped.
+func fetchArchiveTemps(lat, lon float64, from, to time.Time) ([]dailyTemp, error) {
+	url := fmt.Sprintf(
+		"https://archive-api.open-meteo.com/v1/archive?latitude=%.4f&longitude=%.4f"+
+			"&start_date=%s&end_date=%s&daily=temperature_2m_max,temperature_2m_min&timezone=auto",
+		lat, lon, from.Format("2006-01-02"), to.Format("2006-01-02"),
+	)
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("open-meteo archive request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("open-meteo archive returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Daily struct {
+			Time    []string   `json:"time"`
+			TempMax []*float64 `json:"temperature_2m_max"`
+			TempMin []*float64 `json:"temperature_2m_min"`
+		} `json:"daily"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse open-meteo archive JSON: %w", err)
+	}
+	d := result.Daily
+	temps := make([]dailyTemp, 0, len(d.Time))
+	for i, date := range d.Time {
+		if i >= len(d.TempMax) || i >= len(d.TempMin) || d.TempMax[i] == nil || d.TempMin[i] == nil {
+			continue
+		}
+		temps = append(temps, dailyTemp{Date: date, TempMaxC: *d.TempMax[i], TempMinC: *d.TempMin[i]})
+	}
+	return temps, nil
+}
+
+// storeTempHistory caches archive days for a grid cell in one statement.
+// Days already stored are kept.
+func storeTempHistory(db *sqlx.DB, cell string, temps []dailyTemp) (int, error) {
+	if len(temps) == 0 {
+		return 0, nil
+	}
+	dates := make([]string, len(temps))
+	maxC := make([]float64, len(temps))
+	minC := make([]float64, len(temps))
+	for i, t := range temps {
+		dates[i], maxC[i], minC[i] = t.Date, t.TempMaxC, t.TempMinC
+	}
+	res, err := db.Exec(`
+		INSERT INTO weather_daily_history (geohash, obs_date, temp_max, temp_min, source)
+		SELECT $1, d, hi, lo, 'era5'
+		FROM unnest($2::date[], $3::float8[], $4::float8[]) AS t(d, hi, lo)
+		ON CONFLICT (geohash, obs_date) DO NOTHING`,
+		cell, pq.Array(dates), pq.Array(maxC), pq.Array(minC))
+	if err != nil {
+		return 0, fmt.Errorf("store temperature history: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// backfillPlotHistory fills the temperature history of every cell with a
+// plot sown this season, so growth tracking rarely has to estimate. Run by
+// the weather worker; cells whose history is complete cost one query.
+func backfillPlotHistory(db *sqlx.DB) {
+	var plots []struct {
+		Lat    float64   `db:"lat"`
+		Lon    float64   `db:"lon"`
+		Sowing time.Time `db:"sowing_date"`
+	}
+	err := db.Select(&plots, `
+		SELECT ST_Y(location::geometry) AS lat, ST_X(location::geometry) AS lon, sowing_date
+		FROM farm_plots
+		WHERE sowing_date >= CURRENT_DATE - $1::int`, maxSeasonDays)
+	if err != nil {
+		log.Printf("[worker] Load plots for temperature backfill failed: %v", err)
+		return
+	}
+
+	// Earliest sowing per cell
+	type cellStart struct {
+		lat, lon float64
+		from     time.Time
+	}
+	cells := map[string]cellStart{}
+	for _, p := range plots {
+		cell := encodeGeohash(p.Lat, p.Lon, weatherCellPrecision)
+		if c, ok := cells[cell]; !ok || p.Sowing.Before(c.from) {
+			cells[cell] = cellStart{p.Lat, p.Lon, p.Sowing}
+		}
+	}
+
+	stored, fetched := 0, 0
+	for cell, c := range cells {
+		if fetched >= weatherMaxCellsPerRun {
+			break
+		}
+		if _, running := historyBackfills.LoadOrStore(cell, true); running {
+			continue
+		}
+		n, err := backfillTempHistory(db, cell, c.lat, c.lon, c.from)
+		historyBackfills.Delete(cell)
+		if err != nil {
+			log.Printf("[worker] Temperature backfill for cell %s failed: %v", cell, err)
+		}
+		if n > 0 || err != nil {
+			fetched++
+			time.Sleep(weatherRequestGap)
+		}
+		stored += n
+	}
+	if stored > 0 {
+		log.Printf("[worker] Backfilled %d days of temperature history across %d cells.", stored, fetched)
+	}
+}
+
+// ── Growth Model ────────────────────────────
+
+// projectGrowth accumulates GDD from sowing to yesterday and projects the
+// harvest date: through the weather forecast (today first), then at the
+// recent daily pace. Days missing from the history are counted at the
+// season's mean pace and reported as estimated.
+func projectGrowth(p cropPhenology, sowing, today time.Time, history []dailyTemp, forecast []DailyForecast) (GrowthStatus, error) {
+	g := GrowthStatus{
+		SowingDate:    sowing.Format("2006-01-02"),
+		BaseTempC:     p.BaseTempC,
+		GDDToMaturity: p.GDDMaturity,
+		Source:        "gdd",
+	}
+
+	seasonDays := 0
+	if today.After(sowing) {
+		seasonDays = int(today.Sub(sowing).Hours() / 24)
+	}
+
+	var observed, recent float64
+	recentDays := 0
+	for i, t := range history {
+		gdd := dailyGDD(p, t.TempMaxC, t.TempMinC)
+		observed += gdd
+		if i >= len(history)-growthRateWindowDays {
+			recent += gdd
+			recentDays++
+		}
+	}
+	g.DaysObserved = len(history)
+
+	var forecastGDD []float64
+	for _, f := range forecast {
+		forecastGDD = append(forecastGDD, dailyGDD(p, f.TempMaxC, f.TempMinC))
+	}
+
+	// Pace for missing days and for projecting past the forecast
+	var meanRate, recentRate float64
+	switch {
+	case len(history) > 0:
+		meanRate, recentRate = observed/float64(len(history)), recent/float64(recentDays)
+	case len(forecastGDD) > 0:
+		sum := 0.0
+		for _, v := range forecastGDD {
+			sum += v
+		}
+		meanRate = sum / float64(len(forecastGDD))
+		recentRate = meanRate
+	case seasonDays > 0:
+		return g, errNoTemperatureData
+	}
+
+	g.DaysEstimated = max(0, seasonDays-len(history))
+	acc := observed + float64(g.DaysEstimated)*meanRate
+	g.GDDAccumulated = math.Round(acc)
+	g.DailyGDD = round2(recentRate)
+	progress := acc / p.GDDMaturity
+	g.ProgressPct = math.Round(progress*1000) / 10
+	g.Stage = stageForProgress(progress)
+
+	remaining := p.GDDMaturity - acc
+	switch {
+	case remaining <= 0:
+		g.DaysToHarvest = -int(-remaining / math.Max(recentRate, minDailyGDD))
+	default:
+		days := 0
+		for remaining > 0 && days < maxSeasonDays {
+			rate := math.Max(recentRate, minDailyGDD)
+			if days < len(forecastGDD) {
+				rate = forecastGDD[days]
+			}
+			remaining -= rate
+			days++
+		}
+		g.DaysToHarvest = days
+	}
+	g.ExpectedHarvestDate = today.AddDate(0, 0, g.DaysToHarvest).Format("2006-01-02")
+	g.Maturity = maturityForDays(g.DaysToHarvest)
+	return g, nil
+}
+
+// trackGrowth runs the GDD model for a crop sown on `sowing` at a point.
+// history is from fetchTempHistory over sowing..yesterday.
+func trackGrowth(crop Crop, sowing time.Time, history []dailyTemp, forecast []DailyForecast) (*GrowthStatus, error) {
+	g, err := projectGrowth(fetchCropPhenology(crop), sowing, todayIST(), history, forecast)
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// parseSowingDate validates a sowing date for the growth model.
+func parseSowingDate(s string) (time.Time, error) {
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("sowing_date must be YYYY-MM-DD")
+	}
+	if d.After(todayIST()) {
+		return time.Time{}, fmt.Errorf("sowing_date must not be in the future")
+	}
+	if todayIST().Sub(d) > maxSeasonDays*24*time.Hour {
+		return time.Time{}, fmt.Errorf("sowing_date must be within the last %d days", maxSeasonDays)
+	}
+	return d, nil
+}
+
+// ── Handlers ────────────────────────────────
+
+// handlePlotGrowth returns the GDD growth stage and projected harvest for a plot.
+func handlePlotGrowth(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	id := c.Param("id")
+	plot, err := fetchPlot(id)
+	if err != nil {
+		respondPlotError(c, id, err)
+		return
+	}
+	crop, err := fetchCrop(plot.CropID)
+	if err != nil {
+		respondCropError(c, plot.CropID, err)
+		return
+	}
+	sowing, err := time.Parse("2006-01-02", plot.SowingDate)
+	if err != nil || sowing.After(todayIST()) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("plot %s has not been sown yet", id)})
+		return
+	}
+	if todayIST().Sub(sowing) > maxSeasonDays*24*time.Hour {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("plot %s was sown more than %d days ago; update its season", id, maxSeasonDays)})
+		return
+	}
+
+	history := fetchTempHistory(plot.Lat, plot.Lon, sowing, todayIST().AddDate(0, 0, -1))
+	weather := fetchWeatherFromDB(plot.Lat, plot.Lon, crop.IdealTemp)
+	growth, err := trackGrowth(crop, sowing, history, weather.Forecast)
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, growth)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// temps builds n days of history from sowing with the same extremes.
+func temps(sowing time.Time, n int, tmax, tmin float64) []dailyTemp {
+	days := make([]dailyTemp, n)
+	for i := range days {
+		days[i] = dailyTemp{Date: sowing.AddDate(0, 0, i).Format("2006-01-02"), TempMaxC: tmax, TempMinC: tmin}
+	}
+	return days
+}
+
+func TestProjectGrowth(t *testing.T) {
+	p := cropPhenology{BaseTempC: 10, UpperTempC: 30, GDDMaturity: 1000}
+	sowing := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	today := sowing.AddDate(0, 0, 40)
+
+	// 30/20 °C is 15 GDD a day
+	tests := []struct {
+		name      string
+		history   []dailyTemp
+		observed  int
+		estimated int
+		gdd       float64
+	}{
+		{"full history", temps(sowing, 40, 30, 20), 40, 0, 600},
+		// The last ten days are not stored yet: counted at the mean pace
+		{"missing days", temps(sowing, 30, 30, 20), 30, 10, 600},
+		// 20 GDD days (capped at 30 °C) then 5 GDD days: the 20 missing days
+		// are counted at the observed mean of 12.5
+		{"missing days, mixed pace", append(temps(sowing, 10, 40, 30), temps(sowing.AddDate(0, 0, 10), 10, 20, 10)...), 20, 20, 250 + 20*12.5},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := projectGrowth(p, sowing, today, tc.history, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g.DaysObserved != tc.observed || g.DaysEstimated != tc.estimated {
+				t.Errorf("observed %d, estimated %d; want %d, %d", g.DaysObserved, g.DaysEstimated, tc.observed, tc.estimated)
+			}
+			if g.GDDAccumulated != tc.gdd {
+				t.Errorf("GDD %v, want %v", g.GDDAccumulated, tc.gdd)
+			}
+			if g.DaysToHarvest <= 0 {
+				t.Errorf("%d days to harvest before reaching maturity", g.DaysToHarvest)
+			}
+		})
+	}
+}
+
+func TestProjectGrowthNoHistory(t *testing.T) {
+	p := cropPhenology{BaseTempC: 10, UpperTempC: 30, GDDMaturity: 1000}
+	sowing := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	today := sowing.AddDate(0, 0, 20)
+
+	if _, err := projectGrowth(p, sowing, today, nil, nil); !errors.Is(err, errNoTemperatureData) {
+		t.Errorf("no temperatures: err %v, want errNoTemperatureData", err)
+	}
+
+	// Nothing stored yet: every past day is estimated from the forecast pace
+	forecast := []DailyForecast{{TempMaxC: 30, TempMinC: 20}, {TempMaxC: 30, TempMinC: 20}}
+	g, err := projectGrowth(p, sowing, today, nil, forecast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.DaysEstimated != 20 || g.GDDAccumulated != 300 {
+		t.Errorf("estimated %d days, %v GDD; want 20 days, 300 GDD", g.DaysEstimated, g.GDDAccumulated)
+	}
+}
@@ -6,7 +6,10 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -14,13 +17,38 @@ import (
 
 // ── Shared Structs for Ingestion ──────────────────────────
 
+// LivePrice is one data.gov.in (Agmarknet) record: the prices traded for a
+// commodity variety and grade at a market on its arrival_date.
 type LivePrice struct {
-	Market     string  `json:"market"`
-	State      string  `json:"state"`
-	Commodity  string  `json:"commodity"`
-	MinPrice   float64 `json:"min_price"`
-	MaxPrice   float64 `json:"max_price"`
-	ModalPrice float64 `json:"modal_price"`
+	Market         string
+	State          string
+	District       string
+	Commodity      string
+	Variety        string
+	Grade          string
+	ArrivalDate    time.Time // trading date reported by the mandi
+	MinPrice       float64   // INR per quintal
+	MaxPrice       float64   // INR per quintal
+	ModalPrice     float64   // INR per quintal
+	ArrivalsTonnes float64
+}
+
+// apiNumber decodes data.gov.in numeric fields, which arrive either as JSON
+// numbers or as strings ("2500", "" or "NR" when not reported).
+type apiNumber float64
+
+func (n *apiNumber) UnmarshalJSON(b []byte) error {
+	s := strings.TrimSpace(strings.Trim(string(b), `"`))
+	if s == "" || s == "null" || strings.EqualFold(s, "NR") {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q: %w", s, err)
+	}
+	*n = apiNumber(v)
+	return nil
 }
 
 // StartIngestionCron spawns background workers to ingest external API data into the DB.
@@ -30,25 +58,24 @@ func StartIngestionCron(db *sqlx.DB) {
 		return
 	}
 
-	apiKey := os.Getenv("DATA_GOV_API_KEY")
-	if apiKey == "" || apiKey == "your_api_key_here" {
-		log.Println("Ingestion workers disabled: no valid DATA_GOV_API_KEY provided.")
-		return
-	}
-
 	log.Println("Starting background async ingestion workers...")
 
 	// Tick every 12 hours for Mandis
-	mandiTicker := time.NewTicker(12 * time.Hour)
-	go func() {
-		// Run once immediately
-		ingestMandiPrices(db, apiKey)
-		for range mandiTicker.C {
+	apiKey := os.Getenv("DATA_GOV_API_KEY")
+	if apiKey == "" || apiKey == "your_api_key_here" {
+		log.Println("Mandi ingestion disabled: no valid DATA_GOV_API_KEY provided.")
+	} else {
+		mandiTicker := time.NewTicker(12 * time.Hour)
+		go func() {
+			// Run once immediately
 			ingestMandiPrices(db, apiKey)
-		}
-	}()
+			for range mandiTicker.C {
+				ingestMandiPrices(db, apiKey)
+			}
+		}()
+	}
 
-	// Weather ticker could tick every 1 hour, but simplified for now:
+	// Weather grid refreshes hourly; Open-Meteo needs no key.
 	weatherTicker := time.NewTicker(1 * time.Hour)
 	go func() {
 		ingestWeatherGrid(db)
@@ -58,115 +85,245 @@ func StartIngestionCron(db *sqlx.DB) {
 	}()
 }
 
-func ingestMandiPrices(db *sqlx.DB, apiKey string) {
-	log.Println("[worker] Fetching data.gov.in live prices...")
-	crops := []string{"Tomato", "Wheat", "Rice"}
+// Data.gov.in resources: the live feed carries only the latest sessions,
+// the variety-wise history resource supports arrival_date filters for backfill.
+const (
+	liveMandiResource    = "9ef84268-d588-465a-a308-a864a43d0070"
+	historyMandiResource = "35985678-0d79-46b4-9ed6-6f13308a1d24"
+	mandiPageSize        = 500
+	mandiMaxPages        = 40 // safety cap: 20k records per commodity per run
+)
 
-	for _, crop := range crops {
-		livePrices, err := fetchLiveMandiPrices(apiKey, crop)
-		if err != nil {
-			log.Printf("[worker] Failed to fetch live prices for %s: %v", crop, err)
+// ingestTarget is a catalogue crop and the data.gov.in commodity names it is
+// published under (see commodity_aliases).
+type ingestTarget struct {
+	CropName string
+	APINames []string
+}
+
+// loadIngestTargets derives the commodity list from the crops catalogue.
+// Crops without an alias are queried under their own name.
+func loadIngestTargets(db *sqlx.DB, cropFilter string) ([]ingestTarget, error) {
+	var rows []struct {
+		CropName string `db:"crop_name"`
+		APIName  string `db:"api_name"`
+	}
+	err := db.Select(&rows, `
+		SELECT c.name AS crop_name, COALESCE(a.api_name, c.name) AS api_name
+		FROM crops c
+		LEFT JOIN commodity_aliases a ON a.crop_id = c.id
+		WHERE $1 = '' OR LOWER(c.name) = LOWER($1)
+		ORDER BY c.name, api_name`, cropFilter)
+	if err != nil {
+		return nil, fmt.Errorf("load crop catalogue: %w", err)
+	}
+
+	var targets []ingestTarget
+	for _, r := range rows {
+		if n := len(targets); n > 0 && targets[n-1].CropName == r.CropName {
+			targets[n-1].APINames = append(targets[n-1].APINames, r.APIName)
 			continue
 		}
+		targets = append(targets, ingestTarget{CropName: r.CropName, APINames: []string{r.APIName}})
+	}
+	return targets, nil
+}
 
-		for _, lp := range livePrices {
-			// 1. Ensure Mandi exists and get ID
-			var mandiID int
-			err := db.Get(&mandiID, "SELECT id FROM mandis WHERE name = $1", lp.Market)
-			if err != nil {
-				// Create mandi
-				lat, lon := getCoordinatesForMarket(lp.Market, lp.State)
-				err = db.QueryRow("INSERT INTO mandis (name, location) VALUES ($1, ST_SetSRID(ST_MakePoint($2, $3), 4326)::geography) RETURNING id", lp.Market, lon, lat).Scan(&mandiID)
-				if err != nil {
-					log.Printf("[worker] Failed to insert mandi %s: %v", lp.Market, err)
-					continue
-				}
-			}
+func ingestMandiPrices(db *sqlx.DB, apiKey string) {
+	log.Println("[worker] Fetching data.gov.in live prices...")
+	targets, err := loadIngestTargets(db, "")
+	if err != nil {
+		log.Printf("[worker] %v", err)
+		return
+	}
 
-			// 2. Insert into daily_prices
-			_, err = db.Exec(`
-				INSERT INTO daily_prices (mandi_id, crop_name, price) 
-				VALUES ($1, $2, $3) 
-				ON CONFLICT (mandi_id, crop_name, recorded_at) DO NOTHING`,
-				mandiID, crop, lp.ModalPrice,
-			)
+	for _, t := range targets {
+		// Every alias is one batch so a crop's arrivals are totalled across them
+		var livePrices []LivePrice
+		for _, apiName := range t.APINames {
+			batch, err := fetchLiveMandiPrices(apiKey, liveMandiResource, apiName, nil)
 			if err != nil {
-				log.Printf("[worker] Failed to insert price for %s at %s: %v", crop, lp.Market, err)
+				// Keep the pages fetched before the failure, as the backfill does
+				log.Printf("[worker] Failed to fetch live prices for %s (%s) after %d records: %v", t.CropName, apiName, len(batch), err)
 			}
+			livePrices = append(livePrices, batch...)
 		}
+		stored := storeLivePrices(db, t.CropName, livePrices)
+		log.Printf("[worker] %s: stored %d price records", t.CropName, stored)
 	}
 	log.Println("[worker] Completed mandi price ingestion cycle.")
 }
 
-func ingestWeatherGrid(db *sqlx.DB) {
-	// In a real system, we'd query all farmer locations or a grid spanning India.
-	// We'll mock a small grid loop here.
-	log.Println("[worker] Fetching Open-Meteo weather updates...")
-	lat, lon := 28.6139, 77.2090 // Delhi base
+// storeLivePrices upserts records under our catalogue crop name and returns
+// how many were written. data.gov.in publishes one record per variety and
+// grade, while mandi_arrivals keeps one row per mandi, crop and day, so
+// arrivals are summed over the batch and each day's total is written once.
+// Totals replace the stored row rather than adding to it, so re-running a
+// day (or backfilling it) never double-counts; pass all of a crop's records
+// for a day in one batch.
+func storeLivePrices(db *sqlx.DB, crop string, livePrices []LivePrice) int {
+	type arrivalKey struct {
+		mandiID int
+		date    time.Time
+	}
+	arrivals := map[arrivalKey]float64{}
+	markets := map[arrivalKey]string{}
 
-	w := fetchWeather(lat, lon, 25.0)
+	stored := 0
+	for _, lp := range livePrices {
+		// 1. Ensure Mandi exists and get ID (new mandis start unresolved)
+		mandiID, err := ensureMandi(db, lp)
+		if err != nil {
+			log.Printf("[worker] Failed to register mandi %s: %v", lp.Market, err)
+			continue
+		}
 
-	_, err := db.Exec(`
-		INSERT INTO weather_cache (geohash, temp, humidity, recorded_at)
-		VALUES ('hash123', $1, $2, CURRENT_TIMESTAMP)
-		ON CONFLICT (geohash, recorded_at) DO NOTHING`,
-		w.CurrentTemp, w.Humidity,
-	)
-	if err != nil {
-		log.Printf("[worker] Weather ingestion failed: %v", err)
+		// 2. Upsert into daily_prices, one row per variety/grade per trading day
+		_, err = db.Exec(`
+			INSERT INTO daily_prices (mandi_id, crop_name, variety, grade, arrival_date, price, min_price, max_price)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (mandi_id, crop_name, variety, grade, arrival_date)
+			DO UPDATE SET price = EXCLUDED.price, min_price = EXCLUDED.min_price,
+			              max_price = EXCLUDED.max_price, recorded_at = CURRENT_TIMESTAMP`,
+			mandiID, crop, lp.Variety, lp.Grade, lp.ArrivalDate.Format("2006-01-02"),
+			lp.ModalPrice, lp.MinPrice, lp.MaxPrice,
+		)
+		if err != nil {
+			log.Printf("[worker] Failed to insert price for %s at %s: %v", crop, lp.Market, err)
+			continue
+		}
+		stored++
+
+		// 3. Total measured arrivals across varieties for glut detection
+		if lp.ArrivalsTonnes > 0 {
+			key := arrivalKey{mandiID, lp.ArrivalDate}
+			arrivals[key] += lp.ArrivalsTonnes
+			markets[key] = lp.Market
+		}
 	}
+
+	for key, tonnes := range arrivals {
+		if err := storeArrival(db, key.mandiID, crop, key.date, tonnes); err != nil {
+			log.Printf("[worker] Failed to insert arrivals for %s at %s: %v", crop, markets[key], err)
+		}
+	}
+	return stored
 }
 
 // ── Shared Mandi Fetcher for Cron ────────────────────
 
-// ── Shared Weather Fetcher for Cron ────────────────────
-
-func fetchWeather(lat, lon, idealTemp float64) WeatherInfo {
-	url := fmt.Sprintf(
-		"https://api.open-meteo.com/v1/forecast?latitude=%.4f&longitude=%.4f&current_weather=true&hourly=relative_humidity_2m",
-		lat, lon,
-	)
-
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(url)
-	if err == nil && resp.StatusCode == http.StatusOK {
-		defer resp.Body.Close()
-		body, readErr := io.ReadAll(resp.Body)
-		if readErr == nil {
-			var result struct {
-				CurrentWeather struct {
-					Temperature float64 `json:"temperature"`
-					WeatherCode int     `json:"weathercode"`
-				} `json:"current_weather"`
-				Hourly struct {
-					Humidity []float64 `json:"relative_humidity_2m"`
-				} `json:"hourly"`
-			}
-			if json.Unmarshal(body, &result) == nil {
-				humidity := 60.0
-				if len(result.Hourly.Humidity) > 0 {
-					humidity = result.Hourly.Humidity[0]
-				}
-				condition := weatherCodeToCondition(result.CurrentWeather.WeatherCode)
-				return WeatherInfo{
-					CurrentTemp: result.CurrentWeather.Temperature,
-					Humidity:    humidity,
-					TempDelta:   result.CurrentWeather.Temperature - idealTemp,
-					Condition:   condition,
-				}
-			}
+// fetchLiveMandiPrices pages through every data.gov.in record for a
+// commodity. When arrivalDate is set only that trading day is requested.
+func fetchLiveMandiPrices(apiKey, resource, commodity string, arrivalDate *time.Time) ([]LivePrice, error) {
+	var all []LivePrice
+	for page := 0; page < mandiMaxPages; page++ {
+		records, total, err := fetchMandiPage(apiKey, resource, commodity, arrivalDate, page*mandiPageSize)
+		if err != nil {
+			return all, err
+		}
+		all = append(all, records...)
+		if (page+1)*mandiPageSize >= total {
+			return all, nil
 		}
 	}
+	log.Printf("[worker] %s: stopped after %d pages (page cap reached)", commodity, mandiMaxPages)
+	return all, nil
+}
+
+// fetchMandiPage fetches one page of records and the total available.
+func fetchMandiPage(apiKey, resource, commodity string, arrivalDate *time.Time, offset int) ([]LivePrice, int, error) {
+	q := url.Values{}
+	q.Set("api-key", apiKey)
+	q.Set("format", "json")
+	q.Set("filters[commodity]", commodity)
+	if arrivalDate != nil {
+		q.Set("filters[arrival_date]", arrivalDate.Format("02/01/2006"))
+	}
+	q.Set("sort[arrival_date]", "desc")
+	q.Set("offset", strconv.Itoa(offset))
+	q.Set("limit", strconv.Itoa(mandiPageSize))
+	endpoint := "https://api.data.gov.in/resource/" + resource + "?" + q.Encode()
 
-	log.Printf("⚠ Open-Meteo API failed – using fallback weather data")
-	return WeatherInfo{
-		CurrentTemp: 32.4,
-		Humidity:    68.0,
-		TempDelta:   32.4 - idealTemp,
-		Condition:   "Partly Cloudy",
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return nil, 0, fmt.Errorf("data.gov.in request failed: %w", err)
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("data.gov.in returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// The API returns: { "total": N, "records": [ { "market": "...", "modal_price": "...", ... } ] }
+	// Field matching is case-insensitive, so the history resource's
+	// "Min_Price"/"Arrival_Date" keys decode into the same struct.
+	var apiResp struct {
+		Total   int `json:"total"`
+		Records []struct {
+			Market      string    `json:"market"`
+			Commodity   string    `json:"commodity"`
+			Variety     string    `json:"variety"`
+			Grade       string    `json:"grade"`
+			State       string    `json:"state"`
+			District    string    `json:"district"`
+			ArrivalDate string    `json:"arrival_date"` // dd/mm/yyyy
+			MinPrice    apiNumber `json:"min_price"`
+			MaxPrice    apiNumber `json:"max_price"`
+			ModalPrice  apiNumber `json:"modal_price"`
+			Arrivals    apiNumber `json:"arrivals_in_tonnes"` // Agmarknet reports arrivals in tonnes
+		} `json:"records"`
+	}
+
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, 0, fmt.Errorf("failed to parse data.gov.in JSON: %w", err)
+	}
+
+	var records []LivePrice
+	for _, r := range apiResp.Records {
+		if r.ModalPrice <= 0 {
+			continue
+		}
+		arrivalDate, err := parseArrivalDate(r.ArrivalDate)
+		if err != nil {
+			log.Printf("[worker] Skipping %s record at %s: bad arrival_date %q", r.Commodity, r.Market, r.ArrivalDate)
+			continue
+		}
+
+		// Prices stay per quintal (100kg) to match our CurrentPrice field.
+		// Some records omit min/max; fall back to the modal price.
+		lp := LivePrice{
+			Market:         r.Market,
+			State:          r.State,
+			District:       r.District,
+			Commodity:      r.Commodity,
+			Variety:        strings.TrimSpace(r.Variety),
+			Grade:          strings.TrimSpace(r.Grade),
+			ArrivalDate:    arrivalDate,
+			MinPrice:       float64(r.MinPrice),
+			MaxPrice:       float64(r.MaxPrice),
+			ModalPrice:     float64(r.ModalPrice),
+			ArrivalsTonnes: float64(r.Arrivals),
+		}
+		if lp.MinPrice <= 0 {
+			lp.MinPrice = lp.ModalPrice
+		}
+		if lp.MaxPrice <= 0 {
+			lp.MaxPrice = lp.ModalPrice
+		}
+		records = append(records, lp)
+	}
+
+	return records, apiResp.Total, nil
 }
 
+// ── Shared Weather Fetcher for Cron ────────────────────
+
 func weatherCodeToCondition(code int) string {
 	switch {
 	case code == 0:
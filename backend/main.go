@@ -38,7 +38,18 @@ func main() {
 	}
 
 	InitDB()
+
+	// One-off maintenance commands, e.g. `go run . backtest`
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalf("%s failed: %v", os.Args[1], err)
+		}
+		return
+	}
+
 	StartIngestionCron(db)
+	StartAccuracyJob(db)
+	StartAlertJob(db)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -54,6 +65,55 @@ func main() {
 	r.GET("/api/v1/recommendation", handleRecommendation)
 	r.POST("/api/v1/chat", handleChat)
 
+	// Farmer registry
+	r.POST("/api/v1/farmers", handleCreateFarmer)
+	r.GET("/api/v1/farmers/:id", handleGetFarmer)
+	r.PUT("/api/v1/farmers/:id", handleUpdateFarmer)
+	r.DELETE("/api/v1/farmers/:id", handleDeleteFarmer)
+	r.GET("/api/v1/farmers/:id/recommendations", handleListFarmerRecommendations)
+	r.GET("/api/v1/farmers/:id/notification-preferences", handleGetNotificationPreferences)
+	r.PUT("/api/v1/farmers/:id/notification-preferences", handleUpdateNotificationPreferences)
+	r.GET("/api/v1/farmers/:id/alerts", handleListFarmerAlerts)
+	r.GET("/api/v1/farmers/:id/crops", handleListFarmerCrops)
+	r.PUT("/api/v1/farmers/:id/crops/:crop_id", handleUpsertFarmerCrop)
+	r.DELETE("/api/v1/farmers/:id/crops/:crop_id", handleDeleteFarmerCrop)
+	r.GET("/api/v1/farmers/:id/plots", handleListPlots)
+	r.POST("/api/v1/farmers/:id/plots", handleCreatePlot)
+	r.GET("/api/v1/farmers/:id/storage-bookings", handleListFarmerStorageBookings)
+	r.GET("/api/v1/farmers/:id/soil-tests", handleListSoilTests)
+	r.POST("/api/v1/farmers/:id/soil-tests", handleCreateSoilTest)
+	r.GET("/api/v1/recommendations/:id", handleGetRecommendation)
+	r.POST("/api/v1/recommendations/:id/outcome", handleReportOutcome)
+	r.GET("/api/v1/accuracy", handleAccuracyMetrics)
+
+	// Crop catalogue
+	r.GET("/api/v1/crops", handleListCrops)
+	r.POST("/api/v1/crops", handleCreateCrop)
+	r.GET("/api/v1/crops/:id", handleGetCrop)
+	r.PUT("/api/v1/crops/:id", handleUpdateCrop)
+
+	// Farm plots
+	r.GET("/api/v1/plots/:id", handleGetPlot)
+	r.GET("/api/v1/plots/:id/growth", handlePlotGrowth)
+	r.PUT("/api/v1/plots/:id", handleUpdatePlot)
+	r.DELETE("/api/v1/plots/:id", handleDeletePlot)
+
+	// Soil readings with provenance
+	r.GET("/api/v1/soil", handleGetSoil)
+	r.GET("/api/v1/advisory", handleFieldAdvisory)
+
+	// Cold storage capacity and bookings
+	r.GET("/api/v1/storage/nearby", handleNearbyStorage)
+	r.GET("/api/v1/storage/facilities/:id/availability", handleStorageAvailability)
+	r.POST("/api/v1/storage/bookings", handleCreateStorageBooking)
+	r.GET("/api/v1/storage/bookings/:id", handleGetStorageBooking)
+	r.POST("/api/v1/storage/bookings/:id/confirm", handleConfirmStorageBooking)
+	r.POST("/api/v1/storage/bookings/:id/cancel", handleCancelStorageBooking)
+
+	// Operator endpoints
+	admin := r.Group("/api/v1/admin", requireAdmin())
+	admin.GET("/mandis/unresolved", handleListUnresolvedMandis)
+
 	// WhatsApp Webhook
 	r.POST("/api/v1/webhook/whatsapp", handleWhatsAppWebhook)
 
@@ -103,7 +163,12 @@ func handleWhatsAppWebhook(c *gin.Context) {
 				text := strings.TrimSpace(msg.Text.Body)
 
 				// Expected Format: "MarketName CropName Price" (e.g. "Azadpur Tomato 2500")
+				// or an outcome report: "SOLD MarketName Price SoldKg SpoiledKg"
 				parts := strings.Split(text, " ")
+				if strings.EqualFold(parts[0], "SOLD") {
+					recordWhatsAppOutcome(phone, parts[1:])
+					continue
+				}
 				if len(parts) >= 3 {
 					// We'll assume the last part is the price, and the second-to-last is the crop
 					priceStr := parts[len(parts)-1]
@@ -137,17 +202,52 @@ func handleWhatsAppWebhook(c *gin.Context) {
 func handleRecommendation(c *gin.Context) {
 	farmerID := c.Query("farmer_id")
 	cropID := c.Query("crop_id")
+	plotID := c.Query("plot_id")
+
+	// A stored plot supplies the farmer, crop, location, maturity and quantity.
+	var plot *Plot
+	if plotID != "" {
+		p, err := fetchPlot(plotID)
+		if err == nil && farmerID != "" && farmerID != p.FarmerID {
+			err = errPlotNotFound
+		}
+		if err != nil {
+			respondPlotError(c, plotID, err)
+			return
+		}
+		if cropID != "" && cropID != p.CropID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("crop_id does not match the crop on plot %s", plotID)})
+			return
+		}
+		plot, farmerID, cropID = &p, p.FarmerID, p.CropID
+	}
 
 	if farmerID == "" || cropID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "farmer_id and crop_id query parameters are required",
+			"error": "farmer_id and crop_id (or plot_id) query parameters are required",
 		})
 		return
 	}
 
 	// ── Step 1: Fetch farmer + crop ──
-	farmer := fetchFarmer(farmerID)
-	crop := fetchCrop(cropID)
+	farmer, err := fetchFarmer(farmerID)
+	if err != nil {
+		respondFarmerError(c, farmerID, err)
+		return
+	}
+	crop, err := fetchCrop(cropID)
+	if err != nil {
+		respondCropError(c, cropID, err)
+		return
+	}
+
+	// Plot-derived defaults; explicit query parameters still win.
+	maturityDefault, stageDefault, acresDefault := "Optimal", "maturity", 1.0
+	if plot != nil {
+		farmer.LocationLat, farmer.LocationLon = plot.Lat, plot.Lon
+		maturityDefault, stageDefault = plotMaturity(*plot, todayIST())
+		acresDefault = plot.AreaAcres
+	}
 
 	// Override location with live GPS if provided
 	if latStr := c.Query("lat"); latStr != "" {
@@ -163,33 +263,129 @@ func handleRecommendation(c *gin.Context) {
 	log.Printf("📍 Using location: lat=%.4f, lon=%.4f", farmer.LocationLat, farmer.LocationLon)
 
 	roadQuality := c.DefaultQuery("road_quality", "mixed")
-	cropMaturity := c.DefaultQuery("crop_maturity", "Optimal")
+	cropMaturity := c.DefaultQuery("crop_maturity", maturityDefault)
 
 	lang := c.DefaultQuery("lang", "en") // Default to English if not provided
 
+	forecastDays, err := strconv.Atoi(c.DefaultQuery("forecast_days", strconv.Itoa(forecastHorizonDays)))
+	if err != nil || forecastDays < 1 || forecastDays > maxForecastDays {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("forecast_days must be an integer between 1 and %d", maxForecastDays),
+		})
+		return
+	}
+
+	// Field advisory: standing crops default to the pre-harvest stage.
+	growthStage, areaAcres, err := parseAdvisoryParams(c, stageDefault, acresDefault)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Growth tracking: GDD since sowing derives maturity and stage unless
+	// crop_maturity / growth_stage are given explicitly.
+	var sowing time.Time
+	sowingDate := c.Query("sowing_date")
+	if sowingDate == "" && plot != nil {
+		sowingDate = plot.SowingDate
+	}
+	if sowingDate != "" {
+		if sowing, err = parseSowingDate(sowingDate); err != nil {
+			if plot == nil || c.Query("sowing_date") != "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			log.Printf("🌱 Plot %s not tracked: %v", plot.ID, err)
+			sowingDate = "" // a plot sown in the future or seasons ago keeps its calendar defaults
+		}
+	}
+
+	// Harvest quantity: request > plot's expected yield > farmer's crop record > one-tonne default
+	quantityKg, quantitySource := defaultLoadKg, "default"
+	quantity, quantityUnit := defaultLoadKg/kgPerUnit["quintal"], "quintal"
+	if qStr := c.Query("quantity"); qStr != "" {
+		q, err := strconv.ParseFloat(qStr, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be a number"})
+			return
+		}
+		unit := c.DefaultQuery("unit", "quintal")
+		if quantityKg, err = quantityToKg(q, unit); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		quantity, quantityUnit, quantitySource = q, unit, "request"
+	} else if plot != nil && plot.ExpectedYield != nil {
+		quantityKg, _ = quantityToKg(*plot.ExpectedYield, plot.YieldUnit)
+		quantity, quantityUnit, quantitySource = *plot.ExpectedYield, plot.YieldUnit, "plot"
+	} else if fc, err := fetchFarmerCrop(farmer.ID, cropID); err == nil {
+		quantityKg, _ = quantityToKg(fc.Quantity, fc.Unit)
+		quantity, quantityUnit, quantitySource = fc.Quantity, fc.Unit, "farmer_crop"
+	}
+
 	// ── Step 2: PostgreSQL / PostGIS Cached Fetches ──
 	var wg sync.WaitGroup
 	var weather WeatherInfo
 	var markets []MandiPrice
 	var soil SoilHealth
+	var tempHistory []dailyTemp
 
-	wg.Add(3)
+	wg.Add(4)
 	go func() {
 		defer wg.Done()
 		weather = fetchWeatherFromDB(farmer.LocationLat, farmer.LocationLon, crop.IdealTemp)
 	}()
 	go func() {
 		defer wg.Done()
-		markets = fetchMarketPricesFromDB(cropID, crop.Name, farmer.LocationLat, farmer.LocationLon)
+		// Forecast far enough for the store-vs-sell optimiser; the response
+		// shows only the first forecastDays
+		markets = fetchMarketPricesFromDB(cropID, crop.Name, farmer.LocationLat, farmer.LocationLon, max(forecastDays, storageHorizonDays(crop)))
 	}()
 	go func() {
 		defer wg.Done()
-		soil = fetchSoilHealth(farmer.LocationLat, farmer.LocationLon)
+		soil = fetchSoilHealth(farmer.ID, farmer.LocationLat, farmer.LocationLon)
+	}()
+	go func() {
+		defer wg.Done()
+		if sowingDate != "" {
+			tempHistory = fetchTempHistory(farmer.LocationLat, farmer.LocationLon, sowing, todayIST().AddDate(0, 0, -1))
+		}
 	}()
 	wg.Wait()
 
+	var growth *GrowthStatus
+	if sowingDate != "" {
+		if growth, err = trackGrowth(crop, sowing, tempHistory, weather.Forecast); err != nil {
+			log.Printf("⚠ Growth tracking for %s failed: %v", crop.Name, err)
+		} else {
+			log.Printf("🌱 %s sown %s: %.0f/%.0f GDD, %s, %d days to harvest", crop.Name, sowingDate, growth.GDDAccumulated, growth.GDDToMaturity, growth.Stage, growth.DaysToHarvest)
+			if c.Query("crop_maturity") == "" {
+				cropMaturity = growth.Maturity
+			}
+			if c.Query("growth_stage") == "" {
+				growthStage = growth.Stage
+			}
+		}
+	}
+
+	// Conditions the load travels (and may be stored) in; see spoilage.go
+	factors := SpoilageFactors{
+		TemperatureCelsius: weather.CurrentTemp,
+		HumidityPercent:    weather.Humidity,
+		RoadQuality:        roadQuality,
+		CropMaturity:       cropMaturity,
+	}
+	if weather.Recent != nil {
+		factors.RecentHumidHours = weather.Recent.HumidHours
+		factors.RecentHeatDegreeHr = weather.Recent.HeatDegreeHours
+	}
+
 	// ── Step 3: Compute transit times + market scores ──
-	marketOptions := computeMarketScores(farmer, crop, markets, weather, roadQuality, cropMaturity)
+	marketOptions := computeMarketScores(farmer, crop, markets, factors, quantityKg)
+	if len(marketOptions) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "no market is within range of the vehicles available in this region"})
+		return
+	}
 
 	sort.Slice(marketOptions, func(i, j int) bool {
 		return marketOptions[i].MarketScore > marketOptions[j].MarketScore
@@ -200,71 +396,120 @@ func handleRecommendation(c *gin.Context) {
 
 	bestMarket := marketOptions[0]
 
-	// ── Step 4: Confidence Bands (±10%) ──
-	confidenceMin := math.Round(bestMarket.CurrentPrice*0.90*100) / 100
-	confidenceMax := math.Round(bestMarket.CurrentPrice*1.10*100) / 100
-
-	// ── Step 5: Staggering Protocol ──
-	// Check arrival volume trend for the best market
+	// Look up the best market's arrival trend and forecast path
 	var bestTrend string
+	var bestForecast []ForecastPoint
+	var bestSurgeRatio float64
 	for _, m := range markets {
 		if m.MarketName == bestMarket.MarketName {
 			bestTrend = m.ArrivalVolumeTrend
+			bestForecast = m.Forecast
+			if m.ArrivalTrendSource == "measured" {
+				bestSurgeRatio = m.ArrivalSurgeRatio
+			}
 			break
 		}
 	}
 
+	// The optimiser plans over the whole path; the response shows forecastDays
+	planForecast := bestForecast
+	if len(bestForecast) > forecastDays {
+		bestForecast = bestForecast[:forecastDays]
+	}
+
+	// ── Step 4: Confidence Bands (next-day prediction interval) ──
+	// Width comes from the market's own forecast residuals, so volatile
+	// mandis get wider bands than stable ones.
+	confidenceMin := math.Round(bestMarket.CurrentPrice*0.90*100) / 100
+	confidenceMax := math.Round(bestMarket.CurrentPrice*1.10*100) / 100
+	if len(bestForecast) > 0 {
+		confidenceMin = bestForecast[0].Lower
+		confidenceMax = bestForecast[0].Upper
+	}
+	// Widen to the spread actually traded on the last session so the band
+	// covers the variety/grade range a farmer can realistically fetch.
+	if bestMarket.MinPrice > 0 && bestMarket.MinPrice < confidenceMin {
+		confidenceMin = bestMarket.MinPrice
+	}
+	if bestMarket.MaxPrice > confidenceMax {
+		confidenceMax = bestMarket.MaxPrice
+	}
+
+	// ── Step 5: Staggering Protocol ──
+
 	var storageOpt *StorageOption
-	action, harvestWindow, why := decideActionV2(crop, weather, soil, bestMarket, bestTrend, confidenceMin, confidenceMax)
+	action, harvestWindow, harvestDate, why := decideActionV2(crop, weather, soil, growth, bestMarket, bestTrend, confidenceMin, confidenceMax)
 
-	// If trend is HIGH → trigger staggering: find nearest cold storage
-	if bestTrend == "HIGH" {
-		action = "Delay & Store Locally"
-		storage := fetchNearestStorage(farmer.LocationLat, farmer.LocationLon)
-		storageOpt = &storage
-
-		why = fmt.Sprintf(
-			"1. Price is likely between ₹%.0f and ₹%.0f. However, due to a massive arrival surge at %s, we recommend storing at %s for ₹%.1f/kg to prevent distress sales. "+
-				"2. Current temperature (%.1f°C) with %s conditions. "+
-				"3. Once arrivals normalise, sell at %s for the best effective return (Market Score: %.0f). "+
-				"4. Storage at %s has %.0f MT capacity available at ₹%.1f/kg/day, located %.1f km from your farm.",
-			confidenceMin, confidenceMax,
-			bestMarket.MarketName,
-			storage.Name, storage.PricePerKg,
-			weather.CurrentTemp, weather.Condition,
-			bestMarket.MarketName, bestMarket.MarketScore,
-			storage.Name, storage.CapacityMT, storage.PricePerKg, storage.DistanceKm,
-		)
+	// If trend is HIGH → trigger staggering, but only when holding the load
+	// in cold storage beats selling today (see storage_optimiser.go)
+	storageCands, err := rankStorage(farmer.LocationLat, farmer.LocationLon, &crop, quantityKg,
+		todayIST(), min(len(planForecast), storageHorizonDays(crop)), defaultStorageLimit, defaultStorageRadiusKm)
+	if err != nil {
+		log.Printf("⚠ Storage ranking failed: %v", err)
+	}
+	var storageOptions []StorageOption
+	for _, cand := range storageCands {
+		storageOptions = append(storageOptions, cand.Option)
 	}
 
-	// Calculate Spoilage Risk and generate farmer trust explanation
-	factors := SpoilageFactors{
-		TemperatureCelsius: weather.CurrentTemp,
-		HumidityPercent:    weather.Humidity,
-		TransitTimeHours:   bestMarket.TransitTimeHr,
-		RoadQuality:        roadQuality,
-		CropMaturity:       cropMaturity,
+	var storagePlan *StoragePlan
+	if bestTrend == "HIGH" {
+		plan, storage := optimiseStorage(farmer, crop, factors, bestMarket, planForecast, storageCands, quantityKg)
+		storagePlan = &plan
+		log.Printf("🧊 Store-vs-sell for %s: %s (gain ₹%.0f over %d days)", crop.Name, plan.Decision, plan.ExpectedGain, plan.HoldDays)
+
+		if storage != nil {
+			action = "Delay & Store Locally"
+			storageOpt = storage
+
+			// Holding the load costs money: charge it against the recommended market
+			marketOptions[0].StorageCost = round2(plan.StorageCost + plan.HandlingCost)
+			marketOptions[0].ExpectedSpoilageKg = round2(marketOptions[0].ExpectedSpoilageKg + plan.ShrinkageKg)
+			marketOptions[0].NetReturn = plan.StoreReturn
+			bestMarket = marketOptions[0]
+
+			surge := "a massive arrival surge"
+			if bestSurgeRatio > 0 {
+				surge = fmt.Sprintf("an arrival surge (%.0f%% above the seasonal norm)", (bestSurgeRatio-1)*100)
+			}
+
+			why = fmt.Sprintf(
+				"1. Price is likely between ₹%.0f and ₹%.0f. However, due to %s at %s, we recommend storing at %s for ₹%.1f/kg to prevent distress sales. "+
+					"2. Current temperature (%.1f°C) with %s conditions. "+
+					"3. Hold for %d days and sell at %s around %s near ₹%.0f/quintal, about ₹%.0f more than selling today (Market Score: %.0f). "+
+					"4. Storage at %s has %.0f MT capacity available at ₹%.1f/kg/day, located %.1f km from your farm.",
+				confidenceMin, confidenceMax,
+				surge, bestMarket.MarketName,
+				storage.Name, storage.PricePerKg,
+				weather.CurrentTemp, weather.Condition,
+				plan.HoldDays, bestMarket.MarketName, plan.SellDate, plan.ExpectedPrice, plan.ExpectedGain, bestMarket.MarketScore,
+				storage.Name, storage.AvailableMT, storage.PricePerKg, storage.DistanceKm,
+			)
+		} else {
+			why += fmt.Sprintf(" Arrivals are surging at %s. %s", bestMarket.MarketName, plan.Reason)
+		}
 	}
-	riskLevel := CalculateSpoilageRisk(factors)
 
-	rainProb := 0
-	switch weather.Condition {
-	case "Rain", "Rain Showers", "Thunderstorm":
-		rainProb = 80
-	case "Drizzle":
-		rainProb = 50
-	case "Partly Cloudy":
-		rainProb = 20
+	// Spoilage risk follows the expected loss on the recommended plan (trip
+	// plus any storage hold) and feeds the farmer trust explanation
+	var lossPct float64
+	if quantityKg > 0 {
+		lossPct = bestMarket.ExpectedSpoilageKg / quantityKg * 100
 	}
+	riskLevel := spoilageRiskLabel(lossPct)
+
+	rainProb := rainProbabilityTomorrow(weather)
 
-	explanationStr := GenerateExplanation(bestMarket.MarketName, bestMarket.NetProfitEstimate, riskLevel, rainProb)
+	explanationStr := GenerateExplanation(bestMarket, quantityKg, riskLevel, rainProb)
 	why = explanationStr + "\n\n" + why
 
 	// ── Step 6: Localized Strings via SLM ──
 	whyLocalized := generateLocalizedStrings(why, action, crop.Name, bestMarket.MarketName, lang)
 
+	advisory := buildFieldAdvisory(crop, growthStage, areaAcres, farmer.LocationLat, soil, weather.Forecast)
+
 	// ── Step 7: Preservation Actions ──
-	preservationOptionsEn := getDynamicPreservationActions(crop.Name, riskLevel, weather, bestMarket.TransitTimeHr)
+	preservationOptionsEn := getDynamicPreservationActions(crop, riskLevel, weather, bestMarket.TransitTimeHr)
 	preservationOptions := translatePreservationActions(preservationOptionsEn, lang)
 
 	recommendation := Recommendation{
@@ -272,17 +517,46 @@ func handleRecommendation(c *gin.Context) {
 		CropName:          crop.Name,
 		Action:            action,
 		HarvestWindow:     harvestWindow,
+		HarvestDate:       harvestDate,
 		RecommendedMarket: bestMarket.MarketName,
+		QuantityKg:        quantityKg,
+		QuantitySource:    quantitySource,
 		MarketScore:       math.Round(bestMarket.MarketScore*100) / 100,
 		ConfidenceBandMin: confidenceMin,
 		ConfidenceBandMax: confidenceMax,
+		ForecastModel:     forecasterForCrop(crop.Name).Name(),
+		PriceForecast:     bestForecast,
 		Why:               whyLocalized,
 		Weather:           weather,
 		Soil:              soil,
 		Markets:           marketOptions,
 		Storage:           storageOpt,
+		StorageOptions:    storageOptions,
+		StoragePlan:       storagePlan,
 		Preservation:      preservationOptions,
-		GeneratedAt:       time.Now(),
+		Advisory:          &advisory,
+		Growth:            growth,
+		Inputs: RecommendationInputs{
+			PlotID:       plotID,
+			CropID:       cropID,
+			SowingDate:   sowingDate,
+			LocationLat:  farmer.LocationLat,
+			LocationLon:  farmer.LocationLon,
+			RoadQuality:  roadQuality,
+			CropMaturity: cropMaturity,
+			ForecastDays: forecastDays,
+			Quantity:     quantity,
+			QuantityUnit: quantityUnit,
+			GrowthStage:  growthStage,
+			AreaAcres:    areaAcres,
+			Lang:         lang,
+		},
+		GeneratedAt: time.Now(),
+	}
+
+	// ── Step 8: Persist for audit / offline history ──
+	if err := saveRecommendation(&recommendation); err != nil {
+		log.Printf("⚠ Failed to persist recommendation: %v", err)
 	}
 
 	c.JSON(http.StatusOK, recommendation)
@@ -292,219 +566,11 @@ func handleRecommendation(c *gin.Context) {
 //  DATA FETCHERS WITH FAILSAFE FALLBACKS
 // ══════════════════════════════════════════════
 
-// ── Soil Health ─────────────────────────────
-
-func fetchSoilHealth(lat, lon float64) SoilHealth {
-	// NPK are mocked deterministically based on geographical location.
-	// This ensures stable, realistic data instead of random noise every request.
-	hashLat := int(lat * 1000)
-	hashLon := int(lon * 1000)
-	geoHash := hashLat ^ hashLon
-	if geoHash < 0 {
-		geoHash = -geoHash
-	}
-
-	moisture := 15.0 + float64(geoHash%5) // Default fallback mock
-
-	// Fetch real soil moisture from Open-Meteo
-	url := fmt.Sprintf("https://api.open-meteo.com/v1/forecast?latitude=%.4f&longitude=%.4f&hourly=soil_moisture_0_to_1cm", lat, lon)
-	client := &http.Client{Timeout: 5 * time.Second}
-	if resp, err := client.Get(url); err == nil {
-		defer resp.Body.Close()
-		if resp.StatusCode == http.StatusOK {
-			var apiResp struct {
-				Hourly struct {
-					SoilMoisture []float64 `json:"soil_moisture_0_to_1cm"`
-				} `json:"hourly"`
-			}
-			if err := json.NewDecoder(resp.Body).Decode(&apiResp); err == nil && len(apiResp.Hourly.SoilMoisture) > 0 {
-				// OpenMeteo returns m³/m³, multiply by 100 for percentage
-				moisture = apiResp.Hourly.SoilMoisture[0] * 100
-				if moisture <= 0 {
-					moisture = 15.0 + float64(geoHash%5)
-				} // sanity check
-			}
-		}
-	}
-
-	status := "Good"
-	if moisture < 20.0 {
-		status = "Low Moisture - Irrigate Soon"
-	}
-
-	return SoilHealth{
-		MoisturePct: math.Round(moisture*10) / 10,
-		Nitrogen:    float64(30 + (geoHash % 25)),
-		Phosphorus:  float64(15 + ((geoHash / 10) % 15)),
-		Potassium:   float64(20 + ((geoHash / 100) % 20)),
-		Status:      status,
-	}
-}
-
-// ── Farmer ──────────────────────────────────
-
-func fetchFarmer(id string) Farmer {
-	if db != nil {
-		var f Farmer
-		err := db.Get(&f, "SELECT id, location_lat, location_lon, phone, created_at FROM farmers WHERE id = $1", id)
-		if err == nil {
-			return f
-		}
-		log.Printf("⚠ DB fetch farmer failed: %v – using fallback", err)
-	}
-	return Farmer{
-		ID:          id,
-		LocationLat: 28.6139,
-		LocationLon: 77.2090,
-		Phone:       "+919876543210",
-		CreatedAt:   time.Now(),
-	}
-}
-
-// ── Crop ────────────────────────────────────
-
-func fetchCrop(id string) Crop {
-	if db != nil {
-		var c Crop
-		err := db.Get(&c, "SELECT id, name, ideal_temp, baseline_spoilage_rate, created_at FROM crops WHERE id = $1", id)
-		if err == nil {
-			return c
-		}
-		log.Printf("⚠ DB fetch crop failed: %v – using fallback", err)
-	}
-	// Fallback dictionary for 30+ Indian crops
-	var cropDB = map[string]Crop{
-		// Vegetables
-		"c3d4e5f6-a7b8-9012-cdef-123456789012": {Name: "Tomato", IdealTemp: 25.0, BaselineSpoilageRate: 2.5},
-		"d4e5f6a7-b890-12cd-ef12-345678901234": {Name: "Onion", IdealTemp: 20.0, BaselineSpoilageRate: 1.0},
-		"e5f6a7b8-9012-cdef-1234-567890123456": {Name: "Potato", IdealTemp: 15.0, BaselineSpoilageRate: 1.5},
-		"f6a7b8c9-0123-def0-2345-678901234567": {Name: "Brinjal (Eggplant)", IdealTemp: 26.0, BaselineSpoilageRate: 2.2},
-		"a7b8c9d0-1234-ef01-3456-789012345678": {Name: "Cabbage", IdealTemp: 18.0, BaselineSpoilageRate: 2.8},
-		"b8c9d0e1-2345-f012-4567-890123456789": {Name: "Cauliflower", IdealTemp: 18.0, BaselineSpoilageRate: 3.0},
-		"c9d0e1f2-3456-0123-5678-901234567890": {Name: "Spinach", IdealTemp: 16.0, BaselineSpoilageRate: 4.5},
-		"d0e1f2a3-4567-1234-6789-012345678901": {Name: "Carrot", IdealTemp: 16.0, BaselineSpoilageRate: 1.8},
-		"e1f2a3b4-5678-2345-7890-123456789012": {Name: "Radish", IdealTemp: 15.0, BaselineSpoilageRate: 2.0},
-		"f2a3b4c5-6789-3456-8901-234567890123": {Name: "Garlic", IdealTemp: 18.0, BaselineSpoilageRate: 0.8},
-		// Fruits
-		"a3b4c5d6-7890-4567-9012-345678901234": {Name: "Apple", IdealTemp: 4.0, BaselineSpoilageRate: 1.2},
-		"b4c5d6e7-8901-5678-0123-456789012345": {Name: "Banana", IdealTemp: 14.0, BaselineSpoilageRate: 3.5},
-		"c5d6e7f8-9012-6789-1234-567890123456": {Name: "Mango", IdealTemp: 12.0, BaselineSpoilageRate: 2.8},
-		"d6e7f8a9-0123-7890-2345-678901234567": {Name: "Orange", IdealTemp: 8.0, BaselineSpoilageRate: 2.0},
-		"e7f8a9b0-1234-8901-3456-789012345678": {Name: "Grapes", IdealTemp: 2.0, BaselineSpoilageRate: 3.2},
-		"f8a9b0c1-2345-9012-4567-890123456789": {Name: "Papaya", IdealTemp: 12.0, BaselineSpoilageRate: 4.0},
-		"a9b0c1d2-3456-0123-5678-901234567890": {Name: "Guava", IdealTemp: 10.0, BaselineSpoilageRate: 2.5},
-		"b0c1d2e3-4567-1234-6789-012345678901": {Name: "Pineapple", IdealTemp: 10.0, BaselineSpoilageRate: 1.8},
-		"c1d2e3f4-5678-2345-7890-123456789012": {Name: "Pomegranate", IdealTemp: 5.0, BaselineSpoilageRate: 1.5},
-		// Cash Crops & Grains
-		"d2e3f4a5-6789-3456-8901-234567890123": {Name: "Wheat", IdealTemp: 20.0, BaselineSpoilageRate: 0.5},
-		"e3f4a5b6-7890-4567-9012-345678901234": {Name: "Rice", IdealTemp: 25.0, BaselineSpoilageRate: 0.8},
-		"f4a5b6c7-8901-5678-0123-456789012345": {Name: "Sugarcane", IdealTemp: 30.0, BaselineSpoilageRate: 2.0},
-		"a5b6c7d8-9012-6789-1234-567890123456": {Name: "Cotton", IdealTemp: 25.0, BaselineSpoilageRate: 0.4},
-		"b6c7d8e9-0123-7890-2345-678901234567": {Name: "Maize", IdealTemp: 24.0, BaselineSpoilageRate: 0.9},
-		"c7d8e9f0-1234-8901-3456-789012345678": {Name: "Tea", IdealTemp: 20.0, BaselineSpoilageRate: 1.0},
-		"d8e9f0a1-2345-9012-4567-890123456789": {Name: "Coffee", IdealTemp: 22.0, BaselineSpoilageRate: 1.2},
-		"e9f0a1b2-3456-0123-5678-901234567890": {Name: "Mustard", IdealTemp: 15.0, BaselineSpoilageRate: 0.6},
-		// Spices
-		"f0a1b2c3-4567-1234-6789-012345678901": {Name: "Ginger", IdealTemp: 15.0, BaselineSpoilageRate: 1.5},
-		"a1b2c3d4-5678-2345-7890-123456789012": {Name: "Turmeric", IdealTemp: 25.0, BaselineSpoilageRate: 0.5},
-		"b2c3d4e5-6789-3456-8901-234567890123": {Name: "Coriander", IdealTemp: 20.0, BaselineSpoilageRate: 3.5},
-		"c3d4e5f6-7890-4567-9012-345678901234": {Name: "Cumin", IdealTemp: 25.0, BaselineSpoilageRate: 0.5},
-		"d4e5f6a7-8901-5678-0123-456789012345": {Name: "Black Pepper", IdealTemp: 25.0, BaselineSpoilageRate: 0.8},
-	}
-
-	if cropData, exists := cropDB[id]; exists {
-		cropData.ID = id
-		cropData.CreatedAt = time.Now()
-		return cropData
-	}
-
-	// Default fallback to Tomato if unknown UUID
-	return Crop{
-		ID:                   id,
-		Name:                 "Unknown Crop (Default: Tomato)",
-		IdealTemp:            25.0,
-		BaselineSpoilageRate: 2.5,
-		CreatedAt:            time.Now(),
-	}
-}
-
-// ── Weather (Database Cache) ────────────────
-
-func fetchWeatherFromDB(lat, lon, idealTemp float64) WeatherInfo {
-	if db != nil {
-		var w struct {
-			Temp     float64 `db:"temp"`
-			Humidity float64 `db:"humidity"`
-		}
-		err := db.Get(&w, `
-			SELECT temp, humidity 
-			FROM weather_cache 
-			ORDER BY location <-> ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography 
-			LIMIT 1`, lon, lat)
-		if err == nil {
-			return WeatherInfo{
-				CurrentTemp: w.Temp,
-				Humidity:    w.Humidity,
-				TempDelta:   w.Temp - idealTemp,
-				Condition:   "Clear Sky", // Static for now
-			}
-		}
-		log.Printf("⚠ DB fetch weather failed: %v", err)
-	}
-
-	// Mock fallback
-	return WeatherInfo{
-		CurrentTemp: 32.4,
-		Humidity:    68.0,
-		TempDelta:   32.4 - idealTemp,
-		Condition:   "Partly Cloudy",
-	}
-}
-
-// ── Historical AI Models ────────────────────
-
 // ── Historical AI Models ────────────────────
 
-// forecastPriceTrend performs a simple linear regression over the historical prices to project a 7-day trend (pct)
-func forecastPriceTrend(prices []float64) float64 {
-	if len(prices) < 3 {
-		return 0 // Need at least 3 points for a meaningful trend
-	}
-
-	// We'll use up to 15 recent prices
-	if len(prices) > 15 {
-		prices = prices[len(prices)-15:]
-	}
-
-	n := float64(len(prices))
-	sumX, sumY, sumXY, sumX2 := 0.0, 0.0, 0.0, 0.0
-
-	for i, y := range prices {
-		x := float64(i)
-		sumX += x
-		sumY += y
-		sumXY += x * y
-		sumX2 += x * x
-	}
-
-	denom := (n*sumX2 - sumX*sumX)
-	if denom == 0 {
-		return 0
-	}
-	slope := (n*sumXY - sumX*sumY) / denom
-
-	current := prices[len(prices)-1]
-	projected := current + (slope * 7)
-
-	if current <= 0 {
-		return 0
-	}
-	pctChange := ((projected - current) / current) * 100.0
-	return pctChange
-}
-
 // calculateVolumeTrend infers arrival volume based on recent price pressure.
 // A sharp drop in price implies a HIGH arrival glut. A sharp rise implies LOW arrivals.
+// Only used when no measured arrivals are available (see fetchArrivalTrend).
 func calculateVolumeTrend(prices []float64) string {
 	if len(prices) < 5 {
 		return "NORMAL"
@@ -541,17 +607,25 @@ func calculateVolumeTrend(prices []float64) string {
 	return "NORMAL"
 }
 
-// fetchHistoricalPrices fetches chronological price slices for a given market and crop
+// priceHistoryDays bounds how much history is handed to the forecasters;
+// Holt-Winters needs at least two weekly seasons.
+const priceHistoryDays = 60
+
+// fetchHistoricalPrices fetches one modal price per trading day for a given market and crop, oldest first
 func fetchHistoricalPrices(mandiName string, cropName string) []float64 {
 	var prices []float64
 	if db != nil {
 		err := db.Select(&prices, `
-			SELECT dp.price 
-			FROM daily_prices dp
-			JOIN mandis m ON m.id = dp.mandi_id
-			WHERE m.name = $1 AND dp.crop_name = $2
-			ORDER BY dp.recorded_at ASC
-			LIMIT 15`, mandiName, cropName)
+			SELECT price FROM (
+				SELECT dp.arrival_date, AVG(dp.price) AS price
+				FROM daily_prices dp
+				JOIN mandis m ON m.id = dp.mandi_id
+				WHERE m.name = $1 AND dp.crop_name = $2
+				GROUP BY dp.arrival_date
+				ORDER BY dp.arrival_date DESC
+				LIMIT $3
+			) recent
+			ORDER BY arrival_date ASC`, mandiName, cropName, priceHistoryDays)
 		if err == nil {
 			return prices
 		}
@@ -561,21 +635,38 @@ func fetchHistoricalPrices(mandiName string, cropName string) []float64 {
 
 // ── Market Prices (PostGIS Cache) ─────────────
 
-func fetchMarketPricesFromDB(cropID string, cropName string, lat, lon float64) []MandiPrice {
+func fetchMarketPricesFromDB(cropID string, cropName string, lat, lon float64, horizon int) []MandiPrice {
 	if db != nil {
 		type result struct {
 			MarketName string    `db:"market_name"`
 			Price      float64   `db:"price"`
+			MinPrice   float64   `db:"min_price"`
+			MaxPrice   float64   `db:"max_price"`
 			Lat        float64   `db:"lat"`
 			Lon        float64   `db:"lon"`
 			RecordedAt time.Time `db:"recorded_at"`
 		}
+		// Latest trading day per mandi; varieties traded that day are
+		// averaged (modal) and their min/max spread kept. Mandis without a
+		// verified location are skipped – their distances would be fiction.
 		var rows []result
 		err := db.Select(&rows, `
-			SELECT m.name as market_name, dp.price, ST_Y(m.location::geometry) as lat, ST_X(m.location::geometry) as lon, dp.recorded_at
-			FROM mandis m
-			JOIN daily_prices dp ON dp.mandi_id = m.id
-			WHERE dp.crop_name = $1
+			WITH latest AS (
+				SELECT mandi_id, MAX(arrival_date) AS d
+				FROM daily_prices
+				WHERE crop_name = $1
+				GROUP BY mandi_id
+			)
+			SELECT m.name AS market_name,
+			       AVG(dp.price) AS price,
+			       MIN(COALESCE(dp.min_price, dp.price)) AS min_price,
+			       MAX(COALESCE(dp.max_price, dp.price)) AS max_price,
+			       ST_Y(m.location::geometry) AS lat, ST_X(m.location::geometry) AS lon,
+			       MAX(dp.recorded_at) AS recorded_at
+			FROM latest l
+			JOIN mandis m ON m.id = l.mandi_id AND m.location_resolved
+			JOIN daily_prices dp ON dp.mandi_id = l.mandi_id AND dp.crop_name = $1 AND dp.arrival_date = l.d
+			GROUP BY m.id
 			ORDER BY m.location <-> ST_SetSRID(ST_MakePoint($3, $2), 4326)::geography
 			LIMIT 10`, cropName, lat, lon)
 
@@ -587,15 +678,28 @@ func fetchMarketPricesFromDB(cropID string, cropName string, lat, lon float64) [
 					pricesList = []float64{r.Price} // Fallback to at least current payload price
 				}
 
+				trendPct, forecast := forecastPrices(cropName, pricesList, horizon)
+
+				// Prefer measured arrivals; price pressure is only a proxy.
+				volumeTrend, trendSource, surgeRatio := calculateVolumeTrend(pricesList), "price_inferred", 0.0
+				if arrivals, ok := fetchArrivalTrend(r.MarketName, cropName); ok {
+					volumeTrend, trendSource, surgeRatio = arrivals.Trend, "measured", math.Round(arrivals.Ratio()*100)/100
+				}
+
 				prices = append(prices, MandiPrice{
 					ID:                 fmt.Sprintf("db-%d", i+1),
 					MarketName:         r.MarketName,
 					CropID:             cropID,
 					CurrentPrice:       r.Price,
+					MinPrice:           r.MinPrice,
+					MaxPrice:           r.MaxPrice,
 					MarketLat:          r.Lat,
 					MarketLon:          r.Lon,
-					ArrivalVolumeTrend: calculateVolumeTrend(pricesList),
-					PriceTrendPct:      math.Round(forecastPriceTrend(pricesList)*100) / 100,
+					ArrivalVolumeTrend: volumeTrend,
+					ArrivalTrendSource: trendSource,
+					ArrivalSurgeRatio:  surgeRatio,
+					PriceTrendPct:      trendPct,
+					Forecast:           forecast,
 					Timestamp:          r.RecordedAt,
 				})
 			}
@@ -611,172 +715,20 @@ func fetchMarketPricesFromDB(cropID string, cropName string, lat, lon float64) [
 	m3Hist := []float64{2500, 2480, 2420, 2380, 2350} // Dropping
 	m4Hist := []float64{2600, 2610, 2630, 2640, 2650}
 
-	return []MandiPrice{
-		{ID: "m1", MarketName: "Azadpur Mandi", CropID: cropID, CurrentPrice: 2500, MarketLat: 28.7041, MarketLon: 77.1525, ArrivalVolumeTrend: calculateVolumeTrend(m1Hist), PriceTrendPct: math.Round(forecastPriceTrend(m1Hist)*100) / 100, Timestamp: now},
-		{ID: "m2", MarketName: "Vashi APMC", CropID: cropID, CurrentPrice: 2800, MarketLat: 19.0728, MarketLon: 73.0169, ArrivalVolumeTrend: calculateVolumeTrend(m2Hist), PriceTrendPct: math.Round(forecastPriceTrend(m2Hist)*100) / 100, Timestamp: now},
-		{ID: "m3", MarketName: "Ghazipur Mandi", CropID: cropID, CurrentPrice: 2350, MarketLat: 28.6233, MarketLon: 77.3230, ArrivalVolumeTrend: calculateVolumeTrend(m3Hist), PriceTrendPct: math.Round(forecastPriceTrend(m3Hist)*100) / 100, Timestamp: now},
-		{ID: "m4", MarketName: "Pune APMC", CropID: cropID, CurrentPrice: 2650, MarketLat: 18.5204, MarketLon: 73.8567, ArrivalVolumeTrend: calculateVolumeTrend(m4Hist), PriceTrendPct: math.Round(forecastPriceTrend(m4Hist)*100) / 100, Timestamp: now},
-	}
-}
-
-// LiveMandiRecord represents a single record from the data.gov.in API.
-type LiveMandiRecord struct {
-	Market     string  `json:"market"`
-	Commodity  string  `json:"commodity"`
-	ModalPrice float64 `json:"modal_price"`
-	State      string  `json:"state"`
-	District   string  `json:"district"`
-}
-
-// getCoordinatesForMarket provides a static mapping of market names to coordinates.
-func getCoordinatesForMarket(market, state string) (float64, float64) {
-	dict := map[string][]float64{
-		"Azadpur":       {28.7041, 77.1525},
-		"Ghazipur":      {28.6233, 77.3230},
-		"Narela":        {28.8526, 77.0932},
-		"Vashi":         {19.0728, 73.0169},
-		"Pune":          {18.5204, 73.8567},
-		"Nashik":        {20.0059, 73.7900},
-		"Doharighat":    {26.2736, 83.5822},
-		"Kolar":         {13.1367, 78.1292},
-		"Chittoor":      {13.2172, 79.1003},
-		"Delhi":         {28.6139, 77.2090},
-		"Maharashtra":   {19.7515, 75.7139},
-		"Uttar Pradesh": {26.8467, 80.9462},
-		"Gujarat":       {22.2587, 71.1924},
-		"Karnataka":     {15.3173, 75.7139},
-	}
-
-	if coords, ok := dict[market]; ok {
-		return coords[0], coords[1]
-	}
-	if coords, ok := dict[state]; ok {
-		return coords[0] + 0.1, coords[1] + 0.1 // Slight perturbation to avoid exact duplicates
-	}
-	// Fallback random perturbation around central India to ensure variance
-	randOffset := float64(len(market)) / 100.0
-	return 28.6139 + randOffset, 77.2090 + randOffset
-}
-
-// fetchLiveMandiPrices fetches live mandi prices from data.gov.in.
-func fetchLiveMandiPrices(apiKey string, cropName string) ([]LiveMandiRecord, error) {
-	url := fmt.Sprintf(
-		"https://api.data.gov.in/resource/9ef84268-d588-465a-a308-a864a43d0070?api-key=%s&format=json&filters[commodity]=%s&sort[arrival_date]=desc&limit=10",
-		apiKey, cropName,
-	)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("data.gov.in request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("data.gov.in returned status %d", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	// The API returns: { "records": [ { "market": "...", "modal_price": "...", ... } ] }
-	var apiResp struct {
-		Records []struct {
-			Market     string  `json:"market"`
-			Commodity  string  `json:"commodity"`
-			ModalPrice float64 `json:"modal_price"`
-			State      string  `json:"state"`
-			District   string  `json:"district"`
-		} `json:"records"`
-	}
-
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse data.gov.in JSON: %w", err)
-	}
-
-	var records []LiveMandiRecord
-	for _, r := range apiResp.Records {
-		// modal_price is per Quintal (100kg), divide by 100 for price_per_kg
-		records = append(records, LiveMandiRecord{
-			Market:     r.Market,
-			Commodity:  r.Commodity,
-			ModalPrice: r.ModalPrice, // keep as per quintal to match our CurrentPrice field
-			State:      r.State,
-			District:   r.District,
-		})
-	}
-
-	return records, nil
-}
-
-// ── Storage Facilities ──────────────────────
-
-func fetchNearestStorage(farmerLat, farmerLon float64) StorageOption {
-	if db != nil {
-		var facilities []StorageFacility
-		err := db.Select(&facilities, "SELECT id, name, location_lat, location_lon, capacity_mt, price_per_kg FROM storage_facilities")
-		if err == nil && len(facilities) > 0 {
-			// Find nearest by haversine
-			bestIdx := 0
-			bestDist := math.MaxFloat64
-			for i, f := range facilities {
-				d := haversine(farmerLat, farmerLon, f.LocationLat, f.LocationLon)
-				if d < bestDist {
-					bestDist = d
-					bestIdx = i
-				}
-			}
-			f := facilities[bestIdx]
-			return StorageOption{
-				Name:       f.Name,
-				DistanceKm: math.Round(bestDist*10) / 10,
-				PricePerKg: f.PricePerKg,
-				CapacityMT: f.CapacityMT,
-			}
-		}
-		log.Printf("⚠ DB fetch storage failed: %v – using fallback", err)
+	fallback := []MandiPrice{
+		{ID: "m1", MarketName: "Azadpur Mandi", CropID: cropID, CurrentPrice: 2500, MarketLat: 28.7041, MarketLon: 77.1525, ArrivalVolumeTrend: calculateVolumeTrend(m1Hist), Timestamp: now},
+		{ID: "m2", MarketName: "Vashi APMC", CropID: cropID, CurrentPrice: 2800, MarketLat: 19.0728, MarketLon: 73.0169, ArrivalVolumeTrend: calculateVolumeTrend(m2Hist), Timestamp: now},
+		{ID: "m3", MarketName: "Ghazipur Mandi", CropID: cropID, CurrentPrice: 2350, MarketLat: 28.6233, MarketLon: 77.3230, ArrivalVolumeTrend: calculateVolumeTrend(m3Hist), Timestamp: now},
+		{ID: "m4", MarketName: "Pune APMC", CropID: cropID, CurrentPrice: 2650, MarketLat: 18.5204, MarketLon: 73.8567, ArrivalVolumeTrend: calculateVolumeTrend(m4Hist), Timestamp: now},
 	}
-	// FALLBACK: realistic cold storage near Delhi
-	dist := haversine(farmerLat, farmerLon, 28.8526, 77.0932)
-	return StorageOption{
-		Name:       "Narela Cold Storage",
-		DistanceKm: math.Round(dist*10) / 10,
-		PricePerKg: 2.0,
-		CapacityMT: 500.0,
+	for i, hist := range [][]float64{m1Hist, m2Hist, m3Hist, m4Hist} {
+		fallback[i].ArrivalTrendSource = "price_inferred"
+		fallback[i].PriceTrendPct, fallback[i].Forecast = forecastPrices(cropName, hist, horizon)
 	}
+	return fallback
 }
 
-// ── Transit Time (OSRM) ────────────────────
-
-func fetchTransitTime(farmerLat, farmerLon, marketLat, marketLon float64) float64 {
-	url := fmt.Sprintf(
-		"http://router.project-osrm.org/route/v1/driving/%.4f,%.4f;%.4f,%.4f?overview=false",
-		farmerLon, farmerLat, marketLon, marketLat,
-	)
-
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(url)
-	if err == nil && resp.StatusCode == http.StatusOK {
-		defer resp.Body.Close()
-		body, readErr := io.ReadAll(resp.Body)
-		if readErr == nil {
-			var result struct {
-				Routes []struct {
-					Duration float64 `json:"duration"`
-				} `json:"routes"`
-			}
-			if json.Unmarshal(body, &result) == nil && len(result.Routes) > 0 {
-				return result.Routes[0].Duration / 3600.0
-			}
-		}
-	}
-
-	log.Printf("⚠ OSRM API failed – using haversine fallback")
-	dist := haversine(farmerLat, farmerLon, marketLat, marketLon)
-	return dist / 40.0
-}
+// ── Distance ────────────────────────────────
 
 func haversine(lat1, lon1, lat2, lon2 float64) float64 {
 	const R = 6371.0
@@ -793,81 +745,74 @@ func haversine(lat1, lon1, lat2, lon2 float64) float64 {
 //  SCORING & DECISION ENGINE (Phase 2)
 // ══════════════════════════════════════════════
 
-func CalculateSpoilageRisk(factors SpoilageFactors) string {
-	effectiveTransit := factors.TransitTimeHours
-	if factors.RoadQuality == "unpaved" {
-		effectiveTransit *= 1.8
-	}
-
-	effectiveTemp := factors.TemperatureCelsius
-	if factors.CropMaturity == "Late" {
-		effectiveTemp += 5.0
-	}
-
-	if effectiveTemp > 35 && effectiveTransit > 10 {
-		return "HIGH"
-	}
-	if effectiveTemp > 30 || effectiveTransit > 5 {
-		return "MEDIUM"
-	}
-	return "LOW"
-}
-
-func GenerateExplanation(marketName string, netProfitPerKg float64, riskLevel string, rainProb int) string {
-	return fmt.Sprintf("Sell at %s. It offers ₹%.2f/kg more after transport costs. Spoilage risk during transit is %s. Weather context: %d%% chance of rain tomorrow.",
-		marketName, netProfitPerKg, riskLevel, rainProb)
+func GenerateExplanation(best MarketOption, quantityKg float64, riskLevel string, rainProb int) string {
+	return fmt.Sprintf("Sell at %s. Your %.1f quintals should bring ₹%.0f in hand after ₹%.0f transport and about %.0f kg of spoilage. Spoilage risk is %s. Weather context: %d%% chance of rain tomorrow.",
+		best.MarketName, quantityKg/100, best.NetReturn, best.TotalTransportCost, best.ExpectedSpoilageKg, riskLevel, rainProb)
 }
 
-func computeMarketScores(farmer Farmer, crop Crop, markets []MandiPrice, weather WeatherInfo, roadQuality string, cropMaturity string) []MarketOption {
+// computeMarketScores prices the trip to every market with the vehicle that
+// leaves the most per quintal; factors carries the weather, road and crop
+// condition the spoilage model needs (transit time and cold chain are set
+// per vehicle). Markets beyond every vehicle's range are left out.
+func computeMarketScores(farmer Farmer, crop Crop, markets []MandiPrice, factors SpoilageFactors, quantityKg float64) []MarketOption {
 	options := make([]MarketOption, 0, len(markets))
 
-	type transitResult struct {
-		idx      int
-		duration float64
-	}
-	results := make(chan transitResult, len(markets))
+	// One routing call for every market (see routing.go)
+	dests := make([][2]float64, len(markets))
 	for i, m := range markets {
-		go func(idx int, mkt MandiPrice) {
-			dur := fetchTransitTime(farmer.LocationLat, farmer.LocationLon, mkt.MarketLat, mkt.MarketLon)
-			results <- transitResult{idx: idx, duration: dur}
-		}(i, m)
+		dests[i] = [2]float64{m.MarketLat, m.MarketLon}
 	}
+	transit := fetchTransitMatrix(farmer.LocationLat, farmer.LocationLon, dests)
 
-	transitTimes := make([]float64, len(markets))
-	for range markets {
-		r := <-results
-		transitTimes[r.idx] = r.duration
-	}
+	profiles := fetchVehicleProfiles(farmer.LocationLat, farmer.LocationLon)
 
 	for i, m := range markets {
-		transitHr := transitTimes[i]
-		if roadQuality == "unpaved" {
-			transitHr *= 1.8 // Vibration bruising penalty
+		// Pick the vehicle that leaves the most per quintal after spoilage
+		// and hire costs; a reefer trades a higher rate for a cold chain.
+		var (
+			transport      TransportBreakdown
+			transitHr      float64
+			spoilagePct    float64
+			effectivePrice float64
+			bestNet        = math.Inf(-1)
+		)
+		for _, p := range profiles {
+			if p.MaxRangeKm > 0 && transit[i].DistanceKm > p.MaxRangeKm {
+				continue
+			}
+			quote, hours := quoteTransport(p, quantityKg, transit[i])
+			trip := factors
+			trip.TransitTimeHours, trip.Refrigerated = hours, p.Refrigerated
+			spoil := estimateSpoilage(crop, trip).TransitPct
+			eff := m.CurrentPrice * (1 - spoil/100.0)
+			if net := eff - quote.PerQuintal; net > bestNet {
+				bestNet, transport, transitHr, spoilagePct, effectivePrice = net, quote, hours, spoil, eff
+			}
 		}
-
-		tempFactor := 1.0 + math.Abs(weather.TempDelta)/10.0
-		if cropMaturity == "Late" {
-			tempFactor *= 2.0 // Late harvest decays twice as fast from ambient heat
+		if math.IsInf(bestNet, -1) {
+			log.Printf("🚚 %s is %.0f km away, beyond every vehicle's range – skipped", m.MarketName, transit[i].DistanceKm)
+			continue
 		}
 
-		spoilagePct := crop.BaselineSpoilageRate * transitHr * tempFactor
-		transportPenalty := transitTimes[i] * 50.0 // Standard hr cost
-		effectivePrice := m.CurrentPrice * (1 - spoilagePct/100.0)
+		transportPenalty := transport.PerQuintal
 		score := effectivePrice - transportPenalty
 
-		// Distance via haversine
-		distKm := haversine(farmer.LocationLat, farmer.LocationLon, m.MarketLat, m.MarketLon)
+		// Road distance when routed, straight line otherwise
+		distKm := transit[i].DistanceKm
 
 		// Net profit estimate: effective price minus transport cost
 		netProfit := effectivePrice - transportPenalty
+		priceAdj := 1.0 // expected deviation of the realised price from the quote
 
 		// Penalize HIGH arrival volume markets (glut discount)
 		if m.ArrivalVolumeTrend == "HIGH" {
 			score *= 0.85 // 15% penalty for oversupply risk
 			netProfit *= 0.85
+			priceAdj *= 0.85
 		} else if m.ArrivalVolumeTrend == "LOW" {
 			score *= 1.05 // 5% bonus for undersupply opportunity
 			netProfit *= 1.05
+			priceAdj *= 1.05
 		}
 
 		// ── PHASE 7: Ground Truth Confidence Aggregation ──
@@ -890,17 +835,33 @@ func computeMarketScores(farmer Farmer, crop Crop, markets []MandiPrice, weather
 			// Override Official scores using the Crowd Truth variance
 			score *= varianceRatio
 			netProfit *= varianceRatio
+			priceAdj *= varianceRatio
 		}
 
+		// Money in pocket for the whole harvest
+		spoiledKg := quantityKg * math.Min(spoilagePct, 100) / 100
+		revenue := (quantityKg - spoiledKg) / 100 * m.CurrentPrice * priceAdj
+
 		options = append(options, MarketOption{
 			MarketName:         m.MarketName,
 			CurrentPrice:       m.CurrentPrice,
+			MinPrice:           m.MinPrice,
+			MaxPrice:           m.MaxPrice,
 			DistanceKm:         math.Round(distKm*100) / 100,
 			TransitTimeHr:      math.Round(transitHr*100) / 100,
+			TransitSource:      transit[i].Source,
 			SpoilageLoss:       math.Round(spoilagePct*100) / 100,
+			TransportCost:      math.Round(transportPenalty*100) / 100,
+			Vehicle:            transport.Vehicle,
+			TransportBreakdown: transport,
 			NetProfitEstimate:  math.Round(netProfit*100) / 100,
+			ExpectedSpoilageKg: round2(spoiledKg),
+			TotalRevenue:       round2(revenue),
+			TotalTransportCost: transport.Total,
+			NetReturn:          round2(revenue - transport.Total),
 			MarketScore:        math.Round(score*100) / 100,
 			ArrivalVolumeTrend: m.ArrivalVolumeTrend,
+			ArrivalTrendSource: m.ArrivalTrendSource,
 			PriceTrendPct:      m.PriceTrendPct,
 		})
 	}
@@ -908,55 +869,105 @@ func computeMarketScores(farmer Farmer, crop Crop, markets []MandiPrice, weather
 	return options
 }
 
-func decideActionV2(crop Crop, weather WeatherInfo, soil SoilHealth, best MarketOption, trend string, cbMin, cbMax float64) (string, string, string) {
+func decideActionV2(crop Crop, weather WeatherInfo, soil SoilHealth, growth *GrowthStatus, best MarketOption, trend string, cbMin, cbMax float64) (string, string, string, string) {
 	action := "Sell at Mandi"
 	harvestWindow := "Harvest Today"
+	harvestDate := "" // set when the window comes from the daily forecast
 	var reasons []string
 
 	// Price Forecast logic (replacing hallucinated text)
+	model := forecasterLabel(crop.Name)
 	if best.PriceTrendPct > 2.0 {
 		reasons = append(reasons,
-			fmt.Sprintf("Our regression model projects a +%.1f%% price increase over the next 7 days at %s.", best.PriceTrendPct, best.MarketName))
+			fmt.Sprintf("Our %s model projects a +%.1f%% price increase over the next %d days at %s.", model, best.PriceTrendPct, forecastHorizonDays, best.MarketName))
 		if best.TransitTimeHr < 5 && weather.TempDelta < 5 { // Safe to wait
 			action = "Wait"
 			harvestWindow = "Delay Harvest (3-5 Days)"
 		}
 	} else if best.PriceTrendPct < -2.0 {
 		reasons = append(reasons,
-			fmt.Sprintf("Our model projects a %.1f%% price drop over the next 7 days at %s. Selling immediately is advised to lock in profits.", best.PriceTrendPct, best.MarketName))
+			fmt.Sprintf("Our %s model projects a %.1f%% price drop over the next %d days at %s. Selling immediately is advised to lock in profits.", model, best.PriceTrendPct, forecastHorizonDays, best.MarketName))
 	} else {
 		reasons = append(reasons,
-			fmt.Sprintf("Prices at %s are projected to remain relatively stable (%.1f%% change) over the next week. Recommended price band: ₹%.0f to ₹%.0f.", best.MarketName, best.PriceTrendPct, cbMin, cbMax))
+			fmt.Sprintf("Prices at %s are projected by our %s model to remain relatively stable (%.1f%% change) over the next %d days. Recommended price band: ₹%.0f to ₹%.0f.", best.MarketName, model, best.PriceTrendPct, forecastHorizonDays, cbMin, cbMax))
 	}
 
-	// Soil & Temperature analysis for Harvest Window
-	if soil.MoisturePct < 20 {
-		harvestWindow = "Harvest Today"
-		reasons = append(reasons,
-			fmt.Sprintf("Soil moisture is critically low (%.1f%%). Harvest immediately to prevent wilting and preserve crop weight.", soil.MoisturePct))
-	} else if math.Abs(weather.TempDelta) <= 5 {
-		if action != "Wait" {
-			harvestWindow = "Optimal: Next 2-3 Days"
+	// Crop readiness from growing degree days (see growth.go)
+	if growth != nil {
+		switch growth.Maturity {
+		case "Early":
+			action = "Wait"
+			reasons = append(reasons,
+				fmt.Sprintf("Your %s has built up %.0f of the %.0f growing degree days it needs (%s stage) and should be ready around %s, in about %d days.",
+					crop.Name, growth.GDDAccumulated, growth.GDDToMaturity, growth.Stage, growth.ExpectedHarvestDate, growth.DaysToHarvest))
+		case "Late":
+			if action == "Wait" {
+				action = "Sell at Mandi" // over-ripe produce cannot wait for a rally
+			}
+			reasons = append(reasons,
+				fmt.Sprintf("Your %s reached maturity about %d days ago. Harvest at the first dry day: over-ripe produce spoils faster in transit.",
+					crop.Name, -growth.DaysToHarvest))
 		}
-		reasons = append(reasons,
-			fmt.Sprintf("Current temperature (%.1f°C) is close to the ideal %.1f°C for %s with good soil moisture (%.1f%%).",
-				weather.CurrentTemp, crop.IdealTemp, crop.Name, soil.MoisturePct))
-	} else if weather.TempDelta > 5 {
-		if action != "Wait" {
+	}
+
+	if growth != nil && growth.Maturity == "Early" {
+		harvestWindow = fmt.Sprintf("Not Ready: Harvest in ~%d Days", growth.DaysToHarvest)
+		harvestDate = growth.ExpectedHarvestDate
+	} else {
+		// Soil & Temperature analysis for Harvest Window
+		if soil.MoisturePct < 20 {
 			harvestWindow = "Harvest Today"
-			action = "Sell at Mandi"
+			reasons = append(reasons,
+				fmt.Sprintf("Soil moisture is critically low (%.1f%%). Harvest immediately to prevent wilting and preserve crop weight.", soil.MoisturePct))
+		} else if math.Abs(weather.TempDelta) <= 5 {
+			if action != "Wait" {
+				harvestWindow = "Optimal: Next 2-3 Days"
+			}
+			reasons = append(reasons,
+				fmt.Sprintf("Current temperature (%.1f°C) is close to the ideal %.1f°C for %s with good soil moisture (%.1f%%).",
+					weather.CurrentTemp, crop.IdealTemp, crop.Name, soil.MoisturePct))
+		} else if weather.TempDelta > 5 {
+			if action != "Wait" {
+				harvestWindow = "Harvest Today"
+				action = "Sell at Mandi"
+			}
+			reasons = append(reasons,
+				fmt.Sprintf("It is %.1f°C hotter than ideal for %s. Harvesting sooner reduces heat-related spoilage.",
+					weather.TempDelta, crop.Name))
+		} else {
+			if action != "Sell at Mandi" {
+				action = "Wait"
+				harvestWindow = "Delay Harvest (4-7 Days)"
+			}
+			reasons = append(reasons,
+				fmt.Sprintf("Temperatures are %.1f°C below ideal for %s. Waiting for warmer conditions may improve quality.",
+					math.Abs(weather.TempDelta), crop.Name))
 		}
-		reasons = append(reasons,
-			fmt.Sprintf("It is %.1f°C hotter than ideal for %s. Harvesting sooner reduces heat-related spoilage.",
-				weather.TempDelta, crop.Name))
-	} else {
-		if action != "Sell at Mandi" {
-			action = "Wait"
-			harvestWindow = "Delay Harvest (4-7 Days)"
+
+		// Harvest window from the daily forecast: best dry, near-ideal day.
+		// Critically dry soil still means harvesting today.
+		if len(weather.Forecast) > 0 && soil.MoisturePct >= 20 {
+			earliest := 0
+			if action == "Wait" && best.PriceTrendPct > 2.0 {
+				earliest = min(3, len(weather.Forecast)-1) // let the projected rally play out
+			}
+			if growth != nil && growth.DaysToHarvest > earliest {
+				earliest = min(growth.DaysToHarvest, len(weather.Forecast)-1) // not before the crop is ready
+			}
+			if day, ok := pickHarvestDay(weather.Forecast, crop.IdealTemp, earliest); ok {
+				f := weather.Forecast[day]
+				harvestWindow = harvestDayLabel(day, f)
+				harvestDate = f.Date
+				reasons = append(reasons,
+					fmt.Sprintf("Best harvest day in the %d-day forecast is %s: %d%% chance of rain, %.0f–%.0f°C, wind up to %.0f km/h.",
+						len(weather.Forecast), f.Date, f.PrecipProbPct, f.TempMinC, f.TempMaxC, f.WindMaxKmh))
+			} else {
+				harvestWindow = "Wait for a Dry Spell"
+				reasons = append(reasons,
+					fmt.Sprintf("No dry harvest day in the %d-day forecast (rain chance above %d%% or strong wind every day). Harvest only what you can keep under cover.",
+						len(weather.Forecast), harvestMaxRainProbPct))
+			}
 		}
-		reasons = append(reasons,
-			fmt.Sprintf("Temperatures are %.1f°C below ideal for %s. Waiting for warmer conditions may improve quality.",
-				math.Abs(weather.TempDelta), crop.Name))
 	}
 
 	// Market analysis
@@ -991,10 +1002,31 @@ func decideActionV2(crop Crop, weather WeatherInfo, soil SoilHealth, best Market
 		why += fmt.Sprintf("%d. %s\n", i+1, r)
 	}
 
-	return action, harvestWindow, why
+	return action, harvestWindow, harvestDate, why
+}
+
+// weatherThreatActions is the protective action for each weather threat,
+// shared by the preservation engine (current conditions) and the alert job
+// (forecast threats).
+var weatherThreatActions = map[string]PreservationAction{
+	"heavy_rain": {
+		ActionName:    "Cover with Heavy-Duty Tarpaulin",
+		CostEstimate:  "₹300/trip",
+		Effectiveness: "High (Prevents waterlogging)",
+	},
+	"heatwave": {
+		ActionName:    "Harvest at Dawn & Use Reflective Thermal Covers",
+		CostEstimate:  "₹500/trip",
+		Effectiveness: "High (Keeps field and sun heat out of the load)",
+	},
+	"frost": {
+		ActionName:    "Cover Beds with Frost Cloth & Irrigate Lightly at Dusk",
+		CostEstimate:  "₹800/acre",
+		Effectiveness: "High (Holds ground heat overnight)",
+	},
 }
 
-func getDynamicPreservationActions(cropName string, riskLevel string, weather WeatherInfo, transitHrs float64) []PreservationAction {
+func getDynamicPreservationActions(crop Crop, riskLevel string, weather WeatherInfo, transitHrs float64) []PreservationAction {
 	var actions []PreservationAction
 
 	// Base actions based on risk level
@@ -1008,27 +1040,22 @@ func getDynamicPreservationActions(cropName string, riskLevel string, weather We
 
 	// Weather based actions
 	if weather.Condition == "Rain" || weather.Condition == "Rain Showers" || weather.Condition == "Thunderstorm" {
-		actions = append(actions, PreservationAction{
-			ActionName:    "Cover with Heavy-Duty Tarpaulin",
-			CostEstimate:  "₹300/trip",
-			Effectiveness: "High (Prevents waterlogging)",
-		})
+		actions = append(actions, weatherThreatActions["heavy_rain"])
 	} else if weather.CurrentTemp > 35 {
-		actions = append(actions, PreservationAction{
-			ActionName:    "Use Reflective Thermal Covers",
-			CostEstimate:  "₹500/trip",
-			Effectiveness: "Medium (Reduces heat absorption)",
-		})
+		actions = append(actions, weatherThreatActions["heatwave"])
+	} else if weather.CurrentTemp <= 4 {
+		actions = append(actions, weatherThreatActions["frost"])
 	}
 
-	// Crop specific actions
-	if cropName == "Tomato" {
+	// Crop category actions
+	switch {
+	case (crop.Category == "vegetable" || crop.Category == "fruit") && crop.ShelfLifeDays <= 14:
 		actions = append(actions, PreservationAction{
 			ActionName:    "Use Ventilated Plastic Crates instead of Sacks",
 			CostEstimate:  "₹50/crate",
 			Effectiveness: "High (Prevents 80% crushing)",
 		})
-	} else if cropName == "Onion" || cropName == "Potato" {
+	case crop.Category == "bulb" || crop.Category == "root_tuber":
 		actions = append(actions, PreservationAction{
 			ActionName:    "Ensure Dry Jute Bags / Mesh Sacks",
 			CostEstimate:  "₹20/bag",
@@ -1036,6 +1063,15 @@ func getDynamicPreservationActions(cropName string, riskLevel string, weather We
 		})
 	}
 
+	// Humidity beyond what the crop tolerates
+	if crop.HumidityMaxPct > 0 && weather.Humidity > crop.HumidityMaxPct {
+		actions = append(actions, PreservationAction{
+			ActionName:    "Pack in Moisture-proof Hermetic Bags",
+			CostEstimate:  "₹80/bag",
+			Effectiveness: "High (Blocks moisture uptake)",
+		})
+	}
+
 	// Transit time based actions
 	if transitHrs > 8 {
 		actions = append(actions, PreservationAction{
@@ -1249,8 +1285,16 @@ func handleChat(c *gin.Context) {
 		return
 	}
 
-	farmer := fetchFarmer(req.FarmerID)
-	crop := fetchCrop(req.CropID)
+	farmer, err := fetchFarmer(req.FarmerID)
+	if err != nil {
+		respondFarmerError(c, req.FarmerID, err)
+		return
+	}
+	crop, err := fetchCrop(req.CropID)
+	if err != nil {
+		respondCropError(c, req.CropID, err)
+		return
+	}
 
 	langCode := req.Lang
 	if langCode == "" {
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// ══════════════════════════════════════════════
+//  MANDI REGISTRY (Verified Locations)
+// ══════════════════════════════════════════════
+
+const mandiColumns = `m.id, m.name, m.state, m.district, m.state_code, m.district_code,
+	ST_Y(m.location::geometry) AS lat, ST_X(m.location::geometry) AS lon,
+	m.location_resolved, m.location_source, m.created_at`
+
+// ensureMandi returns the registry ID for an ingested market, registering it
+// as unresolved when seen for the first time. Coordinates are never guessed:
+// a wrong point silently corrupts every distance and route.
+func ensureMandi(db *sqlx.DB, lp LivePrice) (int, error) {
+	var id int
+	err := db.QueryRow(`
+		INSERT INTO mandis (name, state, district)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET
+			state    = CASE WHEN mandis.state = '' THEN EXCLUDED.state ELSE mandis.state END,
+			district = CASE WHEN mandis.district = '' THEN EXCLUDED.district ELSE mandis.district END
+		RETURNING id`, lp.Market, lp.State, lp.District).Scan(&id)
+	return id, err
+}
+
+// ── Gazetteer Import (`go run . import-mandis`) ──
+
+// gazetteerEntry is one verified mandi location from a CSV or GeoJSON file.
+type gazetteerEntry struct {
+	Name         string
+	State        string
+	District     string
+	StateCode    string
+	DistrictCode string
+	Lat          float64
+	Lon          float64
+}
+
+func (g gazetteerEntry) validate() error {
+	if strings.TrimSpace(g.Name) == "" {
+		return errors.New("name is required")
+	}
+	if g.Lat == 0 && g.Lon == 0 {
+		return errors.New("coordinates are missing")
+	}
+	return validateLocation(g.Lat, g.Lon)
+}
+
+// runImportMandis upserts verified coordinates and state/district codes.
+// Matching is on the Agmarknet market name, so the file's names must use the
+// same spelling as the data.gov.in feed.
+func runImportMandis(args []string) error {
+	fs := flag.NewFlagSet("import-mandis", flag.ExitOnError)
+	file := fs.String("file", "", "gazetteer file to import (.csv, .geojson or .json)")
+	format := fs.String("format", "", "csv or geojson (default: from file extension)")
+	source := fs.String("source", "", "provenance label stored with each location (default: file name)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if db == nil {
+		return errors.New("DATABASE_URL is required for import-mandis")
+	}
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(*file)) {
+		case ".csv":
+			*format = "csv"
+		case ".geojson", ".json":
+			*format = "geojson"
+		default:
+			return errors.New("cannot infer format from extension; pass -format csv|geojson")
+		}
+	}
+	if *source == "" {
+		*source = filepath.Base(*file)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var entries []gazetteerEntry
+	switch *format {
+	case "csv":
+		entries, err = parseGazetteerCSV(f)
+	case "geojson":
+		entries, err = parseGazetteerGeoJSON(f)
+	default:
+		return fmt.Errorf("unknown format %q (expected csv or geojson)", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	imported, skipped := 0, 0
+	for i, e := range entries {
+		if err := e.validate(); err != nil {
+			log.Printf("[import] entry %d (%q) skipped: %v", i+1, e.Name, err)
+			skipped++
+			continue
+		}
+		_, err := db.Exec(`
+			INSERT INTO mandis (name, state, district, state_code, district_code, location, location_resolved, location_source)
+			VALUES ($1, $2, $3, $4, $5, ST_SetSRID(ST_MakePoint($6, $7), 4326)::geography, TRUE, $8)
+			ON CONFLICT (name) DO UPDATE SET
+				state = EXCLUDED.state, district = EXCLUDED.district,
+				state_code = EXCLUDED.state_code, district_code = EXCLUDED.district_code,
+				location = EXCLUDED.location, location_resolved = TRUE,
+				location_source = EXCLUDED.location_source, updated_at = NOW()`,
+			strings.TrimSpace(e.Name), e.State, e.District, e.StateCode, e.DistrictCode, e.Lon, e.Lat, *source)
+		if err != nil {
+			return fmt.Errorf("upsert %q: %w", e.Name, err)
+		}
+		imported++
+	}
+
+	var unresolved int
+	if err := db.Get(&unresolved, "SELECT COUNT(*) FROM mandis WHERE NOT location_resolved"); err != nil {
+		return fmt.Errorf("count unresolved mandis: %w", err)
+	}
+	fmt.Printf("Imported %d mandis (%d skipped); %d mandis still have an unresolved location\n", imported, skipped, unresolved)
+	return nil
+}
+
+// parseGazetteerCSV reads a CSV with a header row. Recognised columns:
+// name, state, district, state_code, district_code, lat, lon.
+func parseGazetteerCSV(r io.Reader) ([]gazetteerEntry, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read CSV header: %w", err)
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, required := range []string{"name", "lat", "lon"} {
+		if _, ok := col[required]; !ok {
+			return nil, fmt.Errorf("CSV header must include %q", required)
+		}
+	}
+
+	var entries []gazetteerEntry
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		field := func(name string) string {
+			if i, ok := col[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		lat, errLat := strconv.ParseFloat(field("lat"), 64)
+		lon, errLon := strconv.ParseFloat(field("lon"), 64)
+		if errLat != nil || errLon != nil {
+			log.Printf("[import] line %d (%q) skipped: lat/lon must be numbers", line, field("name"))
+			continue
+		}
+		entries = append(entries, gazetteerEntry{
+			Name: field("name"), State: field("state"), District: field("district"),
+			StateCode: field("state_code"), DistrictCode: field("district_code"),
+			Lat: lat, Lon: lon,
+		})
+	}
+}
+
+// parseGazetteerGeoJSON reads a FeatureCollection of Point features whose
+// properties carry the same keys as the CSV columns.
+func parseGazetteerGeoJSON(r io.Reader) ([]gazetteerEntry, error) {
+	var fc struct {
+		Features []struct {
+			Geometry struct {
+				Type        string    `json:"type"`
+				Coordinates []float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties struct {
+				Name         string `json:"name"`
+				State        string `json:"state"`
+				District     string `json:"district"`
+				StateCode    string `json:"state_code"`
+				DistrictCode string `json:"district_code"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(r).Decode(&fc); err != nil {
+		return nil, fmt.Errorf("parse GeoJSON: %w", err)
+	}
+
+	var entries []gazetteerEntry
+	for i, f := range fc.Features {
+		if f.Geometry.Type != "Point" || len(f.Geometry.Coordinates) < 2 {
+			log.Printf("[import] feature %d (%q) skipped: geometry must be a Point", i+1, f.Properties.Name)
+			continue
+		}
+		p := f.Properties
+		entries = append(entries, gazetteerEntry{
+			Name: p.Name, State: p.State, District: p.District,
+			StateCode: p.StateCode, DistrictCode: p.DistrictCode,
+			Lat: f.Geometry.Coordinates[1], Lon: f.Geometry.Coordinates[0], // GeoJSON is [lon, lat]
+		})
+	}
+	return entries, nil
+}
+
+// ── Handlers ────────────────────────────────
+
+// handleListUnresolvedMandis lists mandis excluded from scoring because their
+// location has not been verified, busiest first so operators fix those first.
+func handleListUnresolvedMandis(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page := MandiPage{Items: []Mandi{}, Limit: limit, Offset: offset}
+	if err := db.Get(&page.Total, "SELECT COUNT(*) FROM mandis WHERE NOT location_resolved"); err != nil {
+		log.Printf("⚠ DB count unresolved mandis failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list mandis"})
+		return
+	}
+	err = db.Select(&page.Items, `
+		SELECT `+mandiColumns+`,
+		       COUNT(dp.id) AS price_records, MAX(dp.arrival_date) AS last_traded
+		FROM mandis m
+		LEFT JOIN daily_prices dp ON dp.mandi_id = m.id
+		WHERE NOT m.location_resolved
+		GROUP BY m.id
+		ORDER BY price_records DESC, m.name
+		LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		log.Printf("⚠ DB list unresolved mandis failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list mandis"})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
@@ -13,28 +13,60 @@ type Farmer struct {
 	LocationLon float64   `json:"location_lon" db:"location_lon"`
 	Phone       string    `json:"phone" db:"phone"`
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// FarmerInput is the request body for registering or updating a farmer.
+// Coordinates are pointers so a missing field is distinguishable from 0.
+type FarmerInput struct {
+	Phone       string   `json:"phone"`
+	LocationLat *float64 `json:"location_lat"`
+	LocationLon *float64 `json:"location_lon"`
 }
 
 // Crop represents an agricultural crop and its spoilage parameters.
 type Crop struct {
 	ID                   string    `json:"id" db:"id"`
 	Name                 string    `json:"name" db:"name"`
+	Category             string    `json:"category" db:"category"` // vegetable, fruit, grain, bulb, ...
 	IdealTemp            float64   `json:"ideal_temp" db:"ideal_temp"`
 	BaselineSpoilageRate float64   `json:"baseline_spoilage_rate" db:"baseline_spoilage_rate"`
+	ShelfLifeDays        float64   `json:"shelf_life_days" db:"shelf_life_days"` // at ambient conditions
+	HumidityMinPct       float64   `json:"humidity_min_pct" db:"humidity_min_pct"`
+	HumidityMaxPct       float64   `json:"humidity_max_pct" db:"humidity_max_pct"`
+	UnitOfSale           string    `json:"unit_of_sale" db:"unit_of_sale"` // quintal, kg, tonne, dozen
 	CreatedAt            time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CropInput is the request body for adding or updating a catalogue crop.
+type CropInput struct {
+	Name                 string  `json:"name"`
+	Category             string  `json:"category"`
+	IdealTemp            float64 `json:"ideal_temp"`
+	BaselineSpoilageRate float64 `json:"baseline_spoilage_rate"`
+	ShelfLifeDays        float64 `json:"shelf_life_days"`
+	HumidityMinPct       float64 `json:"humidity_min_pct"`
+	HumidityMaxPct       float64 `json:"humidity_max_pct"`
+	UnitOfSale           string  `json:"unit_of_sale"`
 }
 
 // MandiPrice represents a live market price entry for a specific crop.
 type MandiPrice struct {
-	ID                 string    `json:"id" db:"id"`
-	MarketName         string    `json:"market_name" db:"market_name"`
-	CropID             string    `json:"crop_id" db:"crop_id"`
-	CurrentPrice       float64   `json:"current_price" db:"current_price"`
-	MarketLat          float64   `json:"market_lat" db:"market_lat"`
-	MarketLon          float64   `json:"market_lon" db:"market_lon"`
-	ArrivalVolumeTrend string    `json:"arrival_volume_trend" db:"arrival_volume_trend"`
-	PriceTrendPct      float64   `json:"price_trend_pct" db:"price_trend_pct"`
-	Timestamp          time.Time `json:"timestamp" db:"timestamp"`
+	ID                 string          `json:"id" db:"id"`
+	MarketName         string          `json:"market_name" db:"market_name"`
+	CropID             string          `json:"crop_id" db:"crop_id"`
+	CurrentPrice       float64         `json:"current_price" db:"current_price"` // modal, INR per quintal
+	MinPrice           float64         `json:"min_price" db:"-"`
+	MaxPrice           float64         `json:"max_price" db:"-"`
+	MarketLat          float64         `json:"market_lat" db:"market_lat"`
+	MarketLon          float64         `json:"market_lon" db:"market_lon"`
+	ArrivalVolumeTrend string          `json:"arrival_volume_trend" db:"arrival_volume_trend"`
+	ArrivalTrendSource string          `json:"arrival_trend_source" db:"-"` // measured, price_inferred
+	ArrivalSurgeRatio  float64         `json:"arrival_surge_ratio" db:"-"`  // recent / baseline arrivals, measured only
+	PriceTrendPct      float64         `json:"price_trend_pct" db:"price_trend_pct"`
+	Forecast           []ForecastPoint `json:"forecast,omitempty" db:"-"`
+	Timestamp          time.Time       `json:"timestamp" db:"timestamp"`
 }
 
 // StorageFacility represents a cold storage / micro-storage option.
@@ -45,6 +77,8 @@ type StorageFacility struct {
 	LocationLon float64   `json:"location_lon" db:"location_lon"`
 	CapacityMT  float64   `json:"capacity_mt" db:"capacity_mt"`
 	PricePerKg  float64   `json:"price_per_kg" db:"price_per_kg"`
+	MinTempC    *float64  `json:"min_temp_c" db:"min_temp_c"` // coldest chamber setting; nil when unknown
+	MaxTempC    *float64  `json:"max_temp_c" db:"max_temp_c"` // warmest chamber setting; nil when unknown
 	CreatedAt   time.Time `json:"created_at" db:"created_at"`
 }
 
@@ -52,14 +86,29 @@ type StorageFacility struct {
 
 // MarketOption represents a single market with its computed score.
 type MarketOption struct {
-	MarketName         string  `json:"market_name"`
-	CurrentPrice       float64 `json:"current_price"`
-	DistanceKm         float64 `json:"distance_km"`
-	TransitTimeHr      float64 `json:"transit_time_hr"`
-	SpoilageLoss       float64 `json:"spoilage_loss_pct"`
-	NetProfitEstimate  float64 `json:"net_profit_estimate"`
+	MarketName         string             `json:"market_name"`
+	CurrentPrice       float64            `json:"current_price"`
+	MinPrice           float64            `json:"min_price"`
+	MaxPrice           float64            `json:"max_price"`
+	DistanceKm         float64            `json:"distance_km"`
+	TransitTimeHr      float64            `json:"transit_time_hr"`
+	TransitSource      string             `json:"transit_source"` // routed, haversine
+	SpoilageLoss       float64            `json:"spoilage_loss_pct"`
+	TransportCost      float64            `json:"transport_cost"` // ₹ per quintal
+	Vehicle            string             `json:"vehicle"`
+	TransportBreakdown TransportBreakdown `json:"transport_breakdown"`
+	NetProfitEstimate  float64            `json:"net_profit_estimate"` // ₹ per quintal
+
+	// Rupee totals for the farmer's whole harvest
+	ExpectedSpoilageKg float64 `json:"expected_spoilage_kg"`
+	TotalRevenue       float64 `json:"total_revenue"`
+	TotalTransportCost float64 `json:"total_transport_cost"`
+	StorageCost        float64 `json:"storage_cost"`
+	NetReturn          float64 `json:"net_return"`
+
 	MarketScore        float64 `json:"market_score"`
 	ArrivalVolumeTrend string  `json:"arrival_volume_trend"`
+	ArrivalTrendSource string  `json:"arrival_trend_source"` // measured, price_inferred
 	PriceTrendPct      float64 `json:"price_trend_pct"`
 	IsAIRecommended    bool    `json:"is_ai_recommended"`
 }
@@ -70,23 +119,110 @@ type WeatherInfo struct {
 	Humidity    float64 `json:"humidity_pct"`
 	TempDelta   float64 `json:"temp_delta_from_ideal"`
 	Condition   string  `json:"condition"`
+	PrecipMm    float64 `json:"precipitation_mm"`
+	WindKmh     float64 `json:"wind_kmh"`
+	DewPointC   float64 `json:"dew_point_c"`
+
+	// Conditions the crop has already sat through in the field; nil when no
+	// cached history exists for the cell.
+	Recent *WeatherExposure `json:"recent,omitempty"`
+
+	// Provenance: "cache" (nearest grid cell), "live" (direct Open-Meteo
+	// call) or "fallback" (demo values).
+	Source         string    `json:"source"`
+	Cell           string    `json:"cell,omitempty"`
+	CellDistanceKm float64   `json:"cell_distance_km,omitempty"`
+	ObservedAt     time.Time `json:"observed_at,omitempty"`
+	AgeMinutes     int       `json:"age_minutes"`
+
+	Forecast []DailyForecast `json:"forecast,omitempty"` // today first, up to 7 days
 }
 
-// SoilHealth holds the mock soil indicators for the farmer's region.
+// WeatherExposure summarises a grid cell's recent hourly readings.
+type WeatherExposure struct {
+	WindowHours     int     `json:"window_hours" db:"-"`
+	Readings        int     `json:"readings" db:"readings"`
+	MeanTempC       float64 `json:"mean_temp_c" db:"mean_temp"`
+	MaxTempC        float64 `json:"max_temp_c" db:"max_temp"`
+	MeanHumidity    float64 `json:"mean_humidity_pct" db:"mean_humidity"`
+	HumidHours      int     `json:"humid_hours" db:"humid_hours"` // readings at RH >= 85%
+	RainMm          float64 `json:"rain_mm" db:"rain_mm"`
+	HeatDegreeHours float64 `json:"heat_degree_hours" db:"heat_degree_hours"` // °C·h above the crop's ideal
+}
+
+// DailyForecast is one day of the Open-Meteo daily forecast for a grid cell.
+type DailyForecast struct {
+	Date          string  `json:"date" db:"date"` // YYYY-MM-DD, local to the cell
+	PrecipProbPct int     `json:"precip_probability_pct" db:"precip_prob_pct"`
+	PrecipMm      float64 `json:"precipitation_mm" db:"precip_mm"`
+	TempMaxC      float64 `json:"temp_max_c" db:"temp_max"`
+	TempMinC      float64 `json:"temp_min_c" db:"temp_min"`
+	WindMaxKmh    float64 `json:"wind_max_kmh" db:"wind_max_kmh"`
+	ET0Mm         float64 `json:"et0_mm" db:"et0_mm"` // FAO-56 reference evapotranspiration
+}
+
+// SoilHealth holds the soil indicators for the farmer's location with their
+// provenance. Nutrients are nil when no sample or estimate is available.
 type SoilHealth struct {
-	MoisturePct float64 `json:"moisture_pct"`
-	Nitrogen    float64 `json:"nitrogen"`
-	Phosphorus  float64 `json:"phosphorus"`
-	Potassium   float64 `json:"potassium"`
-	Status      string  `json:"status"`
+	MoisturePct      float64  `json:"moisture_pct"`
+	MoistureSource   string   `json:"moisture_source"` // "open_meteo" or "default"
+	Nitrogen         *float64 `json:"nitrogen"`        // available N, kg/ha
+	Phosphorus       *float64 `json:"phosphorus"`      // available P, kg/ha
+	Potassium        *float64 `json:"potassium"`       // available K, kg/ha
+	PH               *float64 `json:"ph,omitempty"`
+	OrganicCarbonPct *float64 `json:"organic_carbon_pct,omitempty"`
+	Source           string   `json:"source"` // lab, soil_health_card, soilgrids, regional_estimate, unavailable
+	SampledOn        string   `json:"sampled_on,omitempty"`
+	DistanceKm       float64  `json:"distance_km"` // from the sample (nearest sample for estimates)
+	Estimated        bool     `json:"estimated"`
+	Status           string   `json:"status"`
 }
 
 // StorageOption represents a nearby cold storage recommendation.
 type StorageOption struct {
-	Name       string  `json:"name"`
-	DistanceKm float64 `json:"distance_km"`
-	PricePerKg float64 `json:"price_per_kg"`
-	CapacityMT float64 `json:"capacity_mt"`
+	ID          string  `json:"id,omitempty"`
+	Name        string  `json:"name"`
+	DistanceKm  float64 `json:"distance_km"`
+	PricePerKg  float64 `json:"price_per_kg"`
+	CapacityMT  float64 `json:"capacity_mt"`
+	AvailableMT float64 `json:"available_mt"` // free on every day of the hold window
+	HoldFrom    string  `json:"hold_from,omitempty"`
+	HoldUntil   string  `json:"hold_until,omitempty"`
+
+	// Ranking (see storage_search.go)
+	MinTempC *float64 `json:"min_temp_c,omitempty"`
+	MaxTempC *float64 `json:"max_temp_c,omitempty"`
+	TempFit  string   `json:"temp_fit,omitempty"` // "ok", "too_cold", "too_warm", "unknown"
+	FitsLoad bool     `json:"fits_load"`
+	Score    float64  `json:"score"` // 0-1, higher is better
+}
+
+// StoragePlan is the store-vs-sell optimiser's verdict for a surging market.
+// Rupee figures are totals for the whole load.
+type StoragePlan struct {
+	Decision      string  `json:"decision"` // "store" or "sell_now"
+	FacilityID    string  `json:"facility_id,omitempty"`
+	FacilityName  string  `json:"facility_name,omitempty"`
+	HoldDays      int     `json:"hold_days"`
+	SellDate      string  `json:"sell_date,omitempty"`      // YYYY-MM-DD
+	ExpectedPrice float64 `json:"expected_price,omitempty"` // ₹ per quintal on the sell date
+	ShrinkageKg   float64 `json:"shrinkage_kg"`
+	StorageCost   float64 `json:"storage_cost"`
+	HandlingCost  float64 `json:"handling_cost"` // trip from the farm to the facility
+	SellNowReturn float64 `json:"sell_now_return"`
+	StoreReturn   float64 `json:"store_return"`
+	ExpectedGain  float64 `json:"expected_gain"`
+	WorstCaseGain float64 `json:"worst_case_gain"` // at the forecast's lower band
+	Reason        string  `json:"reason"`
+}
+
+// ForecastPoint is one day of a forecast price path with its prediction interval (INR per quintal).
+type ForecastPoint struct {
+	Day   int     `json:"day"`
+	Date  string  `json:"date"` // YYYY-MM-DD
+	Price float64 `json:"price"`
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
 }
 
 // ConfidenceBand represents a price range for farmer psychology management.
@@ -103,32 +239,185 @@ type PreservationAction struct {
 	Rank          int    `json:"rank"`
 }
 
+// FieldAdvisory is the fertiliser and irrigation advice for a crop at one
+// growth stage. Quantities and costs cover the whole area.
+type FieldAdvisory struct {
+	CropName       string           `json:"crop_name"`
+	GrowthStage    string           `json:"growth_stage"` // sowing, vegetative, flowering, maturity
+	AreaAcres      float64          `json:"area_acres"`
+	SoilSource     string           `json:"soil_source"`
+	SoilEstimated  bool             `json:"soil_estimated"`
+	Nutrients      []NutrientStatus `json:"nutrients"`
+	Fertilisers    []FertiliserDose `json:"fertilisers"`
+	Irrigation     IrrigationPlan   `json:"irrigation"`
+	FertiliserCost float64          `json:"fertiliser_cost"`
+	IrrigationCost float64          `json:"irrigation_cost"`
+	TotalCost      float64          `json:"total_cost"`
+	Notes          []string         `json:"notes,omitempty"`
+}
+
+// NutrientStatus compares a soil test value with the crop's requirement.
+// N, P and K requirements are N, P2O5 and K2O respectively.
+type NutrientStatus struct {
+	Nutrient     string   `json:"nutrient"` // N, P, K
+	SoilKgHa     *float64 `json:"soil_kg_ha"`
+	Rating       string   `json:"rating"` // low, medium, high, unknown
+	RequiredKgHa float64  `json:"required_kg_ha"`
+	DueNowKgHa   float64  `json:"due_now_kg_ha"` // soil-adjusted share for this stage
+}
+
+// FertiliserDose is one product to apply now.
+type FertiliserDose struct {
+	Product   string  `json:"product"` // Urea, DAP, MOP
+	KgPerAcre float64 `json:"kg_per_acre"`
+	TotalKg   float64 `json:"total_kg"`
+	Bags      float64 `json:"bags"`
+	Cost      float64 `json:"cost"`
+}
+
+// IrrigationPlan is the irrigation schedule over the weather forecast.
+type IrrigationPlan struct {
+	Kc              float64           `json:"kc"` // crop coefficient at this stage
+	TotalETcMm      float64           `json:"total_etc_mm"`
+	EffectiveRainMm float64           `json:"effective_rain_mm"`
+	TotalGrossMm    float64           `json:"total_gross_mm"`
+	TotalLitres     float64           `json:"total_litres"`
+	Schedule        []IrrigationEvent `json:"schedule"`
+	Note            string            `json:"note,omitempty"`
+}
+
+// IrrigationEvent is one scheduled irrigation. Gross depth includes field losses.
+type IrrigationEvent struct {
+	Date    string  `json:"date"` // YYYY-MM-DD
+	NetMm   float64 `json:"net_mm"`
+	GrossMm float64 `json:"gross_mm"`
+}
+
+// GrowthStatus is a crop's growth stage from growing degree days (GDD)
+// accumulated since sowing, and its projected harvest.
+type GrowthStatus struct {
+	SowingDate          string  `json:"sowing_date"`
+	BaseTempC           float64 `json:"base_temp_c"`
+	GDDAccumulated      float64 `json:"gdd_accumulated"`
+	GDDToMaturity       float64 `json:"gdd_to_maturity"`
+	ProgressPct         float64 `json:"progress_pct"`
+	Stage               string  `json:"stage"`           // sowing, vegetative, flowering, maturity
+	Maturity            string  `json:"maturity"`        // Early, Optimal, Late
+	DaysToHarvest       int     `json:"days_to_harvest"` // negative once past maturity
+	ExpectedHarvestDate string  `json:"expected_harvest_date"`
+	DailyGDD            float64 `json:"daily_gdd"`      // recent pace used beyond the forecast
+	DaysObserved        int     `json:"days_observed"`  // days with temperature data
+	DaysEstimated       int     `json:"days_estimated"` // gaps counted at the season's mean pace
+	Source              string  `json:"source"`         // "gdd"
+}
+
+// RecommendationInputs records the request parameters a recommendation was built from.
+type RecommendationInputs struct {
+	PlotID       string  `json:"plot_id,omitempty"`
+	CropID       string  `json:"crop_id"`
+	SowingDate   string  `json:"sowing_date,omitempty"`
+	LocationLat  float64 `json:"location_lat"`
+	LocationLon  float64 `json:"location_lon"`
+	RoadQuality  string  `json:"road_quality"`
+	CropMaturity string  `json:"crop_maturity"`
+	ForecastDays int     `json:"forecast_days"`
+	Quantity     float64 `json:"quantity"`
+	QuantityUnit string  `json:"quantity_unit"`
+	GrowthStage  string  `json:"growth_stage"`
+	AreaAcres    float64 `json:"area_acres"`
+	Lang         string  `json:"lang"`
+}
+
 // Recommendation is the top-level JSON payload returned to the frontend.
 type Recommendation struct {
+	ID                string               `json:"id,omitempty"` // set once persisted
 	FarmerID          string               `json:"farmer_id"`
 	CropName          string               `json:"crop_name"`
 	Action            string               `json:"action"` // e.g. "Sell at Mandi", "Delay & Store Locally"
 	HarvestWindow     string               `json:"harvest_window"`
+	HarvestDate       string               `json:"harvest_date,omitempty"` // YYYY-MM-DD when picked from the forecast
 	RecommendedMarket string               `json:"recommended_market"`
+	QuantityKg        float64              `json:"quantity_kg"`
+	QuantitySource    string               `json:"quantity_source"` // request, plot, farmer_crop, default
 	MarketScore       float64              `json:"market_score"`
 	ConfidenceBandMin float64              `json:"confidence_band_min"`
 	ConfidenceBandMax float64              `json:"confidence_band_max"`
+	ForecastModel     string               `json:"forecast_model"`
+	PriceForecast     []ForecastPoint      `json:"price_forecast"` // recommended market, day by day
 	Why               string               `json:"why"`
 	Weather           WeatherInfo          `json:"weather"`
 	Soil              SoilHealth           `json:"soil_health"`
 	Markets           []MarketOption       `json:"markets"`
 	Storage           *StorageOption       `json:"storage,omitempty"`
+	StorageOptions    []StorageOption      `json:"storage_options,omitempty"` // ranked nearby facilities
+	StoragePlan       *StoragePlan         `json:"storage_plan,omitempty"`
 	Preservation      []PreservationAction `json:"preservation_actions"`
+	Advisory          *FieldAdvisory       `json:"advisory,omitempty"`
+	Growth            *GrowthStatus        `json:"growth,omitempty"`
+	Inputs            RecommendationInputs `json:"inputs"`
 	GeneratedAt       time.Time            `json:"generated_at"`
 }
 
-// SpoilageFactors holds environmental and logistical data to determine spoilage risk.
+// RecommendationPage is a paginated slice of a farmer's recommendation history.
+type RecommendationPage struct {
+	Items  []Recommendation `json:"items"`
+	Total  int              `json:"total"`
+	Limit  int              `json:"limit"`
+	Offset int              `json:"offset"`
+}
+
+// OutcomeInput is what a farmer (or the WhatsApp bot) reports after acting
+// on a recommendation. Prices are INR per quintal.
+type OutcomeInput struct {
+	MarketName        string     `json:"market_name"`
+	RealisedPrice     float64    `json:"realised_price"`
+	QuantitySoldKg    float64    `json:"quantity_sold_kg"`
+	QuantitySpoiledKg float64    `json:"quantity_spoiled_kg"`
+	SoldAt            *time.Time `json:"sold_at,omitempty"`
+	Source            string     `json:"source"` // "app" or "whatsapp"
+}
+
+// RecommendationOutcome is a stored outcome plus the scores computed by the accuracy job.
+type RecommendationOutcome struct {
+	ID                string     `json:"id" db:"id"`
+	RecommendationID  string     `json:"recommendation_id" db:"recommendation_id"`
+	FarmerID          string     `json:"farmer_id" db:"farmer_id"`
+	MarketName        string     `json:"market_name" db:"market_name"`
+	RealisedPrice     float64    `json:"realised_price" db:"realised_price"`
+	QuantitySoldKg    float64    `json:"quantity_sold_kg" db:"quantity_sold_kg"`
+	QuantitySpoiledKg float64    `json:"quantity_spoiled_kg" db:"quantity_spoiled_kg"`
+	Source            string     `json:"source" db:"source"`
+	SoldAt            time.Time  `json:"sold_at" db:"sold_at"`
+	InBand            *bool      `json:"in_band,omitempty" db:"in_band"`
+	PriceErrorPct     *float64   `json:"price_error_pct,omitempty" db:"price_error_pct"`
+	ProfitError       *float64   `json:"profit_error,omitempty" db:"profit_error"`
+	EvaluatedAt       *time.Time `json:"evaluated_at,omitempty" db:"evaluated_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+}
+
+// AccuracyMetric summarises how well past advice matched reality for one market or crop.
+type AccuracyMetric struct {
+	Scope           string    `json:"scope" db:"scope"` // "market" or "crop"
+	Key             string    `json:"key" db:"key"`
+	SampleSize      int       `json:"sample_size" db:"sample_size"`
+	BandHitRate     float64   `json:"band_hit_rate" db:"band_hit_rate"`         // share of sales inside the confidence band
+	PriceMAPE       float64   `json:"price_mape" db:"price_mape"`               // mean absolute % error vs quoted price
+	MeanProfitError float64   `json:"mean_profit_error" db:"mean_profit_error"` // realised minus estimated net, INR/quintal
+	ComputedAt      time.Time `json:"computed_at" db:"computed_at"`
+}
+
+// SpoilageFactors holds environmental and logistical data for the spoilage model (see spoilage.go).
 type SpoilageFactors struct {
 	TemperatureCelsius float64
 	HumidityPercent    float64
 	TransitTimeHours   float64
-	RoadQuality        string // "paved", "unpaved", "mixed"
-	CropMaturity       string // "Early", "Optimal", "Late"
+	RoadQuality        string  // "paved", "unpaved", "mixed"
+	CropMaturity       string  // "Early", "Optimal", "Late"
+	RecentHumidHours   int     // field hours at RH >= 85% over the last 3 days
+	RecentHeatDegreeHr float64 // °C·h above the crop's ideal over the last 3 days
+	Refrigerated       bool    // reefer: the load rides at the crop's ideal temperature
+	StorageDays        float64 // cold-storage hold after the trip
+	StorageTempC       float64 // chamber temperature during the hold
 }
 
 // ---------- Chat Models ----------
@@ -143,3 +432,226 @@ type ChatRequest struct {
 type ChatResponse struct {
 	Reply string `json:"reply"`
 }
+
+// Mandi is a registry entry. Mandis discovered by ingestion start with no
+// verified location and are excluded from scoring until a gazetteer import
+// resolves them.
+type Mandi struct {
+	ID               int        `json:"id" db:"id"`
+	Name             string     `json:"name" db:"name"`
+	State            string     `json:"state" db:"state"`
+	District         string     `json:"district" db:"district"`
+	StateCode        string     `json:"state_code" db:"state_code"`
+	DistrictCode     string     `json:"district_code" db:"district_code"`
+	Lat              *float64   `json:"lat" db:"lat"`
+	Lon              *float64   `json:"lon" db:"lon"`
+	LocationResolved bool       `json:"location_resolved" db:"location_resolved"`
+	LocationSource   string     `json:"location_source" db:"location_source"`
+	PriceRecords     int        `json:"price_records" db:"price_records"`
+	LastTraded       *time.Time `json:"last_traded,omitempty" db:"last_traded"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+}
+
+// MandiPage is a paginated slice of the mandi registry.
+type MandiPage struct {
+	Items  []Mandi `json:"items"`
+	Total  int     `json:"total"`
+	Limit  int     `json:"limit"`
+	Offset int     `json:"offset"`
+}
+
+// NotificationPreferences controls proactive alerts for one farmer. Farmers
+// without a stored row get the defaults (all alerts on, WhatsApp).
+type NotificationPreferences struct {
+	FarmerID  string    `json:"farmer_id" db:"farmer_id"`
+	Enabled   bool      `json:"enabled" db:"enabled"`
+	Channel   string    `json:"channel" db:"channel"` // "whatsapp" or "log"
+	HeavyRain bool      `json:"heavy_rain" db:"heavy_rain"`
+	Heatwave  bool      `json:"heatwave" db:"heatwave"`
+	Frost     bool      `json:"frost" db:"frost"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// NotificationPreferencesInput is the PUT payload; omitted fields keep their
+// current value.
+type NotificationPreferencesInput struct {
+	Enabled   *bool   `json:"enabled"`
+	Channel   *string `json:"channel"`
+	HeavyRain *bool   `json:"heavy_rain"`
+	Heatwave  *bool   `json:"heatwave"`
+	Frost     *bool   `json:"frost"`
+}
+
+// FarmerAlert is one severe-weather alert, stored once per farmer, crop,
+// threat and forecast day.
+type FarmerAlert struct {
+	ID        string     `json:"id" db:"id"`
+	FarmerID  string     `json:"farmer_id" db:"farmer_id"`
+	CropID    string     `json:"crop_id" db:"crop_id"`
+	CropName  string     `json:"crop_name" db:"crop_name"`
+	AlertType string     `json:"alert_type" db:"alert_type"` // "heavy_rain", "heatwave", "frost"
+	AlertDate string     `json:"alert_date" db:"alert_date"` // forecast day the threat is on
+	Message   string     `json:"message" db:"message"`
+	Action    string     `json:"recommended_action" db:"action"`
+	Channel   string     `json:"channel" db:"channel"`
+	Status    string     `json:"status" db:"status"` // "pending", "sent", "failed", "undelivered"
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	SentAt    *time.Time `json:"sent_at,omitempty" db:"sent_at"`
+}
+
+// FarmerAlertPage is a paginated slice of a farmer's alert history.
+type FarmerAlertPage struct {
+	Items  []FarmerAlert `json:"items"`
+	Total  int           `json:"total"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
+
+// FarmerCrop is how much of a crop a farmer currently has to sell.
+type FarmerCrop struct {
+	FarmerID  string    `json:"farmer_id" db:"farmer_id"`
+	CropID    string    `json:"crop_id" db:"crop_id"`
+	CropName  string    `json:"crop_name" db:"crop_name"`
+	Quantity  float64   `json:"quantity" db:"quantity"`
+	Unit      string    `json:"unit" db:"unit"` // kg, quintal or tonne
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// FarmerCropInput is the PUT payload for a farmer's crop record.
+type FarmerCropInput struct {
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit"`
+}
+
+// GeoPoint is a WGS84 coordinate.
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// Plot is one of a farmer's fields and the crop standing on it.
+type Plot struct {
+	ID                  string     `json:"id" db:"id"`
+	FarmerID            string     `json:"farmer_id" db:"farmer_id"`
+	Name                string     `json:"name" db:"name"`
+	CropID              string     `json:"crop_id" db:"crop_id"`
+	CropName            string     `json:"crop_name" db:"crop_name"`
+	Lat                 float64    `json:"lat" db:"lat"`
+	Lon                 float64    `json:"lon" db:"lon"`
+	Boundary            []GeoPoint `json:"boundary,omitempty" db:"-"`
+	BoundaryGeoJSON     string     `json:"-" db:"boundary_geojson"`
+	AreaAcres           float64    `json:"area_acres" db:"area_acres"`
+	SowingDate          string     `json:"sowing_date" db:"sowing_date"`
+	ExpectedHarvestDate string     `json:"expected_harvest_date,omitempty" db:"expected_harvest_date"`
+	IrrigationType      string     `json:"irrigation_type" db:"irrigation_type"`
+	ExpectedYield       *float64   `json:"expected_yield" db:"expected_yield"`
+	YieldUnit           string     `json:"yield_unit" db:"yield_unit"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// PlotInput is the POST/PUT payload for a plot. Give either lat/lon or a
+// boundary; area_acres is computed from the boundary when omitted.
+type PlotInput struct {
+	Name                string     `json:"name"`
+	CropID              string     `json:"crop_id"`
+	Lat                 *float64   `json:"lat"`
+	Lon                 *float64   `json:"lon"`
+	Boundary            []GeoPoint `json:"boundary"`
+	AreaAcres           *float64   `json:"area_acres"`
+	SowingDate          string     `json:"sowing_date"`
+	ExpectedHarvestDate string     `json:"expected_harvest_date"`
+	IrrigationType      string     `json:"irrigation_type"`
+	ExpectedYield       *float64   `json:"expected_yield"`
+	YieldUnit           string     `json:"yield_unit"`
+}
+
+// StorageBooking is a reservation of cold-storage space for a date range.
+// Pending holds lapse at ExpiresAt unless confirmed.
+type StorageBooking struct {
+	ID           string     `json:"id" db:"id"`
+	FacilityID   string     `json:"facility_id" db:"facility_id"`
+	FacilityName string     `json:"facility_name" db:"facility_name"`
+	FarmerID     string     `json:"farmer_id" db:"farmer_id"`
+	CropID       *string    `json:"crop_id,omitempty" db:"crop_id"`
+	QuantityKg   float64    `json:"quantity_kg" db:"quantity_kg"`
+	StartDate    string     `json:"start_date" db:"start_date"` // first day stored
+	EndDate      string     `json:"end_date" db:"end_date"`     // last day stored, inclusive
+	Status       string     `json:"status" db:"status"`         // "pending", "confirmed", "cancelled", "expired"
+	ExpiresAt    time.Time  `json:"expires_at" db:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty" db:"confirmed_at"`
+	CancelledAt  *time.Time `json:"cancelled_at,omitempty" db:"cancelled_at"`
+}
+
+// StorageBookingInput is the payload for reserving cold-storage space.
+type StorageBookingInput struct {
+	FacilityID string  `json:"facility_id" binding:"required"`
+	FarmerID   string  `json:"farmer_id" binding:"required"`
+	CropID     *string `json:"crop_id"`
+	Quantity   float64 `json:"quantity" binding:"required"`
+	Unit       string  `json:"unit"` // kg, quintal (default), tonne
+	StartDate  string  `json:"start_date" binding:"required"`
+	EndDate    string  `json:"end_date" binding:"required"`
+}
+
+// StorageBookingPage is a paginated slice of a farmer's storage bookings.
+type StorageBookingPage struct {
+	Items  []StorageBooking `json:"items"`
+	Total  int              `json:"total"`
+	Limit  int              `json:"limit"`
+	Offset int              `json:"offset"`
+}
+
+// StorageAvailabilityDay is a facility's booked and free tonnage on one day.
+type StorageAvailabilityDay struct {
+	Date        string  `json:"date" db:"date"`
+	CapacityMT  float64 `json:"capacity_mt" db:"-"`
+	BookedMT    float64 `json:"booked_mt" db:"booked_mt"`
+	AvailableMT float64 `json:"available_mt" db:"-"`
+}
+
+// SoilSample is one stored soil test: an imported Soil Health Card or
+// SoilGrids point, or a farmer's own lab report.
+type SoilSample struct {
+	ID               string    `json:"id" db:"id"`
+	Source           string    `json:"source" db:"source"` // "lab", "soil_health_card", "soilgrids"
+	Reference        string    `json:"reference" db:"reference"`
+	FarmerID         *string   `json:"farmer_id,omitempty" db:"farmer_id"`
+	PlotName         string    `json:"plot_name" db:"plot_name"`
+	Lat              float64   `json:"lat" db:"lat"`
+	Lon              float64   `json:"lon" db:"lon"`
+	SampledOn        string    `json:"sampled_on" db:"sampled_on"`
+	Nitrogen         *float64  `json:"nitrogen" db:"nitrogen"`
+	Phosphorus       *float64  `json:"phosphorus" db:"phosphorus"`
+	Potassium        *float64  `json:"potassium" db:"potassium"`
+	PH               *float64  `json:"ph" db:"ph"`
+	OrganicCarbonPct *float64  `json:"organic_carbon_pct" db:"organic_carbon_pct"`
+	LabName          string    `json:"lab_name" db:"lab_name"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+// SoilTestInput is a farmer's lab report. Lat/Lon default to the farmer's
+// registered location.
+type SoilTestInput struct {
+	PlotName         string   `json:"plot_name"`
+	Lat              *float64 `json:"lat"`
+	Lon              *float64 `json:"lon"`
+	SampledOn        string   `json:"sampled_on"`
+	Nitrogen         *float64 `json:"nitrogen"`
+	Phosphorus       *float64 `json:"phosphorus"`
+	Potassium        *float64 `json:"potassium"`
+	PH               *float64 `json:"ph"`
+	OrganicCarbonPct *float64 `json:"organic_carbon_pct"`
+	LabName          string   `json:"lab_name"`
+	Reference        string   `json:"reference"`
+}
+
+// SoilSamplePage is a paginated slice of a farmer's soil tests.
+type SoilSamplePage struct {
+	Items  []SoilSample `json:"items"`
+	Total  int          `json:"total"`
+	Limit  int          `json:"limit"`
+	Offset int          `json:"offset"`
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// ══════════════════════════════════════════════
+//  NOTIFICATIONS (Outbound Farmer Messages)
+// ══════════════════════════════════════════════
+
+// Notifier delivers a text message to a farmer's phone.
+type Notifier interface {
+	Channel() string
+	Send(phone, message string) error
+}
+
+// WhatsAppNotifier sends through the WhatsApp Cloud API, the same business
+// number that receives crowdsourcing pings on /api/v1/webhook/whatsapp.
+type WhatsAppNotifier struct {
+	PhoneNumberID string
+	AccessToken   string
+}
+
+func (WhatsAppNotifier) Channel() string { return "whatsapp" }
+
+func (n WhatsAppNotifier) Send(phone, message string) error {
+	body, err := json.Marshal(map[string]any{
+		"messaging_product": "whatsapp",
+		"to":                strings.TrimPrefix(phone, "+"),
+		"type":              "text",
+		"text":              map[string]string{"body": message},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost,
+		"https://graph.facebook.com/v19.0/"+n.PhoneNumberID+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+n.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("whatsapp request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("whatsapp returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// LogNotifier writes messages to the server log. Used in demo mode and when
+// WhatsApp credentials are not configured.
+type LogNotifier struct{}
+
+func (LogNotifier) Channel() string { return "log" }
+
+func (LogNotifier) Send(phone, message string) error {
+	log.Printf("📨 [notify] to %s: %s", phone, message)
+	return nil
+}
+
+// notificationChannels lists the channels a farmer can choose.
+var notificationChannels = map[string]bool{"whatsapp": true, "log": true}
+
+// notifierFor returns the notifier for a channel, degrading WhatsApp to the
+// log when WHATSAPP_PHONE_NUMBER_ID / WHATSAPP_ACCESS_TOKEN are unset. degraded
+// reports that fallback: the farmer will not actually receive the message.
+func notifierFor(channel string) (n Notifier, degraded bool) {
+	if channel == "whatsapp" {
+		id, token := os.Getenv("WHATSAPP_PHONE_NUMBER_ID"), os.Getenv("WHATSAPP_ACCESS_TOKEN")
+		if id != "" && token != "" {
+			return WhatsAppNotifier{PhoneNumberID: id, AccessToken: token}, false
+		}
+		return LogNotifier{}, true
+	}
+	return LogNotifier{}, false
+}
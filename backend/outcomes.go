@@ -0,0 +1,327 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// ══════════════════════════════════════════════
+//  OUTCOME FEEDBACK LOOP (Recommendation Accuracy)
+// ══════════════════════════════════════════════
+
+var errOutcomeExists = errors.New("outcome already reported")
+
+func (in OutcomeInput) validate() error {
+	if strings.TrimSpace(in.MarketName) == "" {
+		return fmt.Errorf("market_name is required")
+	}
+	if in.RealisedPrice <= 0 {
+		return fmt.Errorf("realised_price must be positive (INR per quintal)")
+	}
+	if in.QuantitySoldKg < 0 || in.QuantitySpoiledKg < 0 {
+		return fmt.Errorf("quantities must not be negative")
+	}
+	if in.Source != "" && in.Source != "app" && in.Source != "whatsapp" {
+		return fmt.Errorf("source must be app or whatsapp")
+	}
+	return nil
+}
+
+// recordOutcome stores what actually happened after a recommendation. Only
+// one outcome per recommendation is accepted.
+func recordOutcome(rec Recommendation, in OutcomeInput) (RecommendationOutcome, error) {
+	source := in.Source
+	if source == "" {
+		source = "app"
+	}
+	soldAt := time.Now()
+	if in.SoldAt != nil {
+		soldAt = *in.SoldAt
+	}
+
+	var o RecommendationOutcome
+	err := db.Get(&o, `
+		INSERT INTO recommendation_outcomes
+			(recommendation_id, farmer_id, market_name, realised_price, quantity_sold_kg, quantity_spoiled_kg, source, sold_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (recommendation_id) DO NOTHING
+		RETURNING `+outcomeColumns,
+		rec.ID, rec.FarmerID, strings.TrimSpace(in.MarketName), in.RealisedPrice,
+		in.QuantitySoldKg, in.QuantitySpoiledKg, source, soldAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return RecommendationOutcome{}, errOutcomeExists
+	}
+	if err != nil {
+		return RecommendationOutcome{}, fmt.Errorf("insert outcome: %w", err)
+	}
+	return o, nil
+}
+
+const outcomeColumns = "id, recommendation_id, farmer_id, market_name, realised_price, quantity_sold_kg, quantity_spoiled_kg, source, sold_at, in_band, price_error_pct, profit_error, evaluated_at, created_at"
+
+// ── Handlers ────────────────────────────────
+
+func handleReportOutcome(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	id := c.Param("id")
+	rec, err := fetchRecommendation(id)
+	if errors.Is(err, errRecommendationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("recommendation %s not found", id)})
+		return
+	}
+	if err != nil {
+		log.Printf("⚠ DB fetch recommendation failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load recommendation"})
+		return
+	}
+
+	var in OutcomeInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload format"})
+		return
+	}
+	if err := in.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	o, err := recordOutcome(rec, in)
+	if errors.Is(err, errOutcomeExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("an outcome for recommendation %s has already been reported", id)})
+		return
+	}
+	if err != nil {
+		log.Printf("Error recording outcome: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record outcome"})
+		return
+	}
+
+	log.Printf("✅ Outcome reported for recommendation %s: sold at %s for ₹%.2f", id, o.MarketName, o.RealisedPrice)
+	c.JSON(http.StatusCreated, o)
+}
+
+// handleAccuracyMetrics serves the latest snapshot computed by the accuracy
+// job. ?scope=market|crop narrows the result.
+func handleAccuracyMetrics(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	scope := c.Query("scope")
+	if scope != "" && scope != "market" && scope != "crop" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be market or crop"})
+		return
+	}
+
+	metrics := []AccuracyMetric{}
+	err := db.Select(&metrics, `
+		SELECT scope, key, sample_size, band_hit_rate, price_mape, mean_profit_error, computed_at
+		FROM accuracy_metrics
+		WHERE $1 = '' OR scope = $1
+		ORDER BY scope, sample_size DESC, key`, scope)
+	if err != nil {
+		log.Printf("Error loading accuracy metrics: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load accuracy metrics"})
+		return
+	}
+	c.JSON(http.StatusOK, metrics)
+}
+
+// ── Accuracy Job ────────────────────────────
+
+// StartAccuracyJob periodically scores reported outcomes against the advice
+// that was given and refreshes the per-market / per-crop metrics.
+func StartAccuracyJob(db *sqlx.DB) {
+	if db == nil {
+		log.Println("Accuracy job disabled: Database connection is nil.")
+		return
+	}
+
+	ticker := time.NewTicker(6 * time.Hour)
+	go func() {
+		runAccuracyJob(db)
+		for range ticker.C {
+			runAccuracyJob(db)
+		}
+	}()
+}
+
+func runAccuracyJob(db *sqlx.DB) {
+	log.Println("[accuracy] Scoring reported outcomes...")
+
+	var pending []RecommendationOutcome
+	err := db.Select(&pending, "SELECT "+outcomeColumns+" FROM recommendation_outcomes WHERE evaluated_at IS NULL")
+	if err != nil {
+		log.Printf("[accuracy] Failed to load pending outcomes: %v", err)
+		return
+	}
+
+	for _, o := range pending {
+		var row recommendationRow
+		if err := db.Get(&row, "SELECT id, payload FROM recommendations WHERE id = $1", o.RecommendationID); err != nil {
+			log.Printf("[accuracy] Failed to load recommendation %s: %v", o.RecommendationID, err)
+			continue
+		}
+		rec, err := row.decode()
+		if err != nil {
+			log.Printf("[accuracy] %v", err)
+			continue
+		}
+
+		inBand, priceErrPct, profitErr := scoreOutcome(rec, o)
+		_, err = db.Exec(`
+			UPDATE recommendation_outcomes
+			SET in_band = $2, price_error_pct = $3, profit_error = $4, evaluated_at = NOW()
+			WHERE id = $1`, o.ID, inBand, priceErrPct, profitErr)
+		if err != nil {
+			log.Printf("[accuracy] Failed to store score for outcome %s: %v", o.ID, err)
+		}
+	}
+
+	// Rebuild the aggregate snapshot in one transaction so readers never see
+	// a half-written table.
+	tx, err := db.Beginx()
+	if err != nil {
+		log.Printf("[accuracy] Failed to begin transaction: %v", err)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM accuracy_metrics"); err != nil {
+		log.Printf("[accuracy] Failed to clear metrics: %v", err)
+		return
+	}
+	for scope, keyExpr := range map[string]string{"market": "o.market_name", "crop": "r.crop_name"} {
+		_, err := tx.Exec(`
+			INSERT INTO accuracy_metrics (scope, key, sample_size, band_hit_rate, price_mape, mean_profit_error, computed_at)
+			SELECT $1, `+keyExpr+`, COUNT(*),
+			       AVG(CASE WHEN o.in_band THEN 1.0 ELSE 0.0 END),
+			       AVG(ABS(o.price_error_pct)),
+			       AVG(o.profit_error),
+			       NOW()
+			FROM recommendation_outcomes o
+			JOIN recommendations r ON r.id = o.recommendation_id
+			WHERE o.evaluated_at IS NOT NULL
+			GROUP BY `+keyExpr, scope)
+		if err != nil {
+			log.Printf("[accuracy] Failed to aggregate %s metrics: %v", scope, err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("[accuracy] Failed to commit metrics: %v", err)
+		return
+	}
+
+	log.Printf("[accuracy] Scored %d new outcomes and refreshed metrics.", len(pending))
+}
+
+// scoreOutcome compares a realised sale with the advice that preceded it.
+// The predicted price and net-profit estimate come from the market the farmer
+// actually sold at when it was among the options, otherwise from the
+// recommended market.
+func scoreOutcome(rec Recommendation, o RecommendationOutcome) (bool, float64, float64) {
+	inBand := o.RealisedPrice >= rec.ConfidenceBandMin && o.RealisedPrice <= rec.ConfidenceBandMax
+
+	var chosen *MarketOption
+	for i := range rec.Markets {
+		m := &rec.Markets[i]
+		if strings.EqualFold(m.MarketName, o.MarketName) {
+			chosen = m
+			break
+		}
+		if chosen == nil && m.MarketName == rec.RecommendedMarket {
+			chosen = m
+		}
+	}
+	if chosen == nil || chosen.CurrentPrice <= 0 {
+		return inBand, 0, 0
+	}
+
+	priceErrPct := (o.RealisedPrice - chosen.CurrentPrice) / chosen.CurrentPrice * 100
+
+	// Realised net per quintal: price discounted by the share that spoiled,
+	// minus the transport cost the model assumed for that market.
+	spoiledShare := 0.0
+	if total := o.QuantitySoldKg + o.QuantitySpoiledKg; total > 0 {
+		spoiledShare = o.QuantitySpoiledKg / total
+	}
+	realisedNet := o.RealisedPrice*(1-spoiledShare) - chosen.TransportCost
+	profitErr := realisedNet - chosen.NetProfitEstimate
+
+	return inBand, math.Round(priceErrPct*100) / 100, math.Round(profitErr*100) / 100
+}
+
+// recordWhatsAppOutcome parses "MarketName Price SoldKg SpoiledKg" from a
+// SOLD message and links it to the sender's most recent recommendation.
+func recordWhatsAppOutcome(phone string, parts []string) {
+	if db == nil {
+		return
+	}
+	if len(parts) < 4 {
+		log.Printf("⚠ Ignoring malformed SOLD message from %s", phone)
+		return
+	}
+
+	nums := make([]float64, 3)
+	for i, p := range parts[len(parts)-3:] {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			log.Printf("⚠ Ignoring SOLD message from %s: %q is not a number", phone, p)
+			return
+		}
+		nums[i] = v
+	}
+	in := OutcomeInput{
+		MarketName:        strings.Join(parts[:len(parts)-3], " "),
+		RealisedPrice:     nums[0],
+		QuantitySoldKg:    nums[1],
+		QuantitySpoiledKg: nums[2],
+		Source:            "whatsapp",
+	}
+	if err := in.validate(); err != nil {
+		log.Printf("⚠ Ignoring SOLD message from %s: %v", phone, err)
+		return
+	}
+
+	// WhatsApp delivers the sender as bare international digits.
+	if !strings.HasPrefix(phone, "+") {
+		phone = "+" + phone
+	}
+	var row recommendationRow
+	err := db.Get(&row, `
+		SELECT r.id, r.payload
+		FROM recommendations r
+		JOIN farmers f ON f.id = r.farmer_id
+		WHERE f.phone = $1
+		ORDER BY r.generated_at DESC
+		LIMIT 1`, phone)
+	if err != nil {
+		log.Printf("⚠ No recommendation found for SOLD message from %s: %v", phone, err)
+		return
+	}
+	rec, err := row.decode()
+	if err != nil {
+		log.Printf("⚠ %v", err)
+		return
+	}
+
+	if _, err := recordOutcome(rec, in); err != nil {
+		log.Printf("Error recording WhatsApp outcome from %s: %v", phone, err)
+		return
+	}
+	log.Printf("✅ Outcome ping registered: %s sold at %s for ₹%.2f (recommendation %s)", phone, in.MarketName, in.RealisedPrice, rec.ID)
+}
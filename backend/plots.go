@@ -0,0 +1,407 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ══════════════════════════════════════════════
+//  FARM PLOTS (Fields, Crops & Seasons)
+// ══════════════════════════════════════════════
+
+var (
+	errPlotNotFound    = errors.New("plot not found")
+	errInvalidBoundary = errors.New("invalid plot boundary")
+)
+
+var irrigationTypes = map[string]bool{
+	"rainfed":   true,
+	"canal":     true,
+	"tubewell":  true,
+	"drip":      true,
+	"sprinkler": true,
+}
+
+const (
+	maxPlotAcres        = 1000
+	maxBoundaryPoints   = 500
+	squareMetresPerAcre = 4046.8564224
+	// maturityWindowDays either side of the expected harvest date counts as
+	// "Optimal"; later than that the crop is "Late".
+	maturityWindowDays = 7
+)
+
+const plotColumns = `p.id, p.farmer_id, p.name, p.crop_id, c.name AS crop_name,
+	ST_Y(p.location::geometry) AS lat, ST_X(p.location::geometry) AS lon,
+	COALESCE(ST_AsGeoJSON(p.boundary), '') AS boundary_geojson, p.area_acres,
+	to_char(p.sowing_date, 'YYYY-MM-DD') AS sowing_date,
+	COALESCE(to_char(p.expected_harvest_date, 'YYYY-MM-DD'), '') AS expected_harvest_date,
+	p.irrigation_type, p.expected_yield, p.yield_unit, p.created_at, p.updated_at`
+
+// validate checks the payload and fills in defaults.
+func (in *PlotInput) validate() error {
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" || len(in.Name) > 100 {
+		return fmt.Errorf("name is required (at most 100 characters)")
+	}
+	if !isValidUUID(in.CropID) {
+		return fmt.Errorf("crop_id must be a valid UUID")
+	}
+
+	if len(in.Boundary) > 0 {
+		if len(in.Boundary) < 3 || len(in.Boundary) > maxBoundaryPoints {
+			return fmt.Errorf("boundary must have between 3 and %d points", maxBoundaryPoints)
+		}
+		for _, p := range in.Boundary {
+			if err := validateLocation(p.Lat, p.Lon); err != nil {
+				return fmt.Errorf("boundary: %w", err)
+			}
+		}
+	} else {
+		if in.Lat == nil || in.Lon == nil {
+			return fmt.Errorf("either lat and lon or a boundary is required")
+		}
+		if err := validateLocation(*in.Lat, *in.Lon); err != nil {
+			return err
+		}
+		if in.AreaAcres == nil {
+			return fmt.Errorf("area_acres is required when no boundary is given")
+		}
+	}
+	if in.AreaAcres != nil && (*in.AreaAcres <= 0 || *in.AreaAcres > maxPlotAcres) {
+		return fmt.Errorf("area_acres must be above 0 and at most %d", maxPlotAcres)
+	}
+
+	sowing, err := time.Parse("2006-01-02", in.SowingDate)
+	if err != nil {
+		return fmt.Errorf("sowing_date is required (YYYY-MM-DD)")
+	}
+	if in.ExpectedHarvestDate != "" {
+		harvest, err := time.Parse("2006-01-02", in.ExpectedHarvestDate)
+		if err != nil {
+			return fmt.Errorf("expected_harvest_date must be YYYY-MM-DD")
+		}
+		if !harvest.After(sowing) {
+			return fmt.Errorf("expected_harvest_date must be after sowing_date")
+		}
+	}
+
+	in.IrrigationType = strings.ToLower(strings.TrimSpace(in.IrrigationType))
+	if in.IrrigationType == "" {
+		in.IrrigationType = "rainfed"
+	}
+	if !irrigationTypes[in.IrrigationType] {
+		return fmt.Errorf("irrigation_type must be one of: rainfed, canal, tubewell, drip, sprinkler")
+	}
+	if in.YieldUnit == "" {
+		in.YieldUnit = "quintal"
+	}
+	if in.ExpectedYield != nil {
+		if _, err := quantityToKg(*in.ExpectedYield, in.YieldUnit); err != nil {
+			return fmt.Errorf("expected_yield: %w", err)
+		}
+	} else if _, ok := kgPerUnit[in.YieldUnit]; !ok {
+		return fmt.Errorf("yield_unit must be one of: kg, quintal, tonne")
+	}
+	return nil
+}
+
+// boundaryWKT renders the boundary as a closed WKT polygon ring.
+func boundaryWKT(points []GeoPoint) string {
+	if len(points) == 0 {
+		return ""
+	}
+	ring := append([]GeoPoint{}, points...)
+	if ring[0] != ring[len(ring)-1] {
+		ring = append(ring, ring[0])
+	}
+	coords := make([]string, len(ring))
+	for i, p := range ring {
+		coords[i] = fmt.Sprintf("%f %f", p.Lon, p.Lat)
+	}
+	return "POLYGON((" + strings.Join(coords, ", ") + "))"
+}
+
+// resolvePlotShape checks a drawn boundary with PostGIS and fills in the
+// area from it when the farmer did not give one.
+func resolvePlotShape(in *PlotInput) (string, error) {
+	wkt := boundaryWKT(in.Boundary)
+	if wkt == "" {
+		return "", nil
+	}
+	var shape struct {
+		Valid bool    `db:"valid"`
+		Acres float64 `db:"acres"`
+	}
+	err := db.Get(&shape, `
+		SELECT ST_IsValid(g) AS valid, ST_Area(g::geography) / $2 AS acres
+		FROM (SELECT ST_GeomFromText($1, 4326) AS g) s`, wkt, squareMetresPerAcre)
+	if err != nil {
+		return "", fmt.Errorf("check plot boundary: %w", err)
+	}
+	if !shape.Valid {
+		return "", fmt.Errorf("%w: boundary must not cross itself", errInvalidBoundary)
+	}
+	if in.AreaAcres == nil {
+		if shape.Acres <= 0 || shape.Acres > maxPlotAcres {
+			return "", fmt.Errorf("%w: boundary encloses %.2f acres; plots must be above 0 and at most %d acres", errInvalidBoundary, shape.Acres, maxPlotAcres)
+		}
+		acres := round2(shape.Acres)
+		in.AreaAcres = &acres
+	}
+	return wkt, nil
+}
+
+// decodeBoundary fills Boundary from the stored GeoJSON polygon, without
+// the closing point.
+func (p *Plot) decodeBoundary() {
+	if p.BoundaryGeoJSON == "" {
+		return
+	}
+	var g struct {
+		Coordinates [][][2]float64 `json:"coordinates"`
+	}
+	if err := json.Unmarshal([]byte(p.BoundaryGeoJSON), &g); err != nil || len(g.Coordinates) == 0 {
+		log.Printf("⚠ Plot %s has an unreadable boundary: %v", p.ID, err)
+		return
+	}
+	ring := g.Coordinates[0]
+	if n := len(ring); n > 1 && ring[0] == ring[n-1] {
+		ring = ring[:n-1]
+	}
+	p.Boundary = make([]GeoPoint, len(ring))
+	for i, c := range ring {
+		p.Boundary[i] = GeoPoint{Lat: c[1], Lon: c[0]}
+	}
+}
+
+// fetchPlot loads a plot with its crop name.
+func fetchPlot(id string) (Plot, error) {
+	if db == nil || !isValidUUID(id) {
+		return Plot{}, errPlotNotFound
+	}
+	var p Plot
+	err := db.Get(&p, "SELECT "+plotColumns+`
+		FROM farm_plots p JOIN crops c ON c.id = p.crop_id
+		WHERE p.id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Plot{}, errPlotNotFound
+	}
+	if err != nil {
+		return Plot{}, fmt.Errorf("fetch plot %s: %w", id, err)
+	}
+	p.decodeBoundary()
+	return p, nil
+}
+
+// respondPlotError maps a fetchPlot error onto an HTTP response.
+func respondPlotError(c *gin.Context, id string, err error) {
+	if errors.Is(err, errPlotNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("plot %s not found", id)})
+		return
+	}
+	log.Printf("⚠ DB fetch plot failed: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load plot"})
+}
+
+// plotMaturity derives crop_maturity and the advisory growth stage from the
+// plot's calendar season. It backs the GDD model (growth.go) when no
+// temperature data is available; without an expected harvest date the
+// request defaults apply.
+func plotMaturity(p Plot, today time.Time) (maturity, stage string) {
+	sowing, err1 := time.Parse("2006-01-02", p.SowingDate)
+	harvest, err2 := time.Parse("2006-01-02", p.ExpectedHarvestDate)
+	if err1 != nil || err2 != nil {
+		return "Optimal", "maturity"
+	}
+	daysToHarvest := int(math.Round(harvest.Sub(today).Hours() / 24))
+	return maturityForDays(daysToHarvest), stageForProgress(today.Sub(sowing).Hours() / harvest.Sub(sowing).Hours())
+}
+
+// ── Handlers ────────────────────────────────
+
+func handleListPlots(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	farmerID := c.Param("id")
+	if _, err := fetchFarmer(farmerID); err != nil {
+		respondFarmerError(c, farmerID, err)
+		return
+	}
+
+	plots := []Plot{}
+	err := db.Select(&plots, "SELECT "+plotColumns+`
+		FROM farm_plots p JOIN crops c ON c.id = p.crop_id
+		WHERE p.farmer_id = $1
+		ORDER BY p.created_at`, farmerID)
+	if err != nil {
+		log.Printf("Error listing plots for farmer %s: %v", farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list plots"})
+		return
+	}
+	for i := range plots {
+		plots[i].decodeBoundary()
+	}
+	c.JSON(http.StatusOK, plots)
+}
+
+// bindPlotInput reads and validates a plot payload, writing the error
+// response itself. It returns the boundary as WKT ("" for a point plot).
+func bindPlotInput(c *gin.Context, in *PlotInput) (string, bool) {
+	if err := c.ShouldBindJSON(in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload format"})
+		return "", false
+	}
+	if err := in.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	if _, err := fetchCrop(in.CropID); err != nil {
+		respondCropError(c, in.CropID, err)
+		return "", false
+	}
+	wkt, err := resolvePlotShape(in)
+	if errors.Is(err, errInvalidBoundary) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	if err != nil {
+		log.Printf("Error checking plot boundary: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save plot"})
+		return "", false
+	}
+	return wkt, true
+}
+
+func handleCreatePlot(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	farmerID := c.Param("id")
+	if _, err := fetchFarmer(farmerID); err != nil {
+		respondFarmerError(c, farmerID, err)
+		return
+	}
+
+	var in PlotInput
+	wkt, ok := bindPlotInput(c, &in)
+	if !ok {
+		return
+	}
+
+	var id string
+	err := db.Get(&id, `
+		INSERT INTO farm_plots (farmer_id, name, crop_id, location, boundary, area_acres,
+			sowing_date, expected_harvest_date, irrigation_type, expected_yield, yield_unit)
+		SELECT $1, $2, $3,
+			COALESCE(ST_Centroid(g)::geography, ST_SetSRID(ST_MakePoint($4, $5), 4326)::geography),
+			g::geography, $7, $8, NULLIF($9, '')::date, $10, $11, $12
+		FROM (SELECT ST_GeomFromText(NULLIF($6, ''), 4326) AS g) s
+		RETURNING id`,
+		farmerID, in.Name, in.CropID, in.Lon, in.Lat, wkt, *in.AreaAcres,
+		in.SowingDate, in.ExpectedHarvestDate, in.IrrigationType, in.ExpectedYield, in.YieldUnit)
+	if err != nil {
+		log.Printf("Error inserting plot for farmer %s: %v", farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create plot"})
+		return
+	}
+	p, err := fetchPlot(id)
+	if err != nil {
+		respondPlotError(c, id, err)
+		return
+	}
+
+	log.Printf("🌾 Plot registered: %s (%s, %.2f acres of %s) for farmer %s", p.ID, p.Name, p.AreaAcres, p.CropName, farmerID)
+	c.JSON(http.StatusCreated, p)
+}
+
+func handleGetPlot(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	id := c.Param("id")
+	p, err := fetchPlot(id)
+	if err != nil {
+		respondPlotError(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// handleUpdatePlot replaces a plot's details, e.g. for the next season's crop.
+func handleUpdatePlot(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	id := c.Param("id")
+	if !isValidUUID(id) {
+		respondPlotError(c, id, errPlotNotFound)
+		return
+	}
+
+	var in PlotInput
+	wkt, ok := bindPlotInput(c, &in)
+	if !ok {
+		return
+	}
+
+	res, err := db.Exec(`
+		UPDATE farm_plots p
+		SET name = $2, crop_id = $3,
+			location = COALESCE(ST_Centroid(s.g)::geography, ST_SetSRID(ST_MakePoint($4, $5), 4326)::geography),
+			boundary = s.g::geography, area_acres = $7, sowing_date = $8,
+			expected_harvest_date = NULLIF($9, '')::date, irrigation_type = $10,
+			expected_yield = $11, yield_unit = $12, updated_at = NOW()
+		FROM (SELECT ST_GeomFromText(NULLIF($6, ''), 4326) AS g) s
+		WHERE p.id = $1`,
+		id, in.Name, in.CropID, in.Lon, in.Lat, wkt, *in.AreaAcres,
+		in.SowingDate, in.ExpectedHarvestDate, in.IrrigationType, in.ExpectedYield, in.YieldUnit)
+	if err != nil {
+		log.Printf("Error updating plot %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update plot"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondPlotError(c, id, errPlotNotFound)
+		return
+	}
+	p, err := fetchPlot(id)
+	if err != nil {
+		respondPlotError(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func handleDeletePlot(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	id := c.Param("id")
+	if !isValidUUID(id) {
+		respondPlotError(c, id, errPlotNotFound)
+		return
+	}
+
+	res, err := db.Exec("DELETE FROM farm_plots WHERE id = $1", id)
+	if err != nil {
+		log.Printf("Error deleting plot %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete plot"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondPlotError(c, id, errPlotNotFound)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ══════════════════════════════════════════════
+//  RECOMMENDATION HISTORY (Audit + Offline Replay)
+// ══════════════════════════════════════════════
+
+var errRecommendationNotFound = errors.New("recommendation not found")
+
+// saveRecommendation persists the full recommendation payload and stamps the
+// generated ID onto rec. It is a no-op in demo mode (no database).
+func saveRecommendation(rec *Recommendation) error {
+	if db == nil {
+		return nil
+	}
+
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("marshal recommendation: %w", err)
+	}
+
+	var id string
+	err = db.QueryRow(`
+		INSERT INTO recommendations (farmer_id, crop_id, crop_name, action, recommended_market, payload, generated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		rec.FarmerID, rec.Inputs.CropID, rec.CropName, rec.Action, rec.RecommendedMarket, payload, rec.GeneratedAt,
+	).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("insert recommendation: %w", err)
+	}
+	rec.ID = id
+	return nil
+}
+
+type recommendationRow struct {
+	ID      string `db:"id"`
+	Payload []byte `db:"payload"`
+}
+
+func (r recommendationRow) decode() (Recommendation, error) {
+	var rec Recommendation
+	if err := json.Unmarshal(r.Payload, &rec); err != nil {
+		return Recommendation{}, fmt.Errorf("decode recommendation %s: %w", r.ID, err)
+	}
+	rec.ID = r.ID
+	return rec, nil
+}
+
+func fetchRecommendation(id string) (Recommendation, error) {
+	if !isValidUUID(id) {
+		return Recommendation{}, errRecommendationNotFound
+	}
+	var row recommendationRow
+	err := db.Get(&row, "SELECT id, payload FROM recommendations WHERE id = $1", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Recommendation{}, errRecommendationNotFound
+	}
+	if err != nil {
+		return Recommendation{}, fmt.Errorf("fetch recommendation %s: %w", id, err)
+	}
+	return row.decode()
+}
+
+// ── Handlers ────────────────────────────────
+
+// handleListFarmerRecommendations returns a farmer's past advice, newest
+// first. Optional filters: crop_id, from and to (YYYY-MM-DD, inclusive).
+func handleListFarmerRecommendations(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	farmerID := c.Param("id")
+	if _, err := fetchFarmer(farmerID); err != nil {
+		respondFarmerError(c, farmerID, err)
+		return
+	}
+
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Unbounded defaults keep the query static; filters narrow it when present.
+	from := time.Time{}
+	to := time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	if s := c.Query("from"); s != "" {
+		if from, err = time.Parse("2006-01-02", s); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date in YYYY-MM-DD format"})
+			return
+		}
+	}
+	if s := c.Query("to"); s != "" {
+		if to, err = time.Parse("2006-01-02", s); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date in YYYY-MM-DD format"})
+			return
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	cropID := c.Query("crop_id")
+
+	const where = `
+		WHERE farmer_id = $1
+		  AND ($2 = '' OR crop_id::text = $2)
+		  AND generated_at >= $3 AND generated_at < $4`
+
+	var total int
+	if err := db.Get(&total, "SELECT COUNT(*) FROM recommendations"+where, farmerID, cropID, from, to); err != nil {
+		log.Printf("Error counting recommendations for %s: %v", farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list recommendations"})
+		return
+	}
+
+	var rows []recommendationRow
+	err = db.Select(&rows, "SELECT id, payload FROM recommendations"+where+`
+		ORDER BY generated_at DESC
+		LIMIT $5 OFFSET $6`, farmerID, cropID, from, to, limit, offset)
+	if err != nil {
+		log.Printf("Error listing recommendations for %s: %v", farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list recommendations"})
+		return
+	}
+
+	items := make([]Recommendation, 0, len(rows))
+	for _, r := range rows {
+		rec, err := r.decode()
+		if err != nil {
+			log.Printf("⚠ %v", err)
+			continue
+		}
+		items = append(items, rec)
+	}
+
+	c.JSON(http.StatusOK, RecommendationPage{
+		Items:  items,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+func handleGetRecommendation(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+
+	id := c.Param("id")
+	rec, err := fetchRecommendation(id)
+	if errors.Is(err, errRecommendationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("recommendation %s not found", id)})
+		return
+	}
+	if err != nil {
+		log.Printf("⚠ DB fetch recommendation failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load recommendation"})
+		return
+	}
+	c.JSON(http.StatusOK, rec)
+}
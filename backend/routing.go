@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ══════════════════════════════════════════════
+//  ROUTING (OSRM / Valhalla + PostgreSQL Cache)
+// ══════════════════════════════════════════════
+
+const (
+	publicOSRMURL       = "http://router.project-osrm.org"
+	routeCoordDecimals  = 2   // ~1 km: farmer GPS jitter still hits the cache
+	haversineSpeedKmh   = 40  // average rural road speed for estimates
+	defaultRouteTTLHrs  = 168 // road network changes slowly
+	routingTimeoutSecs  = 10
+	valhallaCostingMode = "auto" // car times, like OSRM's driving profile; vehicles scale them by SpeedFactor
+)
+
+// routeLeg is one origin→destination result; nil entries in a Router's
+// result mean the pair could not be routed.
+type routeLeg struct {
+	DurationHr float64
+	DistanceKm float64
+}
+
+// Router returns driving durations from one origin to many destinations in
+// a single request. Points are [lat, lon].
+type Router interface {
+	Name() string
+	Table(origin [2]float64, dests [][2]float64) ([]*routeLeg, error)
+}
+
+// ── OSRM ────────────────────────────────────
+
+// OSRMRouter uses the /table service of an OSRM server.
+type OSRMRouter struct {
+	BaseURL string
+}
+
+func (OSRMRouter) Name() string { return "osrm" }
+
+func (r OSRMRouter) Table(origin [2]float64, dests [][2]float64) ([]*routeLeg, error) {
+	coords := make([]string, 0, len(dests)+1)
+	for _, p := range append([][2]float64{origin}, dests...) {
+		coords = append(coords, fmt.Sprintf("%.5f,%.5f", p[1], p[0])) // OSRM wants lon,lat
+	}
+	url := fmt.Sprintf("%s/table/v1/driving/%s?sources=0&annotations=duration,distance",
+		strings.TrimRight(r.BaseURL, "/"), strings.Join(coords, ";"))
+
+	client := &http.Client{Timeout: routingTimeoutSecs * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("osrm request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("osrm returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Code      string       `json:"code"`
+		Durations [][]*float64 `json:"durations"` // seconds
+		Distances [][]*float64 `json:"distances"` // metres
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse osrm JSON: %w", err)
+	}
+	if result.Code != "Ok" || len(result.Durations) == 0 || len(result.Durations[0]) != len(dests)+1 {
+		return nil, fmt.Errorf("osrm table returned code %q", result.Code)
+	}
+
+	// A missing or short distances row leaves DistanceKm at 0 (haversine fallback)
+	hasDistances := len(result.Distances) > 0 && len(result.Distances[0]) == len(dests)+1
+
+	legs := make([]*routeLeg, len(dests))
+	for i := range dests {
+		dur := result.Durations[0][i+1]
+		if dur == nil {
+			continue
+		}
+		leg := &routeLeg{DurationHr: *dur / 3600}
+		if hasDistances && result.Distances[0][i+1] != nil {
+			leg.DistanceKm = *result.Distances[0][i+1] / 1000
+		}
+		legs[i] = leg
+	}
+	return legs, nil
+}
+
+// ── Valhalla ────────────────────────────────
+
+// ValhallaRouter uses the /sources_to_targets matrix service of Valhalla.
+type ValhallaRouter struct {
+	BaseURL string
+}
+
+func (ValhallaRouter) Name() string { return "valhalla" }
+
+func (r ValhallaRouter) Table(origin [2]float64, dests [][2]float64) ([]*routeLeg, error) {
+	type loc struct {
+		Lat float64 `json:"lat"`
+		Lon float64 `json:"lon"`
+	}
+	targets := make([]loc, len(dests))
+	for i, d := range dests {
+		targets[i] = loc{Lat: d[0], Lon: d[1]}
+	}
+	body, err := json.Marshal(map[string]any{
+		"sources": []loc{{Lat: origin[0], Lon: origin[1]}},
+		"targets": targets,
+		"costing": valhallaCostingMode,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: routingTimeoutSecs * time.Second}
+	resp, err := client.Post(strings.TrimRight(r.BaseURL, "/")+"/sources_to_targets", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("valhalla request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("valhalla returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		SourcesToTargets [][]struct {
+			ToIndex  int      `json:"to_index"`
+			Time     *float64 `json:"time"`     // seconds
+			Distance *float64 `json:"distance"` // km
+		} `json:"sources_to_targets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse valhalla JSON: %w", err)
+	}
+	if len(result.SourcesToTargets) == 0 {
+		return nil, fmt.Errorf("valhalla returned an empty matrix")
+	}
+
+	legs := make([]*routeLeg, len(dests))
+	for _, cell := range result.SourcesToTargets[0] {
+		if cell.Time == nil || cell.ToIndex < 0 || cell.ToIndex >= len(dests) {
+			continue
+		}
+		leg := &routeLeg{DurationHr: *cell.Time / 3600}
+		if cell.Distance != nil {
+			leg.DistanceKm = *cell.Distance
+		}
+		legs[cell.ToIndex] = leg
+	}
+	return legs, nil
+}
+
+// ── Configuration ───────────────────────────
+
+var (
+	routingConfigOnce sync.Once
+	activeRouter      Router
+	routeCacheTTL     time.Duration
+)
+
+// loadRoutingConfig reads ROUTING_BACKEND (osrm or valhalla, default osrm),
+// ROUTING_URL (default: the public OSRM demo server, which is rate limited
+// and meant for testing only) and ROUTE_CACHE_TTL_HOURS.
+func loadRoutingConfig() {
+	url := os.Getenv("ROUTING_URL")
+	switch backend := os.Getenv("ROUTING_BACKEND"); backend {
+	case "valhalla":
+		if url == "" {
+			log.Println("⚠ ROUTING_BACKEND=valhalla needs ROUTING_URL – falling back to public OSRM")
+			activeRouter = OSRMRouter{BaseURL: publicOSRMURL}
+		} else {
+			activeRouter = ValhallaRouter{BaseURL: url}
+		}
+	case "", "osrm":
+		if url == "" {
+			log.Println("⚠ ROUTING_URL not set – using the public OSRM demo server (not for production)")
+			url = publicOSRMURL
+		}
+		activeRouter = OSRMRouter{BaseURL: url}
+	default:
+		log.Printf("⚠ Unknown ROUTING_BACKEND %q – using OSRM", backend)
+		if url == "" {
+			url = publicOSRMURL
+		}
+		activeRouter = OSRMRouter{BaseURL: url}
+	}
+
+	routeCacheTTL = defaultRouteTTLHrs * time.Hour
+	if s := os.Getenv("ROUTE_CACHE_TTL_HOURS"); s != "" {
+		if h, err := strconv.Atoi(s); err == nil && h > 0 {
+			routeCacheTTL = time.Duration(h) * time.Hour
+		} else {
+			log.Printf("⚠ Invalid ROUTE_CACHE_TTL_HOURS %q – using %d", s, defaultRouteTTLHrs)
+		}
+	}
+}
+
+// ── Transit Matrix ──────────────────────────
+
+// TransitEstimate is the farm→market leg used for scoring.
+type TransitEstimate struct {
+	Hours      float64
+	DistanceKm float64
+	Source     string // "routed" (road network, possibly cached) or "haversine"
+}
+
+func roundCoord(v float64) float64 {
+	p := math.Pow(10, routeCoordDecimals)
+	return math.Round(v*p) / p
+}
+
+func routeKey(lat, lon float64) string {
+	return fmt.Sprintf("%.*f,%.*f", routeCoordDecimals, lat, routeCoordDecimals, lon)
+}
+
+// haversineEstimate is the straight-line fallback at an average road speed.
+func haversineEstimate(fromLat, fromLon, toLat, toLon float64) TransitEstimate {
+	dist := haversine(fromLat, fromLon, toLat, toLon)
+	return TransitEstimate{Hours: dist / haversineSpeedKmh, DistanceKm: dist, Source: "haversine"}
+}
+
+// fetchTransitMatrix returns farm→market legs for every destination. Cached
+// routes are reused; the rest come from one routing /table call and are
+// cached by rounded coordinates. Unroutable pairs fall back to haversine.
+func fetchTransitMatrix(originLat, originLon float64, dests [][2]float64) []TransitEstimate {
+	routingConfigOnce.Do(loadRoutingConfig)
+	origin := [2]float64{roundCoord(originLat), roundCoord(originLon)}
+
+	out := make([]TransitEstimate, len(dests))
+	cached := map[string]routeLeg{}
+	if db != nil {
+		var rows []struct {
+			DestLat    float64 `db:"dest_lat"`
+			DestLon    float64 `db:"dest_lon"`
+			DurationHr float64 `db:"duration_hr"`
+			DistanceKm float64 `db:"distance_km"`
+		}
+		err := db.Select(&rows, `
+			SELECT dest_lat, dest_lon, duration_hr, distance_km
+			FROM route_cache
+			WHERE backend = $1 AND origin_lat = $2 AND origin_lon = $3
+			  AND fetched_at > NOW() - make_interval(secs => $4)`,
+			activeRouter.Name(), origin[0], origin[1], routeCacheTTL.Seconds())
+		if err != nil {
+			log.Printf("⚠ DB fetch route cache failed: %v", err)
+		}
+		for _, r := range rows {
+			cached[routeKey(r.DestLat, r.DestLon)] = routeLeg{DurationHr: r.DurationHr, DistanceKm: r.DistanceKm}
+		}
+	}
+
+	// Collect the distinct destinations the cache does not cover.
+	var missing [][2]float64
+	missingIdx := map[string]int{}
+	for _, d := range dests {
+		key := routeKey(roundCoord(d[0]), roundCoord(d[1]))
+		if _, ok := cached[key]; ok {
+			continue
+		}
+		if _, ok := missingIdx[key]; !ok {
+			missingIdx[key] = len(missing)
+			missing = append(missing, [2]float64{roundCoord(d[0]), roundCoord(d[1])})
+		}
+	}
+
+	var fresh []*routeLeg
+	if len(missing) > 0 {
+		var err error
+		fresh, err = activeRouter.Table(origin, missing)
+		if err != nil {
+			log.Printf("⚠ %s table failed – using haversine fallback: %v", activeRouter.Name(), err)
+			fresh = nil
+		}
+		for i, leg := range fresh {
+			if leg == nil || db == nil {
+				continue
+			}
+			_, err := db.Exec(`
+				INSERT INTO route_cache (backend, origin_lat, origin_lon, dest_lat, dest_lon, duration_hr, distance_km, fetched_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+				ON CONFLICT (backend, origin_lat, origin_lon, dest_lat, dest_lon) DO UPDATE SET
+					duration_hr = EXCLUDED.duration_hr, distance_km = EXCLUDED.distance_km, fetched_at = NOW()`,
+				activeRouter.Name(), origin[0], origin[1], missing[i][0], missing[i][1], leg.DurationHr, leg.DistanceKm)
+			if err != nil {
+				log.Printf("⚠ DB store route cache failed: %v", err)
+			}
+		}
+	}
+
+	for i, d := range dests {
+		key := routeKey(roundCoord(d[0]), roundCoord(d[1]))
+		leg, ok := cached[key]
+		if !ok {
+			if j, pending := missingIdx[key]; pending && j < len(fresh) && fresh[j] != nil {
+				leg, ok = *fresh[j], true
+			}
+		}
+		if !ok {
+			out[i] = haversineEstimate(originLat, originLon, d[0], d[1])
+			continue
+		}
+		if leg.DistanceKm == 0 {
+			leg.DistanceKm = haversine(originLat, originLon, d[0], d[1])
+		}
+		out[i] = TransitEstimate{Hours: leg.DurationHr, DistanceKm: leg.DistanceKm, Source: "routed"}
+	}
+	return out
+}
@@ -15,6 +15,36 @@ CREATE TABLE IF NOT EXISTS mandis (
     location GEOGRAPHY(Point, 4326) NOT NULL
 );
 
+-- Mandi registry: verified coordinates and LGD state/district codes come from
+-- a gazetteer import (`go run . import-mandis`). Mandis first seen by
+-- ingestion have no location and are excluded from scoring until resolved.
+ALTER TABLE mandis ADD COLUMN IF NOT EXISTS state VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE mandis ADD COLUMN IF NOT EXISTS district VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE mandis ADD COLUMN IF NOT EXISTS state_code VARCHAR(10) NOT NULL DEFAULT '';
+ALTER TABLE mandis ADD COLUMN IF NOT EXISTS district_code VARCHAR(10) NOT NULL DEFAULT '';
+ALTER TABLE mandis ADD COLUMN IF NOT EXISTS location_resolved BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE mandis ADD COLUMN IF NOT EXISTS location_source VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE mandis ADD COLUMN IF NOT EXISTS created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE mandis ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE mandis ALTER COLUMN location DROP NOT NULL;
+
+-- Markets whose coordinates were verified by hand. Everything else the old
+-- static dictionary placed (state centroids, offsets from Delhi) stays unresolved.
+INSERT INTO mandis (name, state, district, state_code, location, location_resolved, location_source) VALUES
+    ('Azadpur',    'NCT of Delhi',   '',        '07', ST_SetSRID(ST_MakePoint(77.1525, 28.7041), 4326)::geography, TRUE, 'manual'),
+    ('Ghazipur',   'NCT of Delhi',   '',        '07', ST_SetSRID(ST_MakePoint(77.3230, 28.6233), 4326)::geography, TRUE, 'manual'),
+    ('Narela',     'NCT of Delhi',   '',        '07', ST_SetSRID(ST_MakePoint(77.0932, 28.8526), 4326)::geography, TRUE, 'manual'),
+    ('Vashi',      'Maharashtra',    'Thane',   '27', ST_SetSRID(ST_MakePoint(73.0169, 19.0728), 4326)::geography, TRUE, 'manual'),
+    ('Pune',       'Maharashtra',    'Pune',    '27', ST_SetSRID(ST_MakePoint(73.8567, 18.5204), 4326)::geography, TRUE, 'manual'),
+    ('Nashik',     'Maharashtra',    'Nashik',  '27', ST_SetSRID(ST_MakePoint(73.7900, 20.0059), 4326)::geography, TRUE, 'manual'),
+    ('Doharighat', 'Uttar Pradesh',  'Mau',     '09', ST_SetSRID(ST_MakePoint(83.5822, 26.2736), 4326)::geography, TRUE, 'manual'),
+    ('Kolar',      'Karnataka',      'Kolar',   '29', ST_SetSRID(ST_MakePoint(78.1292, 13.1367), 4326)::geography, TRUE, 'manual'),
+    ('Chittoor',   'Andhra Pradesh', 'Chittoor','28', ST_SetSRID(ST_MakePoint(79.1003, 13.2172), 4326)::geography, TRUE, 'manual')
+ON CONFLICT (name) DO UPDATE SET
+    state = EXCLUDED.state, district = EXCLUDED.district, state_code = EXCLUDED.state_code,
+    location = EXCLUDED.location, location_resolved = TRUE, location_source = EXCLUDED.location_source
+WHERE NOT mandis.location_resolved;
+
 -- Daily Prices table
 CREATE TABLE IF NOT EXISTS daily_prices (
     id SERIAL PRIMARY KEY,
@@ -25,6 +55,37 @@ CREATE TABLE IF NOT EXISTS daily_prices (
     UNIQUE(mandi_id, crop_name, recorded_at)
 );
 
+-- Full Agmarknet price triple per variety/grade, keyed on the mandi's real
+-- trading date rather than the ingest time.
+ALTER TABLE daily_prices ADD COLUMN IF NOT EXISTS min_price DECIMAL(10, 2);
+ALTER TABLE daily_prices ADD COLUMN IF NOT EXISTS max_price DECIMAL(10, 2);
+ALTER TABLE daily_prices ADD COLUMN IF NOT EXISTS variety VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE daily_prices ADD COLUMN IF NOT EXISTS grade VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE daily_prices ADD COLUMN IF NOT EXISTS arrival_date DATE;
+UPDATE daily_prices SET arrival_date = recorded_at::date WHERE arrival_date IS NULL;
+ALTER TABLE daily_prices ALTER COLUMN arrival_date SET DEFAULT CURRENT_DATE;
+ALTER TABLE daily_prices ALTER COLUMN arrival_date SET NOT NULL;
+
+-- Legacy rows were stamped per ingest run; keep the latest one per trading day.
+DELETE FROM daily_prices a USING daily_prices b
+WHERE a.mandi_id = b.mandi_id AND a.crop_name = b.crop_name
+  AND a.variety = b.variety AND a.grade = b.grade
+  AND a.arrival_date = b.arrival_date AND a.id < b.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_daily_prices_trading_day
+    ON daily_prices(mandi_id, crop_name, variety, grade, arrival_date);
+
+-- Mandi Arrivals table: measured daily arrival volumes per mandi and crop.
+CREATE TABLE IF NOT EXISTS mandi_arrivals (
+    id SERIAL PRIMARY KEY,
+    mandi_id INTEGER NOT NULL REFERENCES mandis(id),
+    crop_name VARCHAR(100) NOT NULL,
+    arrival_date DATE NOT NULL,
+    arrivals_tonnes DECIMAL(12, 2) NOT NULL,
+    recorded_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(mandi_id, crop_name, arrival_date)
+);
+
 -- Weather Cache table
 CREATE TABLE IF NOT EXISTS weather_cache (
     id SERIAL PRIMARY KEY,
@@ -35,6 +96,82 @@ CREATE TABLE IF NOT EXISTS weather_cache (
     UNIQUE(geohash, recorded_at)
 );
 
+-- One row per geohash cell and observation; the cell centre is stored as a
+-- geography so requests can pick the nearest fresh reading. Rows written
+-- before the grid existed have no location and are dropped.
+ALTER TABLE weather_cache ADD COLUMN IF NOT EXISTS location GEOGRAPHY(Point, 4326);
+DELETE FROM weather_cache WHERE location IS NULL;
+ALTER TABLE weather_cache ALTER COLUMN location SET NOT NULL;
+
+-- Full current conditions, kept as an hourly series (30-day retention) so
+-- spoilage can use humidity and heat accumulated over the last days.
+ALTER TABLE weather_cache ADD COLUMN IF NOT EXISTS weather_code INTEGER;
+ALTER TABLE weather_cache ADD COLUMN IF NOT EXISTS precipitation_mm DECIMAL(6,2);
+ALTER TABLE weather_cache ADD COLUMN IF NOT EXISTS wind_kmh DECIMAL(5,2);
+ALTER TABLE weather_cache ADD COLUMN IF NOT EXISTS dew_point DECIMAL(5,2);
+
+-- Weather Forecast table: Open-Meteo daily forecast per grid cell, replaced
+-- on every hourly run. Drives harvest-window selection.
+CREATE TABLE IF NOT EXISTS weather_forecast_daily (
+    geohash VARCHAR(20) NOT NULL,
+    forecast_date DATE NOT NULL,
+    location GEOGRAPHY(Point, 4326) NOT NULL,
+    precip_prob_pct INTEGER NOT NULL,
+    precip_mm DECIMAL(6,2) NOT NULL,
+    temp_max DECIMAL(5,2) NOT NULL,
+    temp_min DECIMAL(5,2) NOT NULL,
+    wind_max_kmh DECIMAL(5,2) NOT NULL,
+    fetched_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (geohash, forecast_date)
+);
+
+ALTER TABLE weather_forecast_daily ADD COLUMN IF NOT EXISTS et0_mm DECIMAL(5,2) NOT NULL DEFAULT 0;
+
+-- Weather Daily History table: observed daily temperature extremes per grid
+-- cell, backfilled from the Open-Meteo (ERA5) archive for crop growth tracking.
+CREATE TABLE IF NOT EXISTS weather_daily_history (
+    geohash     VARCHAR(20) NOT NULL,
+    obs_date    DATE NOT NULL,
+    temp_max    DECIMAL(5,2) NOT NULL,
+    temp_min    DECIMAL(5,2) NOT NULL,
+    source      VARCHAR(20) NOT NULL DEFAULT 'era5',
+    fetched_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (geohash, obs_date)
+);
+
+-- Route Cache table: road durations from the routing backend, keyed on
+-- coordinates rounded to 2 decimals (~1 km) and expired by ROUTE_CACHE_TTL_HOURS.
+CREATE TABLE IF NOT EXISTS route_cache (
+    backend VARCHAR(20) NOT NULL,
+    origin_lat DOUBLE PRECISION NOT NULL,
+    origin_lon DOUBLE PRECISION NOT NULL,
+    dest_lat DOUBLE PRECISION NOT NULL,
+    dest_lon DOUBLE PRECISION NOT NULL,
+    duration_hr DOUBLE PRECISION NOT NULL,
+    distance_km DOUBLE PRECISION NOT NULL,
+    fetched_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (backend, origin_lat, origin_lon, dest_lat, dest_lon)
+);
+
+-- Transport Rates table: vehicle hire profiles. Region '' is the national
+-- default; rows for a state (matching mandis.state) override it there.
+CREATE TABLE IF NOT EXISTS transport_rates (
+    region VARCHAR(100) NOT NULL DEFAULT '',
+    vehicle VARCHAR(30) NOT NULL,               -- tractor_trolley, mini_truck, reefer_truck
+    capacity_kg DOUBLE PRECISION NOT NULL,
+    speed_factor DOUBLE PRECISION NOT NULL DEFAULT 1.0,
+    km_per_litre DOUBLE PRECISION NOT NULL,
+    diesel_per_litre DOUBLE PRECISION NOT NULL,
+    per_km_rate DOUBLE PRECISION NOT NULL,       -- ₹/km excluding fuel
+    per_hour_rate DOUBLE PRECISION NOT NULL,     -- ₹/hr hire
+    loading_per_quintal DOUBLE PRECISION NOT NULL,
+    toll_per_km DOUBLE PRECISION NOT NULL DEFAULT 0,
+    return_factor DOUBLE PRECISION NOT NULL DEFAULT 0.5,
+    refrigerated BOOLEAN NOT NULL DEFAULT FALSE,
+    max_range_km DOUBLE PRECISION NOT NULL DEFAULT 0,  -- 0 = unlimited
+    PRIMARY KEY (region, vehicle)
+);
+
 -- Farmers table: stores farmer identity and geolocation.
 CREATE TABLE IF NOT EXISTS farmers (
     id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -44,15 +181,25 @@ CREATE TABLE IF NOT EXISTS farmers (
     created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+ALTER TABLE farmers ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
 -- Crops table: catalogue of supported crops with agri-parameters.
 CREATE TABLE IF NOT EXISTS crops (
     id                   UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     name                 VARCHAR(100) NOT NULL,
     ideal_temp           DOUBLE PRECISION NOT NULL,  -- degrees Celsius
-    baseline_spoilage_rate DOUBLE PRECISION NOT NULL, -- percentage per hour
+    baseline_spoilage_rate DOUBLE PRECISION NOT NULL, -- perishability index (× 0.1 = % lost per hour in transit)
     created_at           TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+-- Agronomic attributes used by the scoring engine and preservation rules.
+ALTER TABLE crops ADD COLUMN IF NOT EXISTS category         VARCHAR(30) NOT NULL DEFAULT '';
+ALTER TABLE crops ADD COLUMN IF NOT EXISTS shelf_life_days  DOUBLE PRECISION NOT NULL DEFAULT 0;  -- days at ambient conditions
+ALTER TABLE crops ADD COLUMN IF NOT EXISTS humidity_min_pct DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE crops ADD COLUMN IF NOT EXISTS humidity_max_pct DOUBLE PRECISION NOT NULL DEFAULT 100;
+ALTER TABLE crops ADD COLUMN IF NOT EXISTS unit_of_sale     VARCHAR(20) NOT NULL DEFAULT 'quintal';
+ALTER TABLE crops ADD COLUMN IF NOT EXISTS updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
 -- Mandi Prices table: live market prices for a given crop at a named market.
 CREATE TABLE IF NOT EXISTS mandi_prices (
     id                    UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -69,13 +216,49 @@ CREATE TABLE IF NOT EXISTS mandi_prices (
 CREATE TABLE IF NOT EXISTS storage_facilities (
     id            UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     name          VARCHAR(200) NOT NULL,
-    location_lat  DOUBLE PRECISION NOT NULL,
-    location_lon  DOUBLE PRECISION NOT NULL,
+    location      GEOGRAPHY(Point, 4326) NOT NULL,
     capacity_mt   DOUBLE PRECISION NOT NULL,  -- metric tonnes
     price_per_kg  DOUBLE PRECISION NOT NULL,  -- INR per kg per day
+    min_temp_c    DOUBLE PRECISION,           -- supported chamber range; NULL when unknown
+    max_temp_c    DOUBLE PRECISION,
     created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+-- Older databases stored facilities as lat/lon doubles: move them onto a
+-- geography point like mandis, then drop the old columns.
+ALTER TABLE storage_facilities ADD COLUMN IF NOT EXISTS location GEOGRAPHY(Point, 4326);
+ALTER TABLE storage_facilities ADD COLUMN IF NOT EXISTS min_temp_c DOUBLE PRECISION;
+ALTER TABLE storage_facilities ADD COLUMN IF NOT EXISTS max_temp_c DOUBLE PRECISION;
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'storage_facilities' AND column_name = 'location_lat') THEN
+        UPDATE storage_facilities
+        SET location = ST_SetSRID(ST_MakePoint(location_lon, location_lat), 4326)::geography
+        WHERE location IS NULL;
+        ALTER TABLE storage_facilities DROP COLUMN location_lat, DROP COLUMN location_lon;
+    END IF;
+END $$;
+ALTER TABLE storage_facilities ALTER COLUMN location SET NOT NULL;
+
+-- Storage Bookings table: reserved cold-storage space per facility and date
+-- range. Pending holds stop counting against capacity once expires_at passes.
+CREATE TABLE IF NOT EXISTS storage_bookings (
+    id            UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    facility_id   UUID NOT NULL REFERENCES storage_facilities(id) ON DELETE CASCADE,
+    farmer_id     UUID NOT NULL REFERENCES farmers(id) ON DELETE CASCADE,
+    crop_id       UUID REFERENCES crops(id) ON DELETE SET NULL,
+    quantity_kg   DOUBLE PRECISION NOT NULL CHECK (quantity_kg > 0),
+    start_date    DATE NOT NULL,
+    end_date      DATE NOT NULL,                        -- inclusive
+    status        VARCHAR(20) NOT NULL DEFAULT 'pending',  -- pending, confirmed, cancelled
+    expires_at    TIMESTAMPTZ NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    confirmed_at  TIMESTAMPTZ,
+    cancelled_at  TIMESTAMPTZ,
+    CHECK (end_date >= start_date)
+);
+
 -- Crowdsource Reports table: Logs farmer verification ping loops from WhatsApp
 CREATE TABLE IF NOT EXISTS crowdsource_reports (
     report_id        UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -86,12 +269,149 @@ CREATE TABLE IF NOT EXISTS crowdsource_reports (
     timestamp        TIMESTAMPTZ NOT NULL DEFAULT NOW()
 );
 
+-- Recommendations table: every generated recommendation, kept for audit and offline history.
+CREATE TABLE IF NOT EXISTS recommendations (
+    id                  UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    farmer_id           UUID NOT NULL REFERENCES farmers(id) ON DELETE CASCADE,
+    crop_id             UUID,
+    crop_name           VARCHAR(100) NOT NULL,
+    action              VARCHAR(100) NOT NULL,
+    recommended_market  VARCHAR(200) NOT NULL,
+    payload             JSONB NOT NULL,  -- full Recommendation incl. inputs, markets, weather, soil
+    generated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Soil Samples table: soil tests with provenance. Imported Soil Health Card
+-- and SoilGrids points have no farmer; lab reports belong to one farmer.
+-- N, P and K are available kg/ha, organic carbon is %.
+CREATE TABLE IF NOT EXISTS soil_samples (
+    id                  UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    source              VARCHAR(30) NOT NULL,              -- lab, soil_health_card, soilgrids
+    reference           VARCHAR(100) NOT NULL DEFAULT '',  -- card / sample number, or grid geohash
+    farmer_id           UUID REFERENCES farmers(id) ON DELETE CASCADE,
+    plot_name           VARCHAR(100) NOT NULL DEFAULT '',
+    location            GEOGRAPHY(Point, 4326) NOT NULL,
+    sampled_on          DATE NOT NULL,
+    nitrogen            DOUBLE PRECISION,
+    phosphorus          DOUBLE PRECISION,
+    potassium           DOUBLE PRECISION,
+    ph                  DOUBLE PRECISION,
+    organic_carbon_pct  DOUBLE PRECISION,
+    lab_name            VARCHAR(255) NOT NULL DEFAULT '',  -- lab, or the import file name
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Farmer Crops table: how much of each crop a farmer has to sell, used to
+-- cost a recommendation when the request does not carry a quantity.
+CREATE TABLE IF NOT EXISTS farmer_crops (
+    farmer_id   UUID NOT NULL REFERENCES farmers(id) ON DELETE CASCADE,
+    crop_id     UUID NOT NULL REFERENCES crops(id) ON DELETE CASCADE,
+    quantity    DOUBLE PRECISION NOT NULL,
+    unit        VARCHAR(10) NOT NULL DEFAULT 'quintal',  -- kg, quintal, tonne
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (farmer_id, crop_id)
+);
+
+-- Farm Plots table: a farmer's fields, each with its own crop, season and
+-- size. location is the plot's point (the boundary centroid when a
+-- boundary is drawn); expected_yield feeds the recommendation's quantity.
+CREATE TABLE IF NOT EXISTS farm_plots (
+    id                     UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    farmer_id              UUID NOT NULL REFERENCES farmers(id) ON DELETE CASCADE,
+    name                   VARCHAR(100) NOT NULL,
+    crop_id                UUID NOT NULL REFERENCES crops(id),
+    location               GEOGRAPHY(Point, 4326) NOT NULL,
+    boundary               GEOGRAPHY(Polygon, 4326),
+    area_acres             DOUBLE PRECISION NOT NULL CHECK (area_acres > 0),
+    sowing_date            DATE NOT NULL,
+    expected_harvest_date  DATE,
+    irrigation_type        VARCHAR(20) NOT NULL DEFAULT 'rainfed',  -- rainfed, canal, tubewell, drip, sprinkler
+    expected_yield         DOUBLE PRECISION,
+    yield_unit             VARCHAR(10) NOT NULL DEFAULT 'quintal',  -- kg, quintal, tonne
+    created_at             TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at             TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (expected_harvest_date IS NULL OR expected_harvest_date > sowing_date)
+);
+
+-- Notification Preferences table: per-farmer opt-outs for proactive alerts.
+-- Farmers without a row receive every alert type on WhatsApp.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    farmer_id   UUID PRIMARY KEY REFERENCES farmers(id) ON DELETE CASCADE,
+    enabled     BOOLEAN NOT NULL DEFAULT TRUE,
+    channel     VARCHAR(20) NOT NULL DEFAULT 'whatsapp',
+    heavy_rain  BOOLEAN NOT NULL DEFAULT TRUE,
+    heatwave    BOOLEAN NOT NULL DEFAULT TRUE,
+    frost       BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Farmer Alerts table: one row per farmer, crop, threat and forecast day,
+-- which is what de-duplicates alerts across job runs.
+CREATE TABLE IF NOT EXISTS farmer_alerts (
+    id          UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    farmer_id   UUID NOT NULL REFERENCES farmers(id) ON DELETE CASCADE,
+    crop_id     UUID NOT NULL,
+    crop_name   VARCHAR(100) NOT NULL,
+    alert_type  VARCHAR(20) NOT NULL,   -- heavy_rain, heatwave, frost
+    alert_date  DATE NOT NULL,
+    message     TEXT NOT NULL,
+    action      VARCHAR(200) NOT NULL,
+    channel     VARCHAR(20) NOT NULL,
+    status      VARCHAR(20) NOT NULL DEFAULT 'pending',  -- pending, sent, failed, undelivered
+    attempts    INTEGER NOT NULL DEFAULT 1,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    sent_at     TIMESTAMPTZ,
+    UNIQUE (farmer_id, crop_id, alert_type, alert_date)
+);
+
+-- Recommendation Outcomes table: what the farmer actually realised after acting on advice.
+CREATE TABLE IF NOT EXISTS recommendation_outcomes (
+    id                   UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    recommendation_id    UUID NOT NULL UNIQUE REFERENCES recommendations(id) ON DELETE CASCADE,
+    farmer_id            UUID NOT NULL REFERENCES farmers(id) ON DELETE CASCADE,
+    market_name          VARCHAR(200) NOT NULL,
+    realised_price       DOUBLE PRECISION NOT NULL,  -- INR per quintal
+    quantity_sold_kg     DOUBLE PRECISION NOT NULL DEFAULT 0,
+    quantity_spoiled_kg  DOUBLE PRECISION NOT NULL DEFAULT 0,
+    source               VARCHAR(20) NOT NULL DEFAULT 'app',  -- app, whatsapp
+    sold_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    in_band              BOOLEAN,            -- filled by the accuracy job
+    price_error_pct      DOUBLE PRECISION,
+    profit_error         DOUBLE PRECISION,   -- INR per quintal, realised minus estimated
+    evaluated_at         TIMESTAMPTZ,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Accuracy Metrics table: snapshot rebuilt by the accuracy job.
+CREATE TABLE IF NOT EXISTS accuracy_metrics (
+    scope              VARCHAR(10) NOT NULL,   -- market, crop
+    key                VARCHAR(200) NOT NULL,
+    sample_size        INTEGER NOT NULL,
+    band_hit_rate      DOUBLE PRECISION NOT NULL,
+    price_mape         DOUBLE PRECISION NOT NULL,
+    mean_profit_error  DOUBLE PRECISION NOT NULL,
+    computed_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (scope, key)
+);
+
 -- Indexes for frequent lookups.
+CREATE INDEX IF NOT EXISTS idx_weather_cache_location ON weather_cache USING GIST (location);
+CREATE INDEX IF NOT EXISTS idx_weather_cache_recorded_at ON weather_cache(recorded_at DESC);
+CREATE INDEX IF NOT EXISTS idx_weather_cache_geohash_time ON weather_cache(geohash, recorded_at DESC);
 CREATE INDEX IF NOT EXISTS idx_mandi_prices_crop_id ON mandi_prices(crop_id);
 CREATE INDEX IF NOT EXISTS idx_mandi_prices_timestamp ON mandi_prices(timestamp DESC);
-CREATE INDEX IF NOT EXISTS idx_storage_facilities_location ON storage_facilities(location_lat, location_lon);
+CREATE INDEX IF NOT EXISTS idx_storage_facilities_location ON storage_facilities USING GIST (location);
+CREATE INDEX IF NOT EXISTS idx_soil_samples_location ON soil_samples USING GIST (location);
+CREATE INDEX IF NOT EXISTS idx_soil_samples_farmer ON soil_samples(farmer_id, sampled_on DESC) WHERE farmer_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_soil_samples_source_ref ON soil_samples(source, reference) WHERE reference <> '' AND source <> 'lab';
+CREATE INDEX IF NOT EXISTS idx_farm_plots_farmer ON farm_plots(farmer_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_storage_bookings_facility_dates ON storage_bookings(facility_id, start_date, end_date) WHERE status <> 'cancelled';
+CREATE INDEX IF NOT EXISTS idx_storage_bookings_farmer ON storage_bookings(farmer_id, created_at DESC);
 CREATE INDEX IF NOT EXISTS idx_crowdsource_reports_market_crop ON crowdsource_reports(market_name, crop_name);
 CREATE INDEX IF NOT EXISTS idx_crowdsource_reports_timestamp ON crowdsource_reports(timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_recommendations_farmer_generated ON recommendations(farmer_id, generated_at DESC);
+CREATE INDEX IF NOT EXISTS idx_recommendation_outcomes_pending ON recommendation_outcomes(evaluated_at) WHERE evaluated_at IS NULL;
 
 -- ═══════════════════════════════════════════════
 -- Seed data for development / demo.
@@ -102,22 +422,196 @@ INSERT INTO farmers (id, location_lat, location_lon, phone) VALUES
     ('b2c3d4e5-f6a7-8901-bcde-f12345678901', 19.0760, 72.8777, '+919876543211')   -- Mumbai
 ON CONFLICT (id) DO NOTHING;
 
-INSERT INTO crops (id, name, ideal_temp, baseline_spoilage_rate) VALUES
-    ('c3d4e5f6-a7b8-9012-cdef-123456789012', 'Tomato',  25.0, 2.5),
-    ('d4e5f6a7-b8c9-0123-defa-234567890123', 'Wheat',   20.0, 0.5),
-    ('e5f6a7b8-c9d0-1234-efab-345678901234', 'Rice',    30.0, 1.0)
-ON CONFLICT (id) DO NOTHING;
+-- Crop catalogue. IDs match the Flutter CropPickerScreen. Rows that predate
+-- the agronomic columns (category = '') are enriched; edits made through the
+-- /api/v1/crops endpoints are never overwritten.
+INSERT INTO crops (id, name, category, ideal_temp, baseline_spoilage_rate, shelf_life_days, humidity_min_pct, humidity_max_pct, unit_of_sale) VALUES
+    -- Vegetables
+    ('c3d4e5f6-a7b8-9012-cdef-123456789012', 'Tomato',             'vegetable',       25.0, 2.5,   7, 85, 95, 'quintal'),
+    ('d4e5f6a7-b890-12cd-ef12-345678901234', 'Onion',              'bulb',            20.0, 1.0, 120, 65, 70, 'quintal'),
+    ('e5f6a7b8-9012-cdef-1234-567890123456', 'Potato',             'root_tuber',      15.0, 1.5,  90, 85, 95, 'quintal'),
+    ('f6a7b8c9-0123-def0-2345-678901234567', 'Brinjal (Eggplant)', 'vegetable',       26.0, 2.2,   7, 90, 95, 'quintal'),
+    ('a7b8c9d0-1234-ef01-3456-789012345678', 'Cabbage',            'leafy_vegetable', 18.0, 2.8,  21, 90, 98, 'quintal'),
+    ('b8c9d0e1-2345-f012-4567-890123456789', 'Cauliflower',        'vegetable',       18.0, 3.0,  14, 90, 98, 'quintal'),
+    ('c9d0e1f2-3456-0123-5678-901234567890', 'Spinach',            'leafy_vegetable', 16.0, 4.5,   3, 90, 98, 'quintal'),
+    ('d0e1f2a3-4567-1234-6789-012345678901', 'Carrot',             'root_tuber',      16.0, 1.8,  30, 90, 98, 'quintal'),
+    ('e1f2a3b4-5678-2345-7890-123456789012', 'Radish',             'root_tuber',      15.0, 2.0,  14, 90, 95, 'quintal'),
+    ('f2a3b4c5-6789-3456-8901-234567890123', 'Garlic',             'bulb',            18.0, 0.8, 150, 60, 70, 'quintal'),
+    -- Fruits
+    ('a3b4c5d6-7890-4567-9012-345678901234', 'Apple',              'fruit',            4.0, 1.2,  90, 90, 95, 'quintal'),
+    ('b4c5d6e7-8901-5678-0123-456789012345', 'Banana',             'fruit',           14.0, 3.5,   7, 85, 95, 'quintal'),
+    ('c5d6e7f8-9012-6789-1234-567890123456', 'Mango',              'fruit',           12.0, 2.8,  10, 85, 90, 'quintal'),
+    ('d6e7f8a9-0123-7890-2345-678901234567', 'Orange',             'fruit',            8.0, 2.0,  30, 85, 90, 'quintal'),
+    ('e7f8a9b0-1234-8901-3456-789012345678', 'Grapes',             'fruit',            2.0, 3.2,   5, 90, 95, 'quintal'),
+    ('f8a9b0c1-2345-9012-4567-890123456789', 'Papaya',             'fruit',           12.0, 4.0,   7, 85, 90, 'quintal'),
+    ('a9b0c1d2-3456-0123-5678-901234567890', 'Guava',              'fruit',           10.0, 2.5,   7, 85, 90, 'quintal'),
+    ('b0c1d2e3-4567-1234-6789-012345678901', 'Pineapple',          'fruit',           10.0, 1.8,  14, 85, 90, 'quintal'),
+    ('c1d2e3f4-5678-2345-7890-123456789012', 'Pomegranate',        'fruit',            5.0, 1.5,  60, 90, 95, 'quintal'),
+    -- Cash Crops & Grains
+    ('d2e3f4a5-6789-3456-8901-234567890123', 'Wheat',              'grain',           20.0, 0.5, 365, 50, 65, 'quintal'),
+    ('e3f4a5b6-7890-4567-9012-345678901234', 'Rice',               'grain',           25.0, 0.8, 365, 50, 65, 'quintal'),
+    ('f4a5b6c7-8901-5678-0123-456789012345', 'Sugarcane',          'cash_crop',       30.0, 2.0,   3, 85, 95, 'quintal'),
+    ('a5b6c7d8-9012-6789-1234-567890123456', 'Cotton',             'cash_crop',       25.0, 0.4, 365, 50, 65, 'quintal'),
+    ('b6c7d8e9-0123-7890-2345-678901234567', 'Maize',              'grain',           24.0, 0.9, 180, 50, 65, 'quintal'),
+    ('c7d8e9f0-1234-8901-3456-789012345678', 'Tea',                'cash_crop',       20.0, 1.0,   1, 70, 90, 'quintal'),
+    ('d8e9f0a1-2345-9012-4567-890123456789', 'Coffee',             'cash_crop',       22.0, 1.2, 180, 50, 65, 'quintal'),
+    ('e9f0a1b2-3456-0123-5678-901234567890', 'Mustard',            'oilseed',         15.0, 0.6, 270, 50, 65, 'quintal'),
+    -- Spices
+    ('f0a1b2c3-4567-1234-6789-012345678901', 'Ginger',             'spice',           15.0, 1.5,  60, 65, 75, 'quintal'),
+    ('a1b2c3d4-5678-2345-7890-123456789012', 'Turmeric',           'spice',           25.0, 0.5, 365, 50, 65, 'quintal'),
+    ('b2c3d4e5-6789-3456-8901-234567890123', 'Coriander',          'leafy_vegetable', 20.0, 3.5,   3, 90, 95, 'quintal'),
+    ('c3d4e5f6-7890-4567-9012-345678901234', 'Cumin',              'spice',           25.0, 0.5, 365, 50, 65, 'quintal'),
+    ('d4e5f6a7-8901-5678-0123-456789012345', 'Black Pepper',       'spice',           25.0, 0.8, 365, 50, 65, 'quintal')
+ON CONFLICT (id) DO UPDATE SET
+    category         = EXCLUDED.category,
+    shelf_life_days  = EXCLUDED.shelf_life_days,
+    humidity_min_pct = EXCLUDED.humidity_min_pct,
+    humidity_max_pct = EXCLUDED.humidity_max_pct,
+    unit_of_sale     = EXCLUDED.unit_of_sale
+WHERE crops.category = '';
+
+-- Retire the legacy Wheat/Rice seed rows whose UUIDs never matched the app.
+UPDATE mandi_prices SET crop_id = 'd2e3f4a5-6789-3456-8901-234567890123' WHERE crop_id = 'd4e5f6a7-b8c9-0123-defa-234567890123';
+UPDATE mandi_prices SET crop_id = 'e3f4a5b6-7890-4567-9012-345678901234' WHERE crop_id = 'e5f6a7b8-c9d0-1234-efab-345678901234';
+DELETE FROM crops WHERE id IN ('d4e5f6a7-b8c9-0123-defa-234567890123', 'e5f6a7b8-c9d0-1234-efab-345678901234');
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_crops_name ON crops (LOWER(name));
+
+-- Crop Nutrients table: recommended seasonal dose (kg/ha of N, P2O5, K2O)
+-- and FAO-56 mid-season crop coefficient for the fertiliser and irrigation advisory.
+CREATE TABLE IF NOT EXISTS crop_nutrients (
+    crop_id    UUID PRIMARY KEY REFERENCES crops(id) ON DELETE CASCADE,
+    n_kg_ha    DECIMAL(6,1) NOT NULL,
+    p2o5_kg_ha DECIMAL(6,1) NOT NULL,
+    k2o_kg_ha  DECIMAL(6,1) NOT NULL,
+    kc_mid     DECIMAL(4,2) NOT NULL
+);
+
+INSERT INTO crop_nutrients (crop_id, n_kg_ha, p2o5_kg_ha, k2o_kg_ha, kc_mid)
+SELECT c.id, v.n, v.p, v.k, v.kc
+FROM (VALUES
+    ('Tomato',              120,  60,  60, 1.15),
+    ('Onion',               100,  50,  50, 1.05),
+    ('Potato',              150,  80, 100, 1.15),
+    ('Brinjal (Eggplant)',  100,  50,  50, 1.05),
+    ('Cabbage',             120,  60,  60, 1.05),
+    ('Cauliflower',         120,  60,  60, 1.05),
+    ('Spinach',              80,  40,  40, 1.00),
+    ('Carrot',               60,  40,  60, 1.05),
+    ('Radish',               60,  40,  40, 0.90),
+    ('Garlic',              100,  50,  50, 1.00),
+    ('Apple',                70,  35,  70, 0.95),
+    ('Banana',              200,  60, 300, 1.20),
+    ('Mango',               100,  50, 100, 0.90),
+    ('Orange',              120,  60,  60, 0.70),
+    ('Grapes',              120,  60, 120, 0.85),
+    ('Papaya',              200, 200, 250, 1.00),
+    ('Guava',               100,  50, 100, 0.90),
+    ('Pineapple',           120,  40, 120, 0.30),
+    ('Pomegranate',         100,  50,  50, 0.90),
+    ('Wheat',               120,  60,  40, 1.15),
+    ('Rice',                100,  50,  50, 1.20),
+    ('Sugarcane',           250, 100, 120, 1.25),
+    ('Cotton',              120,  60,  60, 1.15),
+    ('Maize',               120,  60,  40, 1.20),
+    ('Tea',                 120,  40,  60, 1.00),
+    ('Coffee',              100,  40, 100, 0.95),
+    ('Mustard',              80,  40,  40, 1.05),
+    ('Ginger',               75,  50,  50, 1.00),
+    ('Turmeric',             60,  50, 120, 1.05),
+    ('Coriander',            60,  40,  20, 1.00),
+    ('Cumin',                30,  20,  10, 0.90),
+    ('Black Pepper',         50,  50, 150, 0.95)
+) AS v(name, n, p, k, kc)
+JOIN crops c ON LOWER(c.name) = LOWER(v.name)
+ON CONFLICT (crop_id) DO NOTHING;
+
+-- Crop Phenology table: base and upper temperature (°C) for growing degree
+-- days, and the GDD from sowing (or fruit set, for orchards) to harvest.
+CREATE TABLE IF NOT EXISTS crop_phenology (
+    crop_id       UUID PRIMARY KEY REFERENCES crops(id) ON DELETE CASCADE,
+    base_temp_c   DECIMAL(4,1) NOT NULL,
+    upper_temp_c  DECIMAL(4,1) NOT NULL,
+    gdd_maturity  DECIMAL(6,0) NOT NULL CHECK (gdd_maturity > 0)
+);
+
+INSERT INTO crop_phenology (crop_id, base_temp_c, upper_temp_c, gdd_maturity)
+SELECT c.id, v.base, v.upper, v.gdd
+FROM (VALUES
+    ('Tomato',               10, 30, 1300),
+    ('Onion',                 5, 30, 1900),
+    ('Potato',                7, 30, 1500),
+    ('Brinjal (Eggplant)',   10, 35, 1500),
+    ('Cabbage',               5, 30, 1500),
+    ('Cauliflower',           5, 30, 1400),
+    ('Spinach',               4, 30,  700),
+    ('Carrot',                4, 30, 1300),
+    ('Radish',                4, 30,  600),
+    ('Garlic',                5, 30, 2000),
+    ('Apple',                 5, 30, 2000),
+    ('Banana',               14, 35, 2400),
+    ('Mango',                10, 35, 1600),
+    ('Orange',               13, 35, 2200),
+    ('Grapes',               10, 35, 1300),
+    ('Papaya',               15, 35, 2000),
+    ('Guava',                10, 35, 1600),
+    ('Pineapple',            12, 35, 3000),
+    ('Pomegranate',          10, 35, 1800),
+    ('Wheat',                 0, 30, 2000),
+    ('Rice',                 10, 35, 1900),
+    ('Sugarcane',            12, 35, 4500),
+    ('Cotton',             15.5, 35, 1800),
+    ('Maize',                10, 30, 1500),
+    ('Tea',                12.5, 35,  600),
+    ('Coffee',               10, 32, 2800),
+    ('Mustard',               5, 30, 1600),
+    ('Ginger',               10, 35, 2700),
+    ('Turmeric',             10, 35, 3000),
+    ('Coriander',             5, 30, 1000),
+    ('Cumin',                 5, 30, 1500),
+    ('Black Pepper',         10, 35, 2800)
+) AS v(name, base, upper, gdd)
+JOIN crops c ON LOWER(c.name) = LOWER(v.name)
+ON CONFLICT (crop_id) DO NOTHING;
+
+-- data.gov.in (Agmarknet) commodity names that differ from our catalogue names.
+-- Crops without a row here are fetched under their own name.
+CREATE TABLE IF NOT EXISTS commodity_aliases (
+    api_name VARCHAR(100) PRIMARY KEY,
+    crop_id  UUID NOT NULL REFERENCES crops(id) ON DELETE CASCADE
+);
+
+INSERT INTO commodity_aliases (api_name, crop_id) VALUES
+    ('Brinjal',             'f6a7b8c9-0123-def0-2345-678901234567'),
+    ('Raddish',             'e1f2a3b4-5678-2345-7890-123456789012'),
+    ('Banana',              'b4c5d6e7-8901-5678-0123-456789012345'),
+    ('Banana - Green',      'b4c5d6e7-8901-5678-0123-456789012345'),
+    ('Ginger(Green)',       'f0a1b2c3-4567-1234-6789-012345678901'),
+    ('Ginger(Dry)',         'f0a1b2c3-4567-1234-6789-012345678901'),
+    ('Coriander(Leaves)',   'b2c3d4e5-6789-3456-8901-234567890123'),
+    ('Cummin Seed(Jeera)',  'c3d4e5f6-7890-4567-9012-345678901234'),
+    ('Black pepper',        'd4e5f6a7-8901-5678-0123-456789012345')
+ON CONFLICT (api_name) DO NOTHING;
 
 INSERT INTO mandi_prices (market_name, crop_id, current_price, market_lat, market_lon, arrival_volume_trend) VALUES
     ('Azadpur Mandi',   'c3d4e5f6-a7b8-9012-cdef-123456789012', 2500.00, 28.7041, 77.1525, 'HIGH'),
     ('Vashi APMC',      'c3d4e5f6-a7b8-9012-cdef-123456789012', 2800.00, 19.0728, 73.0169, 'NORMAL'),
     ('Ghazipur Mandi',  'c3d4e5f6-a7b8-9012-cdef-123456789012', 2350.00, 28.6233, 77.3230, 'LOW'),
-    ('Azadpur Mandi',   'd4e5f6a7-b8c9-0123-defa-234567890123', 2200.00, 28.7041, 77.1525, 'NORMAL'),
-    ('Indore Mandi',    'd4e5f6a7-b8c9-0123-defa-234567890123', 2100.00, 22.7196, 75.8577, 'HIGH'),
-    ('Vashi APMC',      'e5f6a7b8-c9d0-1234-efab-345678901234', 3200.00, 19.0728, 73.0169, 'LOW');
-
-INSERT INTO storage_facilities (id, name, location_lat, location_lon, capacity_mt, price_per_kg) VALUES
-    ('f6a7b8c9-d0e1-2345-abcd-456789012345', 'Narela Cold Storage',       28.8526, 77.0932, 500.0, 2.0),
-    ('a7b8c9d0-e1f2-3456-bcde-567890123456', 'Sonipat AgriStore',         28.9931, 77.0151, 300.0, 1.5),
-    ('b8c9d0e1-f2a3-4567-cdef-678901234567', 'Gurgaon FreshVault',        28.4595, 77.0266, 750.0, 2.5)
-ON CONFLICT (id) DO NOTHING;
+    ('Azadpur Mandi',   'd2e3f4a5-6789-3456-8901-234567890123', 2200.00, 28.7041, 77.1525, 'NORMAL'),
+    ('Indore Mandi',    'd2e3f4a5-6789-3456-8901-234567890123', 2100.00, 22.7196, 75.8577, 'HIGH'),
+    ('Vashi APMC',      'e3f4a5b6-7890-4567-9012-345678901234', 3200.00, 19.0728, 73.0169, 'LOW');
+
+INSERT INTO transport_rates (region, vehicle, capacity_kg, speed_factor, km_per_litre, diesel_per_litre, per_km_rate, per_hour_rate, loading_per_quintal, toll_per_km, return_factor, refrigerated, max_range_km) VALUES
+    ('', 'tractor_trolley', 3000, 1.6,  6, 90.0,  4, 120, 15, 0.0, 1.0, FALSE, 60),
+    ('', 'mini_truck',      1500, 1.1, 12, 90.0,  6, 100, 12, 1.2, 0.5, FALSE,  0),
+    ('', 'reefer_truck',    5000, 1.1,  5, 90.0, 14, 250, 18, 2.5, 0.5, TRUE,   0)
+ON CONFLICT (region, vehicle) DO NOTHING;
+
+INSERT INTO storage_facilities (id, name, location, capacity_mt, price_per_kg, min_temp_c, max_temp_c) VALUES
+    ('f6a7b8c9-d0e1-2345-abcd-456789012345', 'Narela Cold Storage', ST_SetSRID(ST_MakePoint(77.0932, 28.8526), 4326)::geography, 500.0, 2.0, 0, 4),
+    ('a7b8c9d0-e1f2-3456-bcde-567890123456', 'Sonipat AgriStore',   ST_SetSRID(ST_MakePoint(77.0151, 28.9931), 4326)::geography, 300.0, 1.5, 2, 15),
+    ('b8c9d0e1-f2a3-4567-cdef-678901234567', 'Gurgaon FreshVault',  ST_SetSRID(ST_MakePoint(77.0266, 28.4595), 4326)::geography, 750.0, 2.5, 0, 12)
+ON CONFLICT (id) DO UPDATE SET
+    min_temp_c = EXCLUDED.min_temp_c,
+    max_temp_c = EXCLUDED.max_temp_c
+WHERE storage_facilities.min_temp_c IS NULL;
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ══════════════════════════════════════════════
+//  SOIL DATA (Soil Health Cards, SoilGrids, Lab Tests)
+// ══════════════════════════════════════════════
+
+// Soil sample sources, in order of preference for a farmer's location.
+const (
+	soilSourceLab       = "lab"              // the farmer's own lab report
+	soilSourceCard      = "soil_health_card" // government Soil Health Card test
+	soilSourceSoilGrids = "soilgrids"        // gridded dataset (ISRIC SoilGrids export)
+)
+
+const (
+	soilLabRadiusKm      = 1.0  // a farmer's lab test covers their own fields
+	soilCardRadiusKm     = 5.0  // a card sample stands in for the village
+	soilGridRadiusKm     = 2.0  // SoilGrids cells are 250 m; allow for sparse exports
+	soilEstimateRadiusKm = 25.0 // interpolate from samples this close
+	soilEstimateSamples  = 10
+	// soilMaxAgeYears drops point tests older than about two card cycles.
+	soilMaxAgeYears = 4
+	// defaultMoisturePct is used when live soil moisture is unavailable; it
+	// sits above the irrigation threshold so no action is triggered on it.
+	defaultMoisturePct = 25.0
+)
+
+const soilSampleColumns = `id, source, reference, farmer_id, plot_name,
+	ST_Y(location::geometry) AS lat, ST_X(location::geometry) AS lon,
+	to_char(sampled_on, 'YYYY-MM-DD') AS sampled_on,
+	nitrogen, phosphorus, potassium, ph, organic_carbon_pct, lab_name, created_at`
+
+// soilReading is one sample found near a location.
+type soilReading struct {
+	Source        string   `db:"source"`
+	SampledOn     string   `db:"sampled_on"`
+	Nitrogen      *float64 `db:"nitrogen"`
+	Phosphorus    *float64 `db:"phosphorus"`
+	Potassium     *float64 `db:"potassium"`
+	PH            *float64 `db:"ph"`
+	OrganicCarbon *float64 `db:"organic_carbon_pct"`
+	DistanceKm    float64  `db:"distance_km"`
+}
+
+func (in SoilTestInput) validate() error {
+	if in.SampledOn == "" {
+		return fmt.Errorf("sampled_on is required (YYYY-MM-DD)")
+	}
+	d, err := time.Parse("2006-01-02", in.SampledOn)
+	if err != nil {
+		return fmt.Errorf("sampled_on must be YYYY-MM-DD")
+	}
+	if d.After(time.Now()) {
+		return fmt.Errorf("sampled_on must not be in the future")
+	}
+	if in.Nitrogen == nil && in.Phosphorus == nil && in.Potassium == nil && in.PH == nil && in.OrganicCarbonPct == nil {
+		return fmt.Errorf("at least one of nitrogen, phosphorus, potassium, ph or organic_carbon_pct is required")
+	}
+	return validateSoilValues(in.Nitrogen, in.Phosphorus, in.Potassium, in.PH, in.OrganicCarbonPct)
+}
+
+// validateSoilValues checks readings against plausible ranges (kg/ha for
+// N, P and K; pH units; % organic carbon).
+func validateSoilValues(n, p, k, ph, oc *float64) error {
+	checks := []struct {
+		name  string
+		v     *float64
+		max   float64
+		units string
+	}{
+		{"nitrogen", n, 2000, "kg/ha"},
+		{"phosphorus", p, 500, "kg/ha"},
+		{"potassium", k, 3000, "kg/ha"},
+		{"ph", ph, 14, ""},
+		{"organic_carbon_pct", oc, 20, "%"},
+	}
+	for _, c := range checks {
+		if c.v != nil && (*c.v < 0 || *c.v > c.max) {
+			return fmt.Errorf("%s must be between 0 and %g %s", c.name, c.max, c.units)
+		}
+	}
+	return nil
+}
+
+// ── Lookup ──────────────────────────────────
+
+// fetchSoilHealth returns soil nutrients for a location with their source
+// and sampling date. A farmer's own lab test wins, then the nearest Soil
+// Health Card sample, then the SoilGrids cell. Failing those, nearby samples
+// are interpolated and the result is flagged as an estimate; with no data at
+// all the nutrients are left empty rather than invented. Moisture comes live
+// from Open-Meteo.
+func fetchSoilHealth(farmerID string, lat, lon float64) SoilHealth {
+	soil := SoilHealth{Source: "unavailable", Estimated: true}
+
+	if db != nil {
+		if r, err := nearestSoilSample(farmerID, lat, lon); err == nil {
+			soil.Source, soil.SampledOn, soil.DistanceKm = r.Source, r.SampledOn, math.Round(r.DistanceKm*10)/10
+			soil.Nitrogen, soil.Phosphorus, soil.Potassium = r.Nitrogen, r.Phosphorus, r.Potassium
+			soil.PH, soil.OrganicCarbonPct = r.PH, r.OrganicCarbon
+			soil.Estimated = false
+		} else if !errors.Is(err, errNoSoilData) {
+			log.Printf("⚠ DB fetch soil sample failed: %v", err)
+		} else if est, ok := estimateSoil(lat, lon); ok {
+			soil = est
+		}
+	}
+
+	soil.MoisturePct, soil.MoistureSource = defaultMoisturePct, "default"
+	if m, err := fetchSoilMoisture(lat, lon); err == nil {
+		soil.MoisturePct, soil.MoistureSource = math.Round(m*10)/10, "open_meteo"
+	}
+
+	soil.Status = soilStatus(soil)
+	return soil
+}
+
+var errNoSoilData = errors.New("no soil data near location")
+
+func nearestSoilSample(farmerID string, lat, lon float64) (soilReading, error) {
+	var rows []soilReading
+	err := db.Select(&rows, `
+		SELECT source, to_char(sampled_on, 'YYYY-MM-DD') AS sampled_on,
+		       nitrogen, phosphorus, potassium, ph, organic_carbon_pct,
+		       ST_Distance(location, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography) / 1000.0 AS distance_km
+		FROM soil_samples
+		WHERE (source = $4 OR sampled_on >= CURRENT_DATE - make_interval(years => $5))
+		  AND ((source = 'lab' AND farmer_id::text = $3
+		        AND ST_DWithin(location, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography, $6))
+		    OR (source = 'soil_health_card'
+		        AND ST_DWithin(location, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography, $7))
+		    OR (source = $4
+		        AND ST_DWithin(location, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography, $8)))
+		ORDER BY CASE source WHEN 'lab' THEN 0 WHEN 'soil_health_card' THEN 1 ELSE 2 END,
+		         location <-> ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography,
+		         sampled_on DESC
+		LIMIT 1`,
+		lat, lon, farmerID, soilSourceSoilGrids, soilMaxAgeYears,
+		soilLabRadiusKm*1000, soilCardRadiusKm*1000, soilGridRadiusKm*1000)
+	if err != nil {
+		return soilReading{}, err
+	}
+	if len(rows) == 0 {
+		return soilReading{}, errNoSoilData
+	}
+	return rows[0], nil
+}
+
+// estimateSoil interpolates card and SoilGrids samples within
+// soilEstimateRadiusKm by inverse distance squared. Other farmers' lab
+// reports are never used.
+func estimateSoil(lat, lon float64) (SoilHealth, bool) {
+	var rows []soilReading
+	err := db.Select(&rows, `
+		SELECT source, to_char(sampled_on, 'YYYY-MM-DD') AS sampled_on,
+		       nitrogen, phosphorus, potassium, ph, organic_carbon_pct,
+		       ST_Distance(location, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography) / 1000.0 AS distance_km
+		FROM soil_samples
+		WHERE source <> 'lab'
+		  AND (source = $3 OR sampled_on >= CURRENT_DATE - make_interval(years => $4))
+		  AND ST_DWithin(location, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography, $5)
+		ORDER BY location <-> ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography
+		LIMIT $6`,
+		lat, lon, soilSourceSoilGrids, soilMaxAgeYears, soilEstimateRadiusKm*1000, soilEstimateSamples)
+	if err != nil {
+		log.Printf("⚠ DB soil estimate failed: %v", err)
+		return SoilHealth{}, false
+	}
+	if len(rows) == 0 {
+		return SoilHealth{}, false
+	}
+
+	idw := func(get func(soilReading) *float64) *float64 {
+		var sum, weights float64
+		for _, r := range rows {
+			if v := get(r); v != nil {
+				w := 1 / math.Pow(math.Max(r.DistanceKm, 0.5), 2)
+				sum += *v * w
+				weights += w
+			}
+		}
+		if weights == 0 {
+			return nil
+		}
+		v := math.Round(sum/weights*10) / 10
+		return &v
+	}
+
+	nearest := rows[0].DistanceKm
+	return SoilHealth{
+		Nitrogen:         idw(func(r soilReading) *float64 { return r.Nitrogen }),
+		Phosphorus:       idw(func(r soilReading) *float64 { return r.Phosphorus }),
+		Potassium:        idw(func(r soilReading) *float64 { return r.Potassium }),
+		PH:               idw(func(r soilReading) *float64 { return r.PH }),
+		OrganicCarbonPct: idw(func(r soilReading) *float64 { return r.OrganicCarbon }),
+		Source:           "regional_estimate",
+		DistanceKm:       math.Round(nearest*10) / 10,
+		Estimated:        true,
+	}, true
+}
+
+// fetchSoilMoisture reads the current top-layer soil moisture (%) from Open-Meteo.
+func fetchSoilMoisture(lat, lon float64) (float64, error) {
+	url := fmt.Sprintf("https://api.open-meteo.com/v1/forecast?latitude=%.4f&longitude=%.4f&hourly=soil_moisture_0_to_1cm", lat, lon)
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("open-meteo status %d", resp.StatusCode)
+	}
+	var apiResp struct {
+		Hourly struct {
+			SoilMoisture []float64 `json:"soil_moisture_0_to_1cm"`
+		} `json:"hourly"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return 0, err
+	}
+	// OpenMeteo returns m³/m³, multiply by 100 for percentage
+	if len(apiResp.Hourly.SoilMoisture) == 0 || apiResp.Hourly.SoilMoisture[0] <= 0 {
+		return 0, errors.New("no soil moisture reading")
+	}
+	return apiResp.Hourly.SoilMoisture[0] * 100, nil
+}
+
+// ── Import (`go run . import-soil`) ─────────
+
+// soilHeaderAliases maps normalised CSV headers, including the column names
+// of Soil Health Card portal exports, to the importer's fields.
+var soilHeaderAliases = map[string]string{
+	"lat": "lat", "latitude": "lat",
+	"lon": "lon", "lng": "lon", "long": "lon", "longitude": "lon",
+	"sampled_on": "sampled_on", "sampling_date": "sampled_on", "date": "sampled_on", "sample_date": "sampled_on",
+	"reference": "reference", "sample_no": "reference", "sample_id": "reference", "card_no": "reference", "shc_no": "reference",
+	"nitrogen": "nitrogen", "n": "nitrogen", "available_n": "nitrogen",
+	"phosphorus": "phosphorus", "p": "phosphorus", "available_p": "phosphorus",
+	"potassium": "potassium", "k": "potassium", "available_k": "potassium",
+	"ph": "ph", "soil_ph": "ph",
+	"organic_carbon_pct": "organic_carbon_pct", "oc": "organic_carbon_pct", "organic_carbon": "organic_carbon_pct",
+}
+
+var soilHeaderUnits = regexp.MustCompile(`\(.*?\)`)
+
+var soilDateLayouts = []string{"2006-01-02", "02-01-2006", "02/01/2006", "2/1/2006"}
+
+// runImportSoil loads point samples into soil_samples. Values must already
+// be in kg/ha (N, P, K), pH units and % organic carbon; SoilGrids rasters
+// should be converted when exported to CSV. Rows are upserted on their
+// reference, or on a geohash of the location when the file has none.
+func runImportSoil(args []string) error {
+	fs := flag.NewFlagSet("import-soil", flag.ExitOnError)
+	file := fs.String("file", "", "CSV file to import")
+	source := fs.String("source", soilSourceCard, "soil_health_card or soilgrids")
+	sampledOn := fs.String("sampled-on", "", "date (YYYY-MM-DD) for rows without one, e.g. the SoilGrids release")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if db == nil {
+		return errors.New("DATABASE_URL is required for import-soil")
+	}
+	if *file == "" {
+		return errors.New("-file is required")
+	}
+	if *source != soilSourceCard && *source != soilSourceSoilGrids {
+		return fmt.Errorf("unknown source %q (expected soil_health_card or soilgrids)", *source)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cr := csv.NewReader(f)
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("read CSV header: %w", err)
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		key := strings.ToLower(strings.TrimSpace(soilHeaderUnits.ReplaceAllString(h, "")))
+		key = strings.NewReplacer(" ", "_", "-", "_", ".", "").Replace(key)
+		if field, ok := soilHeaderAliases[key]; ok {
+			col[field] = i
+		}
+	}
+	for _, required := range []string{"lat", "lon"} {
+		if _, ok := col[required]; !ok {
+			return fmt.Errorf("CSV header must include %q", required)
+		}
+	}
+
+	imported, skipped := 0, 0
+	label := filepath.Base(*file)
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		field := func(name string) string {
+			if i, ok := col[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		num := func(name string) *float64 {
+			v, err := strconv.ParseFloat(field(name), 64)
+			if err != nil {
+				return nil
+			}
+			return &v
+		}
+
+		lat, errLat := strconv.ParseFloat(field("lat"), 64)
+		lon, errLon := strconv.ParseFloat(field("lon"), 64)
+		if errLat != nil || errLon != nil || validateLocation(lat, lon) != nil {
+			log.Printf("[import] line %d skipped: lat/lon must be valid coordinates", line)
+			skipped++
+			continue
+		}
+		dateStr := field("sampled_on")
+		if dateStr == "" {
+			dateStr = *sampledOn
+		}
+		var date time.Time
+		for _, layout := range soilDateLayouts {
+			if date, err = time.Parse(layout, dateStr); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			log.Printf("[import] line %d skipped: no usable sampling date (pass -sampled-on for undated files)", line)
+			skipped++
+			continue
+		}
+		n, p, k, ph, oc := num("nitrogen"), num("phosphorus"), num("potassium"), num("ph"), num("organic_carbon_pct")
+		if n == nil && p == nil && k == nil && ph == nil && oc == nil {
+			log.Printf("[import] line %d skipped: no soil readings", line)
+			skipped++
+			continue
+		}
+		if err := validateSoilValues(n, p, k, ph, oc); err != nil {
+			log.Printf("[import] line %d skipped: %v", line, err)
+			skipped++
+			continue
+		}
+		ref := field("reference")
+		if ref == "" {
+			ref = encodeGeohash(lat, lon, 8)
+		}
+
+		_, err = db.Exec(`
+			INSERT INTO soil_samples (source, reference, location, sampled_on, nitrogen, phosphorus, potassium, ph, organic_carbon_pct, lab_name)
+			VALUES ($1, $2, ST_SetSRID(ST_MakePoint($3, $4), 4326)::geography, $5, $6, $7, $8, $9, $10, $11)
+			ON CONFLICT (source, reference) WHERE reference <> '' AND source <> 'lab' DO UPDATE SET
+				location = EXCLUDED.location, sampled_on = EXCLUDED.sampled_on,
+				nitrogen = EXCLUDED.nitrogen, phosphorus = EXCLUDED.phosphorus, potassium = EXCLUDED.potassium,
+				ph = EXCLUDED.ph, organic_carbon_pct = EXCLUDED.organic_carbon_pct, lab_name = EXCLUDED.lab_name`,
+			*source, ref, lon, lat, date, n, p, k, ph, oc, label)
+		if err != nil {
+			return fmt.Errorf("line %d: upsert sample: %w", line, err)
+		}
+		imported++
+	}
+
+	fmt.Printf("Imported %d %s samples (%d skipped)\n", imported, *source, skipped)
+	return nil
+}
+
+// ── Handlers ────────────────────────────────
+
+// handleGetSoil returns the soil reading the engine would use at a point.
+// ?farmer_id= lets the farmer's own lab tests take priority.
+func handleGetSoil(c *gin.Context) {
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lon, errLon := strconv.ParseFloat(c.Query("lon"), 64)
+	if errLat != nil || errLon != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat and lon are required"})
+		return
+	}
+	if err := validateLocation(lat, lon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, fetchSoilHealth(c.Query("farmer_id"), lat, lon))
+}
+
+// handleCreateSoilTest stores a farmer's own lab report. The sample location
+// defaults to the farmer's registered location.
+func handleCreateSoilTest(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	farmerID := c.Param("id")
+	farmer, err := fetchFarmer(farmerID)
+	if err != nil {
+		respondFarmerError(c, farmerID, err)
+		return
+	}
+
+	var in SoilTestInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload format"})
+		return
+	}
+	if err := in.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	lat, lon := farmer.LocationLat, farmer.LocationLon
+	if in.Lat != nil && in.Lon != nil {
+		lat, lon = *in.Lat, *in.Lon
+	}
+	if err := validateLocation(lat, lon); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var s SoilSample
+	err = db.Get(&s, `
+		INSERT INTO soil_samples (source, reference, farmer_id, plot_name, location, sampled_on,
+			nitrogen, phosphorus, potassium, ph, organic_carbon_pct, lab_name)
+		VALUES ($1, $2, $3, $4, ST_SetSRID(ST_MakePoint($5, $6), 4326)::geography, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING `+soilSampleColumns,
+		soilSourceLab, strings.TrimSpace(in.Reference), farmerID, strings.TrimSpace(in.PlotName), lon, lat, in.SampledOn,
+		in.Nitrogen, in.Phosphorus, in.Potassium, in.PH, in.OrganicCarbonPct, strings.TrimSpace(in.LabName))
+	if err != nil {
+		log.Printf("Error saving soil test for farmer %s: %v", farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save soil test"})
+		return
+	}
+	log.Printf("🧪 Soil test recorded for farmer %s (%s)", farmerID, s.SampledOn)
+	c.JSON(http.StatusCreated, s)
+}
+
+// handleListSoilTests returns a farmer's lab reports, newest sample first.
+func handleListSoilTests(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	farmerID := c.Param("id")
+	if _, err := fetchFarmer(farmerID); err != nil {
+		respondFarmerError(c, farmerID, err)
+		return
+	}
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page := SoilSamplePage{Items: []SoilSample{}, Limit: limit, Offset: offset}
+	if err := db.Get(&page.Total, "SELECT COUNT(*) FROM soil_samples WHERE farmer_id = $1", farmerID); err != nil {
+		log.Printf("Error counting soil tests for %s: %v", farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list soil tests"})
+		return
+	}
+	err = db.Select(&page.Items, "SELECT "+soilSampleColumns+`
+		FROM soil_samples WHERE farmer_id = $1
+		ORDER BY sampled_on DESC, created_at DESC
+		LIMIT $2 OFFSET $3`, farmerID, limit, offset)
+	if err != nil {
+		log.Printf("Error listing soil tests for %s: %v", farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list soil tests"})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
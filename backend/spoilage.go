@@ -0,0 +1,190 @@
+package main
+
+import "math"
+
+// ══════════════════════════════════════════════
+//  SPOILAGE MODEL (Crop Shelf Life under Transit & Storage)
+// ══════════════════════════════════════════════
+
+// The model estimates the share of a load lost over a trip and an optional
+// cold-storage hold. Each phase has a crop-specific base rate that is
+// scaled by the conditions the produce sits in:
+//
+//   - transit: baseline_spoilage_rate is a perishability index; each point
+//     is transitLossPerIndexPct %/h lost in an open vehicle at the crop's
+//     ideal temperature and humidity on a mixed road
+//   - storage: shelf life sets the rate, storageLossOverShelfLifePct being
+//     lost over shelf_life_days at the crop's ideal temperature
+//
+// Both rates follow a Q10 temperature coefficient. They are raised when the
+// air is drier or damper than the crop's humidity range, when the crop was
+// harvested late, and after hot or humid days in the field. Road roughness
+// adds bruising in proportion to how fragile the crop is.
+
+// spoilageQ10 is the factor by which decay speeds up per 10 °C above the
+// crop's ideal temperature, by crop category.
+var spoilageQ10 = map[string]float64{
+	"leafy_vegetable": 3.0,
+	"vegetable":       2.5,
+	"fruit":           2.5,
+	"root_tuber":      2.0,
+	"bulb":            2.0,
+	"grain":           2.0,
+	"oilseed":         2.0,
+	"cash_crop":       2.0,
+	"spice":           2.0,
+}
+
+// bruiseFragility scales road damage by category: soft produce bruises,
+// dry commodities barely notice.
+var bruiseFragility = map[string]float64{
+	"leafy_vegetable": 1.0,
+	"vegetable":       1.0,
+	"fruit":           1.0,
+	"root_tuber":      0.5,
+	"bulb":            0.4,
+	"cash_crop":       0.3,
+	"spice":           0.3,
+	"grain":           0.1,
+	"oilseed":         0.1,
+}
+
+// roadRoughness is the extra loss rate on each road class relative to a
+// mixed road, for the most fragile crops.
+var roadRoughness = map[string]float64{
+	"paved":   -0.2,
+	"mixed":   0,
+	"unpaved": 0.8,
+}
+
+// maturityDecay is the rate multiplier by harvest maturity.
+var maturityDecay = map[string]float64{
+	"Early":   0.9,
+	"Optimal": 1.0,
+	"Late":    2.0, // over-ripe produce decays twice as fast
+}
+
+const (
+	// transitLossPerIndexPct converts a crop's baseline_spoilage_rate into
+	// %/h in transit. The seed indices run from 0.4 (cotton) to 4.5
+	// (spinach), so a six-hour trip in the heat loses under 1% of a grain
+	// load and 3–11% of fresh produce, in line with field surveys.
+	transitLossPerIndexPct = 0.1
+	defaultQ10             = 2.0
+	// minTempFactor caps the benefit of cold: below this, chilling injury
+	// offsets slower respiration.
+	minTempFactor = 0.25
+	// maxTempFactor caps the heat penalty: baseline rates already describe
+	// open-vehicle handling, and cold-chain crops (ideal 2–8 °C) would
+	// otherwise compound Q10 over a 25 °C gap within a single trip.
+	maxTempFactor = 3.0
+	// coldChainFactor is a refrigerated vehicle's loss rate relative to an
+	// open one at the same (ideal) temperature: pre-cooled, shaded, no wind.
+	coldChainFactor = 0.25
+	// Loss rate increase per percentage point of humidity outside the
+	// crop's range: dry air wilts and shrinks, damp air rots.
+	dryAirPerPct  = 0.01
+	dampAirPerPct = 0.03
+	// storageLossOverShelfLifePct is how much of a load is lost over one
+	// ambient shelf life when held at the crop's ideal temperature.
+	storageLossOverShelfLifePct = 10.0
+	// Field exposure: every 24 humid hours or 100 °C·h of heat adds 25%
+	// to the loss rate, up to twice each.
+	fieldExposureStep = 0.25
+
+	// Risk label thresholds on expected loss (% of the load).
+	spoilageMediumPct = 3.0
+	spoilageHighPct   = 7.0
+)
+
+// SpoilageEstimate is the model's expected loss, as % of the load.
+type SpoilageEstimate struct {
+	TransitPct float64
+	StoragePct float64
+	TotalPct   float64
+	Risk       string // LOW, MEDIUM, HIGH
+}
+
+// estimateSpoilage runs the model for a crop under the given conditions.
+func estimateSpoilage(crop Crop, f SpoilageFactors) SpoilageEstimate {
+	common := maturityFactor(f.CropMaturity) * fieldExposureFactor(f.RecentHumidHours, f.RecentHeatDegreeHr)
+
+	var est SpoilageEstimate
+	if f.TransitTimeHours > 0 {
+		rate := crop.BaselineSpoilageRate * transitLossPerIndexPct * common * roadFactor(crop, f.RoadQuality)
+		if f.Refrigerated {
+			rate *= coldChainFactor // held at the crop's ideal temperature and humidity
+		} else {
+			rate *= temperatureFactor(crop, f.TemperatureCelsius) * humidityFactor(crop, f.HumidityPercent)
+		}
+		est.TransitPct = math.Min(rate*f.TransitTimeHours, 100)
+	}
+	if f.StorageDays > 0 && crop.ShelfLifeDays > 0 {
+		rate := storageLossOverShelfLifePct / crop.ShelfLifeDays * common * temperatureFactor(crop, f.StorageTempC)
+		est.StoragePct = math.Min(rate*f.StorageDays, 100)
+	}
+
+	// Storage losses apply to what survived the trip
+	est.TotalPct = 100 - (100-est.TransitPct)*(100-est.StoragePct)/100
+	est.Risk = spoilageRiskLabel(est.TotalPct)
+	return est
+}
+
+// spoilageRiskLabel buckets an expected loss for display.
+func spoilageRiskLabel(lossPct float64) string {
+	switch {
+	case lossPct >= spoilageHighPct:
+		return "HIGH"
+	case lossPct >= spoilageMediumPct:
+		return "MEDIUM"
+	default:
+		return "LOW"
+	}
+}
+
+// temperatureFactor is the Q10 rate multiplier at tempC relative to the
+// crop's ideal temperature, bounded by minTempFactor and maxTempFactor.
+func temperatureFactor(crop Crop, tempC float64) float64 {
+	q10, ok := spoilageQ10[crop.Category]
+	if !ok {
+		q10 = defaultQ10
+	}
+	return math.Min(math.Max(math.Pow(q10, (tempC-crop.IdealTemp)/10), minTempFactor), maxTempFactor)
+}
+
+// humidityFactor raises the rate when relative humidity is outside the
+// crop's range. Crops without a range are not adjusted.
+func humidityFactor(crop Crop, rh float64) float64 {
+	switch {
+	case crop.HumidityMaxPct <= 0:
+		return 1
+	case rh < crop.HumidityMinPct:
+		return 1 + (crop.HumidityMinPct-rh)*dryAirPerPct
+	case rh > crop.HumidityMaxPct:
+		return 1 + (rh-crop.HumidityMaxPct)*dampAirPerPct
+	}
+	return 1
+}
+
+// roadFactor is the bruising multiplier for a road class.
+func roadFactor(crop Crop, road string) float64 {
+	fragility, ok := bruiseFragility[crop.Category]
+	if !ok {
+		fragility = 1
+	}
+	return 1 + roadRoughness[road]*fragility
+}
+
+func maturityFactor(maturity string) float64 {
+	if m, ok := maturityDecay[maturity]; ok {
+		return m
+	}
+	return 1
+}
+
+// fieldExposureFactor shortens remaining shelf life after humid or hot
+// days in the field.
+func fieldExposureFactor(humidHours int, heatDegreeHours float64) float64 {
+	return 1 + fieldExposureStep*math.Min(float64(humidHours)/24, 2) +
+		fieldExposureStep*math.Min(heatDegreeHours/100, 2)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// referenceTrip is a six-hour open-vehicle run on a mixed road on a hot,
+// dry afternoon, the kind of trip most recommendations price.
+var referenceTrip = SpoilageFactors{
+	TemperatureCelsius: 32,
+	HumidityPercent:    60,
+	TransitTimeHours:   6,
+	RoadQuality:        "mixed",
+	CropMaturity:       "Optimal",
+}
+
+func TestSpoilageReferenceTripLoss(t *testing.T) {
+	freshProduce := map[string]bool{"vegetable": true, "leafy_vegetable": true, "root_tuber": true, "fruit": true}
+	for _, crop := range offlineCrops() {
+		t.Run(crop.Name, func(t *testing.T) {
+			// Transit losses are single-digit percentages: a few percent for
+			// fresh produce, about 1% or less for crops that keep for months
+			lo, hi := 0.0, 12.0
+			switch {
+			case freshProduce[crop.Category]:
+				lo = 2
+			case crop.ShelfLifeDays >= 180:
+				hi = 1.5
+			}
+			if got := estimateSpoilage(crop, referenceTrip).TotalPct; got <= lo || got > hi {
+				t.Errorf("open vehicle: loss %.2f%%, want in (%v, %v]", got, lo, hi)
+			}
+
+			reefer := referenceTrip
+			reefer.Refrigerated = true
+			if got := estimateSpoilage(crop, reefer); got.Risk != "LOW" {
+				t.Errorf("refrigerated: risk %s (%.2f%%), want LOW", got.Risk, got.TotalPct)
+			}
+		})
+	}
+}
+
+func TestSpoilageProperties(t *testing.T) {
+	for _, crop := range offlineCrops() {
+		t.Run(crop.Name, func(t *testing.T) {
+			loss := func(mod func(*SpoilageFactors)) float64 {
+				f := referenceTrip
+				mod(&f)
+				return estimateSpoilage(crop, f).TotalPct
+			}
+			base := loss(func(*SpoilageFactors) {})
+
+			if got := loss(func(f *SpoilageFactors) { f.TransitTimeHours = 0 }); got != 0 {
+				t.Errorf("no trip: loss %.2f%%, want 0", got)
+			}
+			if base <= 0 || base > 100 {
+				t.Errorf("reference trip: loss %.2f%% outside (0, 100]", base)
+			}
+			if got := loss(func(f *SpoilageFactors) { f.TransitTimeHours = 5000 }); got != 100 {
+				t.Errorf("5000 h trip: loss %.2f%%, want capped at 100", got)
+			}
+
+			// Monotone in time, heat and road roughness (up to the 100% cap)
+			shorter := loss(func(f *SpoilageFactors) { f.TransitTimeHours = 3 })
+			if shorter > base || (base < 100 && shorter >= base) {
+				t.Errorf("3 h trip lost %.2f%%, 6 h lost %.2f%%", shorter, base)
+			}
+			cool := loss(func(f *SpoilageFactors) { f.TemperatureCelsius = crop.IdealTemp })
+			if cool > base {
+				t.Errorf("at ideal temperature lost %.2f%%, at 32 °C %.2f%%", cool, base)
+			}
+			paved := loss(func(f *SpoilageFactors) { f.RoadQuality = "paved" })
+			unpaved := loss(func(f *SpoilageFactors) { f.RoadQuality = "unpaved" })
+			if !(paved <= base && base <= unpaved) || paved == unpaved {
+				t.Errorf("road: paved %.2f%%, mixed %.2f%%, unpaved %.2f%%", paved, base, unpaved)
+			}
+
+			reefer := loss(func(f *SpoilageFactors) { f.Refrigerated = true })
+			if reefer >= base {
+				t.Errorf("refrigerated lost %.2f%%, open %.2f%%", reefer, base)
+			}
+
+			// Humidity outside the crop's range costs more than inside it
+			atIdeal := func(rh float64) float64 {
+				return loss(func(f *SpoilageFactors) { f.TemperatureCelsius, f.HumidityPercent = crop.IdealTemp, rh })
+			}
+			inRange := atIdeal((crop.HumidityMinPct + crop.HumidityMaxPct) / 2)
+			if dry := atIdeal(crop.HumidityMinPct - 20); dry <= inRange {
+				t.Errorf("dry air lost %.2f%%, in range %.2f%%", dry, inRange)
+			}
+			if damp := atIdeal(math.Min(crop.HumidityMaxPct+5, 100)); crop.HumidityMaxPct < 100 && damp <= inRange {
+				t.Errorf("damp air lost %.2f%%, in range %.2f%%", damp, inRange)
+			}
+
+			// Over-ripe produce and a stressed field both shorten shelf life
+			late := func(m string) float64 {
+				return loss(func(f *SpoilageFactors) { f.CropMaturity, f.TransitTimeHours = m, 1 })
+			}
+			if late("Late") <= late("Optimal") || late("Optimal") <= late("Early") {
+				t.Errorf("maturity: early %.2f%%, optimal %.2f%%, late %.2f%%", late("Early"), late("Optimal"), late("Late"))
+			}
+			stressed := loss(func(f *SpoilageFactors) { f.TransitTimeHours, f.RecentHumidHours = 1, 36 })
+			if stressed <= late("Optimal") {
+				t.Errorf("after 36 humid field hours lost %.2f%%, otherwise %.2f%%", stressed, late("Optimal"))
+			}
+		})
+	}
+}
+
+func TestSpoilageStorage(t *testing.T) {
+	for _, crop := range offlineCrops() {
+		t.Run(crop.Name, func(t *testing.T) {
+			hold := func(days, tempC float64) SpoilageEstimate {
+				return estimateSpoilage(crop, SpoilageFactors{CropMaturity: "Optimal", StorageDays: days, StorageTempC: tempC})
+			}
+
+			// One shelf life at the ideal temperature loses the calibrated share
+			if got := hold(crop.ShelfLifeDays, crop.IdealTemp).StoragePct; math.Abs(got-storageLossOverShelfLifePct) > 1e-9 {
+				t.Errorf("one shelf life at ideal: loss %.4f%%, want %.0f%%", got, storageLossOverShelfLifePct)
+			}
+			if hold(7, crop.IdealTemp+10).StoragePct <= hold(7, crop.IdealTemp).StoragePct {
+				t.Error("a warmer chamber should lose more")
+			}
+			if hold(14, crop.IdealTemp).StoragePct < hold(7, crop.IdealTemp).StoragePct {
+				t.Error("a longer hold should not lose less")
+			}
+
+			// Trip and hold compound on what is left
+			f := referenceTrip
+			f.StorageDays, f.StorageTempC = 7, crop.IdealTemp
+			got := estimateSpoilage(crop, f)
+			want := 100 - (100-got.TransitPct)*(100-got.StoragePct)/100
+			if math.Abs(got.TotalPct-want) > 1e-9 || got.TotalPct < got.TransitPct || got.TotalPct > 100 {
+				t.Errorf("trip %.2f%% + hold %.2f%% gave total %.2f%%", got.TransitPct, got.StoragePct, got.TotalPct)
+			}
+		})
+	}
+}
+
+func TestSpoilageTomatoReference(t *testing.T) {
+	// 2.5 × 0.1 %/h × 6 h × 2.5^0.7 (7 °C over ideal) × 1.25 (25 points drier than 85% RH)
+	want := 2.5 * transitLossPerIndexPct * 6 * math.Pow(2.5, 0.7) * 1.25
+	got := estimateSpoilage(offlineCrops()["c3d4e5f6-a7b8-9012-cdef-123456789012"], referenceTrip)
+	if math.Abs(got.TransitPct-want) > 1e-9 || got.Risk != "MEDIUM" {
+		t.Errorf("tomato: %.4f%% %s, want %.4f%% MEDIUM", got.TransitPct, got.Risk, want)
+	}
+}
+
+func TestSpoilageRiskLabel(t *testing.T) {
+	for _, tc := range []struct {
+		pct  float64
+		want string
+	}{{0, "LOW"}, {2.99, "LOW"}, {3, "MEDIUM"}, {6.99, "MEDIUM"}, {7, "HIGH"}, {100, "HIGH"}} {
+		if got := spoilageRiskLabel(tc.pct); got != tc.want {
+			t.Errorf("spoilageRiskLabel(%v) = %s, want %s", tc.pct, got, tc.want)
+		}
+	}
+}
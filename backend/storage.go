@@ -0,0 +1,421 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+)
+
+// ══════════════════════════════════════════════
+//  COLD STORAGE BOOKING (Capacity & Reservations)
+// ══════════════════════════════════════════════
+
+var (
+	errStorageFacilityNotFound = errors.New("storage facility not found")
+	errStorageBookingNotFound  = errors.New("storage booking not found")
+	errStorageFull             = errors.New("storage facility does not have enough free capacity")
+	errBookingNotPending       = errors.New("booking is not pending")
+)
+
+const (
+	// storageHoldTTL is how long an unconfirmed reservation keeps its space.
+	storageHoldTTL = 24 * time.Hour
+	// maxStorageBookingDays caps the length of a single reservation.
+	maxStorageBookingDays = 180
+)
+
+// activeBookingClause matches bookings that currently occupy space: confirmed
+// ones, and pending holds that have not yet lapsed.
+const activeBookingClause = "(b.status = 'confirmed' OR (b.status = 'pending' AND b.expires_at > NOW()))"
+
+const storageBookingColumns = `b.id, b.facility_id, f.name AS facility_name, b.farmer_id, b.crop_id, b.quantity_kg,
+	to_char(b.start_date, 'YYYY-MM-DD') AS start_date, to_char(b.end_date, 'YYYY-MM-DD') AS end_date,
+	CASE WHEN b.status = 'pending' AND b.expires_at <= NOW() THEN 'expired' ELSE b.status END AS status,
+	b.expires_at, b.created_at, b.confirmed_at, b.cancelled_at`
+
+// peakBookedMT returns the most tonnes booked on any single day between
+// start and end (inclusive), ignoring the booking excludeID.
+func peakBookedMT(q sqlx.Queryer, facilityID string, start, end time.Time, excludeID string) (float64, error) {
+	var peak float64
+	err := sqlx.Get(q, &peak, `
+		SELECT COALESCE(MAX(booked), 0) FROM (
+			SELECT d, SUM(b.quantity_kg) / 1000.0 AS booked
+			FROM generate_series($2::date, $3::date, INTERVAL '1 day') d
+			JOIN storage_bookings b ON b.facility_id = $1
+				AND d BETWEEN b.start_date AND b.end_date
+				AND `+activeBookingClause+`
+				AND b.id::text <> $4
+			GROUP BY d
+		) daily`, facilityID, start, end, excludeID)
+	return peak, err
+}
+
+// reserveStorage holds space at a facility. The facility row is locked for
+// the duration of the capacity check so concurrent reservations cannot
+// overbook it.
+func reserveStorage(facilityID, farmerID string, cropID *string, quantityKg float64, start, end time.Time) (StorageBooking, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return StorageBooking{}, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	var capacityMT float64
+	err = tx.Get(&capacityMT, "SELECT capacity_mt FROM storage_facilities WHERE id = $1 FOR UPDATE", facilityID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return StorageBooking{}, errStorageFacilityNotFound
+	}
+	if err != nil {
+		return StorageBooking{}, fmt.Errorf("lock facility: %w", err)
+	}
+	peak, err := peakBookedMT(tx, facilityID, start, end, "")
+	if err != nil {
+		return StorageBooking{}, fmt.Errorf("booked capacity: %w", err)
+	}
+	if peak+quantityKg/1000 > capacityMT {
+		return StorageBooking{}, errStorageFull
+	}
+
+	var id string
+	err = tx.Get(&id, `
+		INSERT INTO storage_bookings (facility_id, farmer_id, crop_id, quantity_kg, start_date, end_date, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		facilityID, farmerID, cropID, quantityKg, start, end, time.Now().Add(storageHoldTTL))
+	if err != nil {
+		return StorageBooking{}, fmt.Errorf("insert booking: %w", err)
+	}
+	b, err := getStorageBooking(tx, id)
+	if err != nil {
+		return StorageBooking{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return StorageBooking{}, fmt.Errorf("commit: %w", err)
+	}
+	return b, nil
+}
+
+// confirmStorageBooking turns a pending hold into a firm booking. A hold
+// that has lapsed is re-checked against capacity before it is confirmed.
+func confirmStorageBooking(id string) (StorageBooking, error) {
+	b, err := getStorageBooking(db, id)
+	if err != nil {
+		return StorageBooking{}, err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return StorageBooking{}, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock order matches reserveStorage: facility first, then the booking.
+	var capacityMT float64
+	if err := tx.Get(&capacityMT, "SELECT capacity_mt FROM storage_facilities WHERE id = $1 FOR UPDATE", b.FacilityID); err != nil {
+		return StorageBooking{}, fmt.Errorf("lock facility: %w", err)
+	}
+	var status string
+	var expiresAt time.Time
+	row := tx.QueryRowx("SELECT status, expires_at FROM storage_bookings WHERE id = $1 FOR UPDATE", id)
+	if err := row.Scan(&status, &expiresAt); err != nil {
+		return StorageBooking{}, fmt.Errorf("lock booking: %w", err)
+	}
+	if status != "pending" {
+		return StorageBooking{}, errBookingNotPending
+	}
+	if !expiresAt.After(time.Now()) {
+		start, _ := time.Parse("2006-01-02", b.StartDate)
+		end, _ := time.Parse("2006-01-02", b.EndDate)
+		peak, err := peakBookedMT(tx, b.FacilityID, start, end, id)
+		if err != nil {
+			return StorageBooking{}, fmt.Errorf("booked capacity: %w", err)
+		}
+		if peak+b.QuantityKg/1000 > capacityMT {
+			return StorageBooking{}, errStorageFull
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE storage_bookings SET status = 'confirmed', confirmed_at = NOW() WHERE id = $1", id); err != nil {
+		return StorageBooking{}, fmt.Errorf("confirm booking: %w", err)
+	}
+	if b, err = getStorageBooking(tx, id); err != nil {
+		return StorageBooking{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return StorageBooking{}, fmt.Errorf("commit: %w", err)
+	}
+	return b, nil
+}
+
+// cancelStorageBooking releases a pending or confirmed booking.
+func cancelStorageBooking(id string) (StorageBooking, error) {
+	res, err := db.Exec(`
+		UPDATE storage_bookings SET status = 'cancelled', cancelled_at = NOW()
+		WHERE id = $1 AND status <> 'cancelled'`, id)
+	if err != nil {
+		return StorageBooking{}, fmt.Errorf("cancel booking: %w", err)
+	}
+	b, err := getStorageBooking(db, id)
+	if err != nil {
+		return StorageBooking{}, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return b, errBookingNotPending
+	}
+	return b, nil
+}
+
+func getStorageBooking(q sqlx.Queryer, id string) (StorageBooking, error) {
+	if !isValidUUID(id) {
+		return StorageBooking{}, errStorageBookingNotFound
+	}
+	var b StorageBooking
+	err := sqlx.Get(q, &b, "SELECT "+storageBookingColumns+`
+		FROM storage_bookings b JOIN storage_facilities f ON f.id = b.facility_id
+		WHERE b.id = $1`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return StorageBooking{}, errStorageBookingNotFound
+	}
+	return b, err
+}
+
+// istZone is India Standard Time; booking dates are calendar days in India.
+var istZone = time.FixedZone("IST", 5*3600+30*60)
+
+// todayIST is the current calendar date in India, at UTC midnight so it
+// compares cleanly with dates parsed from YYYY-MM-DD.
+func todayIST() time.Time {
+	y, m, d := time.Now().In(istZone).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// parseBookingDates validates a reservation window: it may not start in the
+// past, end before it starts or run longer than maxStorageBookingDays.
+func parseBookingDates(startStr, endStr string) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01-02", startStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("start_date must be YYYY-MM-DD")
+	}
+	end, err := time.Parse("2006-01-02", endStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("end_date must be YYYY-MM-DD")
+	}
+	if start.Before(todayIST()) {
+		return time.Time{}, time.Time{}, fmt.Errorf("start_date must not be in the past")
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end_date must not be before start_date")
+	}
+	if end.Sub(start) >= maxStorageBookingDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("a booking may cover at most %d days", maxStorageBookingDays)
+	}
+	return start, end, nil
+}
+
+func respondStorageBookingError(c *gin.Context, id string, err error) {
+	switch {
+	case errors.Is(err, errStorageBookingNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("storage booking %s not found", id)})
+	case errors.Is(err, errStorageFull):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, errBookingNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("storage booking %s cannot be changed from its current status", id)})
+	default:
+		log.Printf("⚠ Storage booking %s failed: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update storage booking"})
+	}
+}
+
+// ── Handlers ────────────────────────────────
+
+// handleStorageAvailability reports booked and free tonnage per day.
+func handleStorageAvailability(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	id := c.Param("id")
+	today := todayIST().Format("2006-01-02")
+	start, end, err := parseBookingDates(c.DefaultQuery("from", today), c.DefaultQuery("to", today))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var f StorageFacility
+	err = errStorageFacilityNotFound
+	if isValidUUID(id) {
+		err = db.Get(&f, "SELECT "+storageFacilityColumns+" FROM storage_facilities WHERE id = $1", id)
+		if errors.Is(err, sql.ErrNoRows) {
+			err = errStorageFacilityNotFound
+		}
+	}
+	if errors.Is(err, errStorageFacilityNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("storage facility %s not found", id)})
+		return
+	}
+	if err != nil {
+		log.Printf("⚠ DB fetch storage facility failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load storage facility"})
+		return
+	}
+
+	days := []StorageAvailabilityDay{}
+	err = db.Select(&days, `
+		SELECT to_char(d, 'YYYY-MM-DD') AS date,
+		       COALESCE(SUM(b.quantity_kg), 0) / 1000.0 AS booked_mt
+		FROM generate_series($2::date, $3::date, INTERVAL '1 day') d
+		LEFT JOIN storage_bookings b ON b.facility_id = $1
+			AND d BETWEEN b.start_date AND b.end_date
+			AND `+activeBookingClause+`
+		GROUP BY d
+		ORDER BY d`, id, start, end)
+	if err != nil {
+		log.Printf("Error loading availability for storage %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load storage availability"})
+		return
+	}
+	for i := range days {
+		days[i].CapacityMT = f.CapacityMT
+		days[i].AvailableMT = math.Max(0, f.CapacityMT-days[i].BookedMT)
+	}
+	c.JSON(http.StatusOK, gin.H{"facility": f, "days": days})
+}
+
+// handleCreateStorageBooking reserves space. The hold lasts storageHoldTTL
+// unless it is confirmed.
+func handleCreateStorageBooking(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	var in StorageBookingInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid payload format"})
+		return
+	}
+	if in.Unit == "" {
+		in.Unit = "quintal"
+	}
+	quantityKg, err := quantityToKg(in.Quantity, in.Unit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	start, end, err := parseBookingDates(in.StartDate, in.EndDate)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := fetchFarmer(in.FarmerID); err != nil {
+		respondFarmerError(c, in.FarmerID, err)
+		return
+	}
+	if in.CropID != nil {
+		if _, err := fetchCrop(*in.CropID); err != nil {
+			respondCropError(c, *in.CropID, err)
+			return
+		}
+	}
+	if !isValidUUID(in.FacilityID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("storage facility %s not found", in.FacilityID)})
+		return
+	}
+
+	b, err := reserveStorage(in.FacilityID, in.FarmerID, in.CropID, quantityKg, start, end)
+	switch {
+	case errors.Is(err, errStorageFacilityNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("storage facility %s not found", in.FacilityID)})
+		return
+	case errors.Is(err, errStorageFull):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("Error reserving storage: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reserve storage"})
+		return
+	}
+
+	log.Printf("🧊 Reserved %.0f kg at %s for farmer %s (%s to %s)", b.QuantityKg, b.FacilityName, b.FarmerID, b.StartDate, b.EndDate)
+	c.JSON(http.StatusCreated, b)
+}
+
+func handleGetStorageBooking(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	id := c.Param("id")
+	b, err := getStorageBooking(db, id)
+	if err != nil {
+		respondStorageBookingError(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+func handleConfirmStorageBooking(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	id := c.Param("id")
+	b, err := confirmStorageBooking(id)
+	if err != nil {
+		respondStorageBookingError(c, id, err)
+		return
+	}
+	log.Printf("✅ Storage booking %s confirmed at %s", id, b.FacilityName)
+	c.JSON(http.StatusOK, b)
+}
+
+func handleCancelStorageBooking(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	id := c.Param("id")
+	b, err := cancelStorageBooking(id)
+	if err != nil {
+		respondStorageBookingError(c, id, err)
+		return
+	}
+	c.JSON(http.StatusOK, b)
+}
+
+// handleListFarmerStorageBookings returns a farmer's reservations, newest first.
+func handleListFarmerStorageBookings(c *gin.Context) {
+	if !requireDB(c) {
+		return
+	}
+	farmerID := c.Param("id")
+	if _, err := fetchFarmer(farmerID); err != nil {
+		respondFarmerError(c, farmerID, err)
+		return
+	}
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page := StorageBookingPage{Items: []StorageBooking{}, Limit: limit, Offset: offset}
+	if err := db.Get(&page.Total, "SELECT COUNT(*) FROM storage_bookings WHERE farmer_id = $1", farmerID); err != nil {
+		log.Printf("Error counting storage bookings for %s: %v", farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list storage bookings"})
+		return
+	}
+	err = db.Select(&page.Items, "SELECT "+storageBookingColumns+`
+		FROM storage_bookings b JOIN storage_facilities f ON f.id = b.facility_id
+		WHERE b.farmer_id = $1
+		ORDER BY b.created_at DESC
+		LIMIT $2 OFFSET $3`, farmerID, limit, offset)
+	if err != nil {
+		log.Printf("Error listing storage bookings for %s: %v", farmerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list storage bookings"})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// ══════════════════════════════════════════════
+//  STORE-VS-SELL OPTIMISER (Holding Cost vs Price Recovery)
+// ══════════════════════════════════════════════
+
+const (
+	// coldShelfLifeFactor is how much longer a crop keeps in cold storage
+	// than at ambient conditions; it caps the holding period.
+	coldShelfLifeFactor = 2.0
+	// storeMinGainPct is how much better than selling today storing must be
+	// (as a share of today's return) to be worth the risk of a wrong forecast.
+	storeMinGainPct = 2.0
+)
+
+// storageHorizonDays is how far ahead the optimiser looks for a sale: the
+// longest price forecast, capped by how long the crop keeps in cold storage.
+// It does not depend on how many forecast days the caller displays.
+func storageHorizonDays(crop Crop) int {
+	days := maxForecastDays
+	if crop.ShelfLifeDays > 0 {
+		days = min(days, int(math.Floor(crop.ShelfLifeDays*coldShelfLifeFactor)))
+	}
+	return max(days, 1)
+}
+
+// optimiseStorage compares selling at the best market today with storing
+// the load at each nearby facility for every holding period the market's
+// price forecast covers. A stored load loses weight each day (the storage
+// phase of the spoilage model under factors, see spoilage.go), pays the
+// daily storage charge and the trip to the facility, and is sold at the
+// forecast price with the same price adjustments (glut, crowd reports) as
+// today's quote. candidates come from rankStorage with a window starting
+// today; facilities whose temperature range cannot hold the crop are
+// skipped. The returned option is the chosen facility when the plan is "store".
+func optimiseStorage(farmer Farmer, crop Crop, factors SpoilageFactors, best MarketOption, forecast []ForecastPoint, candidates []storageCandidate, quantityKg float64) (StoragePlan, *StorageOption) {
+	plan := StoragePlan{Decision: "sell_now", SellNowReturn: best.NetReturn}
+
+	maxDays := min(len(forecast), storageHorizonDays(crop))
+	if maxDays < 1 || quantityKg <= 0 {
+		plan.Reason = "No price forecast is available to weigh storage against selling today."
+		return plan, nil
+	}
+
+	// Realised share of the quoted price today, and the share of the load
+	// lost on the road to market; both carry over to a later sale.
+	priceRatio, transitLoss := 1.0, best.ExpectedSpoilageKg/quantityKg
+	if gross := (quantityKg - best.ExpectedSpoilageKg) / 100 * best.CurrentPrice; gross > 0 {
+		priceRatio = best.TotalRevenue / gross
+	}
+
+	start := todayIST()
+	profiles := fetchVehicleProfiles(farmer.LocationLat, farmer.LocationLon)
+
+	var chosen *StorageOption
+	bestGain := math.Inf(-1)
+	for _, cand := range candidates {
+		if _, fit := storageTempFit(crop.IdealTemp, cand.Option.MinTempC, cand.Option.MaxTempC); fit == 0 {
+			continue
+		}
+		// The chamber runs as close to the crop's ideal as its range allows
+		hold := factors
+		hold.TransitTimeHours, hold.StorageTempC = 0, crop.IdealTemp
+		if cand.Option.MinTempC != nil && cand.Option.MaxTempC != nil {
+			hold.StorageTempC = math.Min(math.Max(crop.IdealTemp, *cand.Option.MinTempC), *cand.Option.MaxTempC)
+		}
+		handling := cartageCost(profiles, quantityKg, cand.Option)
+		if math.IsInf(handling, 1) {
+			continue
+		}
+		for d := 1; d <= maxDays; d++ {
+			free := cand.freeFor(d)
+			if free < quantityKg/1000 {
+				break // bookings only pile up as the window grows
+			}
+			fp := forecast[d-1]
+			hold.StorageDays = float64(d)
+			shrinkKg := quantityKg * estimateSpoilage(crop, hold).StoragePct / 100
+			sellKg := (quantityKg - shrinkKg) * (1 - transitLoss)
+			storageCost := quantityKg * cand.Option.PricePerKg * float64(d)
+			costs := best.TotalTransportCost + storageCost + handling
+
+			storeReturn := sellKg/100*fp.Price*priceRatio - costs
+			gain := storeReturn - best.NetReturn
+			if gain <= bestGain {
+				continue
+			}
+			bestGain = gain
+			opt := cand.Option
+			opt.AvailableMT = math.Round(free*10) / 10
+			opt.HoldFrom = start.Format("2006-01-02")
+			opt.HoldUntil = start.AddDate(0, 0, d-1).Format("2006-01-02")
+			chosen = &opt
+			plan.FacilityID, plan.FacilityName = opt.ID, opt.Name
+			plan.HoldDays = d
+			plan.SellDate = fp.Date
+			plan.ExpectedPrice = fp.Price
+			plan.ShrinkageKg = round2(shrinkKg)
+			plan.StorageCost = round2(storageCost)
+			plan.HandlingCost = round2(handling)
+			plan.StoreReturn = round2(storeReturn)
+			plan.ExpectedGain = round2(gain)
+			plan.WorstCaseGain = round2(sellKg/100*fp.Lower*priceRatio - costs - best.NetReturn)
+		}
+	}
+
+	switch {
+	case chosen == nil:
+		plan.Reason = fmt.Sprintf("No nearby cold storage has room for your %.0f kg, so plan to sell directly.", quantityKg)
+		return plan, nil
+	case bestGain <= math.Abs(best.NetReturn)*storeMinGainPct/100:
+		outcome := fmt.Sprintf("leaves you ₹%.0f less than selling today", -plan.ExpectedGain)
+		if plan.ExpectedGain >= 0 {
+			outcome = fmt.Sprintf("adds only ₹%.0f", plan.ExpectedGain)
+		}
+		plan.Reason = fmt.Sprintf(
+			"Storing at %s does not pay: even the best plan (%d days, selling near ₹%.0f/quintal) %s after ₹%.0f storage and %.0f kg shrinkage, so sell now.",
+			plan.FacilityName, plan.HoldDays, plan.ExpectedPrice, outcome, plan.StorageCost, plan.ShrinkageKg)
+		return plan, nil
+	}
+
+	plan.Decision = "store"
+	plan.Reason = fmt.Sprintf(
+		"Store at %s for %d days and sell around %s near ₹%.0f/quintal: about ₹%.0f more than selling today, after ₹%.0f storage, ₹%.0f cartage and %.0f kg shrinkage.",
+		plan.FacilityName, plan.HoldDays, plan.SellDate, plan.ExpectedPrice, plan.ExpectedGain, plan.StorageCost, plan.HandlingCost, plan.ShrinkageKg)
+	return plan, chosen
+}
+
+// cartageCost is the cheapest trip from the farm to a storage facility.
+// It is +Inf when no vehicle can make the trip.
+func cartageCost(profiles []VehicleProfile, quantityKg float64, s StorageOption) float64 {
+	leg := TransitEstimate{Hours: s.DistanceKm / haversineSpeedKmh, DistanceKm: s.DistanceKm, Source: "haversine"}
+	cheapest := math.Inf(1)
+	for _, p := range profiles {
+		if p.MaxRangeKm > 0 && leg.DistanceKm > p.MaxRangeKm {
+			continue
+		}
+		quote, _ := quoteTransport(p, quantityKg, leg)
+		cheapest = math.Min(cheapest, quote.Total)
+	}
+	return cheapest
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestStorageHorizonDays(t *testing.T) {
+	tests := []struct {
+		shelfLife float64
+		want      int
+	}{
+		{0, maxForecastDays},   // unknown shelf life: the longest forecast
+		{365, maxForecastDays}, // keeps for a year: the forecast is the limit
+		{7, 14},                // twice the ambient shelf life in cold storage
+		{0.4, 1},               // never less than a day
+	}
+	for _, tc := range tests {
+		if got := storageHorizonDays(Crop{ShelfLifeDays: tc.shelfLife}); got != tc.want {
+			t.Errorf("shelf life %v days: horizon %d, want %d", tc.shelfLife, got, tc.want)
+		}
+	}
+}
+
+func TestOptimiseStorage(t *testing.T) {
+	wheat := offlineCrops()["d2e3f4a5-6789-3456-8901-234567890123"]
+	const loadKg = 1000.0
+
+	// Selling 10 quintals today at ₹2000 after ₹500 transport
+	best := MarketOption{
+		MarketName: "Test Mandi", CurrentPrice: 2000,
+		TotalRevenue: 20000, TotalTransportCost: 500, NetReturn: 19500,
+	}
+	path := func(days int, perDay float64) []ForecastPoint {
+		points := make([]ForecastPoint, days)
+		for d := range points {
+			price := 2000 + perDay*float64(d+1)
+			points[d] = ForecastPoint{Day: d + 1, Date: todayIST().AddDate(0, 0, d+1).Format("2006-01-02"),
+				Price: price, Lower: price - 100, Upper: price + 100}
+		}
+		return points
+	}
+	facility := func(pricePerKg, capacityMT float64, bookedMT ...float64) storageCandidate {
+		return storageCandidate{
+			Option:   StorageOption{ID: "f1", Name: "Test Cold Store", DistanceKm: 5, PricePerKg: pricePerKg, CapacityMT: capacityMT},
+			BookedMT: bookedMT,
+		}
+	}
+	factors := SpoilageFactors{CropMaturity: "Optimal"}
+
+	tests := []struct {
+		name       string
+		forecast   []ForecastPoint
+		candidates []storageCandidate
+		decision   string
+		holdDays   int // 0: any
+	}{
+		{"no forecast", nil, []storageCandidate{facility(0.01, 100)}, "sell_now", 0},
+		{"no facility", path(30, 50), nil, "sell_now", 0},
+		{"flat prices", path(30, 0), []storageCandidate{facility(0.01, 100)}, "sell_now", 0},
+		// +₹5/quintal a day is ₹0.5/day for the load; storage costs ₹50/day
+		{"storage costs exceed the gain", path(30, 5), []storageCandidate{facility(0.05, 100)}, "sell_now", 0},
+		// +₹50/quintal a day against ₹10/day storage: hold to the end of the forecast
+		{"rising prices, cheap storage", path(30, 50), []storageCandidate{facility(0.01, 100)}, "store", 30},
+		// Full from day 6: only five days can be held
+		{"capacity runs out", path(30, 50), []storageCandidate{facility(0.01, 100, 0, 0, 0, 0, 0, 100)}, "store", 5},
+		{"no room at all", path(30, 50), []storageCandidate{facility(0.01, 0.5)}, "sell_now", 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			plan, opt := optimiseStorage(Farmer{}, wheat, factors, best, tc.forecast, tc.candidates, loadKg)
+			if plan.Decision != tc.decision {
+				t.Fatalf("decision %s, want %s (%s)", plan.Decision, tc.decision, plan.Reason)
+			}
+			if (opt != nil) != (tc.decision == "store") {
+				t.Errorf("facility returned: %v", opt != nil)
+			}
+			if tc.holdDays > 0 && plan.HoldDays != tc.holdDays {
+				t.Errorf("hold %d days, want %d", plan.HoldDays, tc.holdDays)
+			}
+			if plan.Decision == "store" && plan.ExpectedGain <= 0 {
+				t.Errorf("storing with expected gain ₹%.2f", plan.ExpectedGain)
+			}
+			if plan.Reason == "" {
+				t.Error("no reason given")
+			}
+		})
+	}
+}
+
+func TestOptimiseStorageShelfLifeCap(t *testing.T) {
+	tomato := offlineCrops()["c3d4e5f6-a7b8-9012-cdef-123456789012"]
+	best := MarketOption{CurrentPrice: 2000, TotalRevenue: 20000, TotalTransportCost: 500, NetReturn: 19500}
+	forecast := make([]ForecastPoint, maxForecastDays)
+	for d := range forecast {
+		price := 2000 + 200*float64(d+1) // steep enough that the last day always wins
+		forecast[d] = ForecastPoint{Day: d + 1, Date: todayIST().AddDate(0, 0, d+1).Format("2006-01-02"), Price: price, Lower: price}
+	}
+	cand := storageCandidate{Option: StorageOption{ID: "f1", Name: "Test Cold Store", DistanceKm: 5, PricePerKg: 0.01, CapacityMT: 100}}
+
+	plan, _ := optimiseStorage(Farmer{}, tomato, SpoilageFactors{CropMaturity: "Optimal"}, best, forecast, []storageCandidate{cand}, 1000)
+	if plan.HoldDays != storageHorizonDays(tomato) {
+		t.Errorf("tomato held %d days, want the %d-day cold shelf life", plan.HoldDays, storageHorizonDays(tomato))
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// ══════════════════════════════════════════════
+//  STORAGE SEARCH (PostGIS Nearby + Ranking)
+// ══════════════════════════════════════════════
+
+const storageFacilityColumns = `id, name, ST_Y(location::geometry) AS location_lat, ST_X(location::geometry) AS location_lon,
+	capacity_mt, price_per_kg, min_temp_c, max_temp_c, created_at`
+
+const (
+	defaultStorageRadiusKm = 150.0
+	maxStorageRadiusKm     = 500.0
+	defaultStorageLimit    = 5
+	maxStorageLimit        = 20
+	// maxNearbyFacilities bounds the spatial query before ranking.
+	maxNearbyFacilities = 50
+	// storageTempToleranceC is how far a crop's ideal temperature may sit
+	// outside a facility's range before the facility scores zero for it.
+	storageTempToleranceC = 5.0
+)
+
+// Ranking weights; they sum to 1 so scores stay between 0 and 1.
+const (
+	storageWeightDistance = 0.35
+	storageWeightPrice    = 0.25
+	storageWeightCapacity = 0.20
+	storageWeightTemp     = 0.20
+)
+
+// defaultStorageFacilities mirror the seed rows in schema.sql and are used
+// when the database is unavailable.
+var defaultStorageFacilities = []StorageFacility{
+	{ID: "f6a7b8c9-d0e1-2345-abcd-456789012345", Name: "Narela Cold Storage", LocationLat: 28.8526, LocationLon: 77.0932, CapacityMT: 500, PricePerKg: 2.0, MinTempC: floatPtr(0), MaxTempC: floatPtr(4)},
+	{ID: "a7b8c9d0-e1f2-3456-bcde-567890123456", Name: "Sonipat AgriStore", LocationLat: 28.9931, LocationLon: 77.0151, CapacityMT: 300, PricePerKg: 1.5, MinTempC: floatPtr(2), MaxTempC: floatPtr(15)},
+	{ID: "b8c9d0e1-f2a3-4567-cdef-678901234567", Name: "Gurgaon FreshVault", LocationLat: 28.4595, LocationLon: 77.0266, CapacityMT: 750, PricePerKg: 2.5, MinTempC: floatPtr(0), MaxTempC: floatPtr(12)},
+}
+
+func floatPtr(v float64) *float64 { return &v }
+
+// storageCandidate is a ranked facility with its booked tonnage for each
+// day of a window, so free space can be read off for any holding period.
+type storageCandidate struct {
+	Option   StorageOption
+	BookedMT []float64 // index 0 is the window's first day
+}
+
+// freeFor returns the tonnes free on every one of the first days of the window.
+func (sc storageCandidate) freeFor(days int) float64 {
+	peak := 0.0
+	for i := 0; i < days && i < len(sc.BookedMT); i++ {
+		peak = math.Max(peak, sc.BookedMT[i])
+	}
+	return sc.Option.CapacityMT - peak
+}
+
+// nearbyFacility is a facility and its distance from the search point.
+type nearbyFacility struct {
+	StorageFacility
+	DistanceKm float64 `db:"distance_km"`
+}
+
+// fetchNearbyFacilities returns facilities within radiusKm, closest first,
+// using the GIST index on storage_facilities.location.
+func fetchNearbyFacilities(lat, lon, radiusKm float64) ([]nearbyFacility, error) {
+	if db == nil {
+		var out []nearbyFacility
+		for _, f := range defaultStorageFacilities {
+			if d := haversine(lat, lon, f.LocationLat, f.LocationLon); d <= radiusKm {
+				out = append(out, nearbyFacility{StorageFacility: f, DistanceKm: d})
+			}
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].DistanceKm < out[j].DistanceKm })
+		return out, nil
+	}
+
+	var out []nearbyFacility
+	err := db.Select(&out, "SELECT "+storageFacilityColumns+`,
+			ST_Distance(location, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography) / 1000.0 AS distance_km
+		FROM storage_facilities
+		WHERE ST_DWithin(location, ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography, $3)
+		ORDER BY location <-> ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography
+		LIMIT $4`, lat, lon, radiusKm*1000, maxNearbyFacilities)
+	return out, err
+}
+
+// fetchBookedMT returns tonnes booked per facility for each day of the window.
+func fetchBookedMT(ids []string, start time.Time, days int) (map[string][]float64, error) {
+	booked := make(map[string][]float64, len(ids))
+	for _, id := range ids {
+		booked[id] = make([]float64, days)
+	}
+	if db == nil || len(ids) == 0 {
+		return booked, nil
+	}
+
+	var rows []struct {
+		FacilityID string  `db:"facility_id"`
+		DayIdx     int     `db:"day_idx"`
+		BookedMT   float64 `db:"booked_mt"`
+	}
+	err := db.Select(&rows, `
+		SELECT b.facility_id, (d::date - $1::date) AS day_idx, SUM(b.quantity_kg) / 1000.0 AS booked_mt
+		FROM generate_series($1::date, $2::date, INTERVAL '1 day') d
+		JOIN storage_bookings b ON d BETWEEN b.start_date AND b.end_date
+			AND b.facility_id = ANY($3)
+			AND `+activeBookingClause+`
+		GROUP BY b.facility_id, d`, start, start.AddDate(0, 0, days-1), pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		if series, ok := booked[r.FacilityID]; ok && r.DayIdx >= 0 && r.DayIdx < days {
+			series[r.DayIdx] = r.BookedMT
+		}
+	}
+	return booked, nil
+}
+
+// storageTempFit scores how well a facility's temperature range suits a
+// crop's ideal storage temperature.
+func storageTempFit(idealTemp float64, minC, maxC *float64) (string, float64) {
+	if minC == nil || maxC == nil {
+		return "unknown", 0.5
+	}
+	var fit string
+	var gap float64
+	switch {
+	case idealTemp < *minC:
+		fit, gap = "too_warm", *minC-idealTemp
+	case idealTemp > *maxC:
+		fit, gap = "too_cold", idealTemp-*maxC // chilling injury risk
+	default:
+		return "ok", 1
+	}
+	return fit, math.Max(0, 1-gap/storageTempToleranceC)
+}
+
+// rankStorage scores facilities near a point on distance, price per kg,
+// free capacity over the window and temperature fit for the crop, and
+// returns the best limit of them. crop may be nil, in which case every
+// facility gets a neutral temperature score; quantityKg may be 0, in which
+// case capacity is scored on the share of the facility still free.
+func rankStorage(lat, lon float64, crop *Crop, quantityKg float64, start time.Time, days, limit int, radiusKm float64) ([]storageCandidate, error) {
+	if days < 1 {
+		days = 1
+	}
+	facilities, err := fetchNearbyFacilities(lat, lon, radiusKm)
+	if err != nil {
+		return nil, fmt.Errorf("nearby facilities: %w", err)
+	}
+	if len(facilities) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, len(facilities))
+	cheapest := math.MaxFloat64
+	for i, f := range facilities {
+		ids[i] = f.ID
+		if f.PricePerKg > 0 {
+			cheapest = math.Min(cheapest, f.PricePerKg)
+		}
+	}
+	booked, err := fetchBookedMT(ids, start, days)
+	if err != nil {
+		return nil, fmt.Errorf("storage bookings: %w", err)
+	}
+
+	needMT := quantityKg / 1000
+	candidates := make([]storageCandidate, 0, len(facilities))
+	for _, f := range facilities {
+		c := storageCandidate{
+			Option: StorageOption{
+				ID:         f.ID,
+				Name:       f.Name,
+				DistanceKm: math.Round(f.DistanceKm*10) / 10,
+				PricePerKg: f.PricePerKg,
+				CapacityMT: f.CapacityMT,
+				MinTempC:   f.MinTempC,
+				MaxTempC:   f.MaxTempC,
+			},
+			BookedMT: booked[f.ID],
+		}
+		free := math.Max(0, c.freeFor(days))
+		c.Option.AvailableMT = math.Round(free*10) / 10
+		c.Option.FitsLoad = free >= needMT
+
+		distScore := math.Max(0, 1-f.DistanceKm/radiusKm)
+		priceScore := 1.0
+		if f.PricePerKg > 0 {
+			priceScore = cheapest / f.PricePerKg
+		}
+		capScore := 0.0
+		if needMT > 0 {
+			capScore = math.Min(1, free/needMT)
+		} else if f.CapacityMT > 0 {
+			capScore = free / f.CapacityMT
+		}
+		tempScore := 0.5
+		c.Option.TempFit = "unknown"
+		if crop != nil {
+			c.Option.TempFit, tempScore = storageTempFit(crop.IdealTemp, f.MinTempC, f.MaxTempC)
+		}
+
+		score := storageWeightDistance*distScore + storageWeightPrice*priceScore +
+			storageWeightCapacity*capScore + storageWeightTemp*tempScore
+		c.Option.Score = math.Round(score*1000) / 1000
+		candidates = append(candidates, c)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Option.Score > candidates[j].Option.Score })
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
+
+// ── Handlers ────────────────────────────────
+
+// handleNearbyStorage lists ranked cold storage around a point.
+func handleNearbyStorage(c *gin.Context) {
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lon, errLon := strconv.ParseFloat(c.Query("lon"), 64)
+	if errLat != nil || errLon != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat and lon are required (lat within ±90, lon within ±180)"})
+		return
+	}
+	radiusKm, err := strconv.ParseFloat(c.DefaultQuery("radius_km", strconv.FormatFloat(defaultStorageRadiusKm, 'f', -1, 64)), 64)
+	if err != nil || radiusKm <= 0 || radiusKm > maxStorageRadiusKm {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("radius_km must be between 0 and %.0f", maxStorageRadiusKm)})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultStorageLimit)))
+	if err != nil || limit < 1 || limit > maxStorageLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be an integer between 1 and %d", maxStorageLimit)})
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "1"))
+	if err != nil || days < 1 || days > maxStorageBookingDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("days must be an integer between 1 and %d", maxStorageBookingDays)})
+		return
+	}
+	start := todayIST()
+	if s := c.Query("from"); s != "" {
+		if start, _, err = parseBookingDates(s, s); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a YYYY-MM-DD date that is not in the past"})
+			return
+		}
+	}
+
+	quantityKg := 0.0
+	if q := c.Query("quantity"); q != "" {
+		qty, err := strconv.ParseFloat(q, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "quantity must be a number"})
+			return
+		}
+		if quantityKg, err = quantityToKg(qty, c.DefaultQuery("unit", "quintal")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var crop *Crop
+	if cropID := c.Query("crop_id"); cropID != "" {
+		cr, err := fetchCrop(cropID)
+		if err != nil {
+			respondCropError(c, cropID, err)
+			return
+		}
+		crop = &cr
+	}
+
+	candidates, err := rankStorage(lat, lon, crop, quantityKg, start, days, limit, radiusKm)
+	if err != nil {
+		log.Printf("Error ranking storage near %.4f,%.4f: %v", lat, lon, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search storage facilities"})
+		return
+	}
+	options := make([]StorageOption, len(candidates))
+	for i, cand := range candidates {
+		options[i] = cand.Option
+	}
+	c.JSON(http.StatusOK, options)
+}
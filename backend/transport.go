@@ -0,0 +1,123 @@
+package main
+
+import (
+	"log"
+	"math"
+)
+
+// ══════════════════════════════════════════════
+//  TRANSPORT COST MODEL (Vehicle Profiles)
+// ══════════════════════════════════════════════
+
+// defaultLoadKg is the consignment costed when the farmer has not said how
+// much they are bringing (one tonne, 10 quintals).
+const defaultLoadKg = 1000.0
+
+// VehicleProfile holds hire rates for one vehicle type in one region.
+// Region "" is the national default; state rows override it.
+type VehicleProfile struct {
+	Region            string  `json:"-" db:"region"`
+	Vehicle           string  `json:"vehicle" db:"vehicle"`
+	CapacityKg        float64 `json:"capacity_kg" db:"capacity_kg"`
+	SpeedFactor       float64 `json:"-" db:"speed_factor"` // multiplies routed car-speed durations
+	KmPerLitre        float64 `json:"-" db:"km_per_litre"`
+	DieselPerLitre    float64 `json:"-" db:"diesel_per_litre"`
+	PerKmRate         float64 `json:"-" db:"per_km_rate"`   // wear, driver allowance (excl. fuel)
+	PerHourRate       float64 `json:"-" db:"per_hour_rate"` // hire charge while on the road
+	LoadingPerQuintal float64 `json:"-" db:"loading_per_quintal"`
+	TollPerKm         float64 `json:"-" db:"toll_per_km"`
+	ReturnFactor      float64 `json:"-" db:"return_factor"` // share of the outbound leg billed for the empty return
+	Refrigerated      bool    `json:"refrigerated" db:"refrigerated"`
+	MaxRangeKm        float64 `json:"-" db:"max_range_km"` // 0 = unlimited
+}
+
+// defaultVehicleProfiles mirror the seed rows in schema.sql and are used
+// when the database is unavailable.
+var defaultVehicleProfiles = []VehicleProfile{
+	{Vehicle: "tractor_trolley", CapacityKg: 3000, SpeedFactor: 1.6, KmPerLitre: 6, DieselPerLitre: 90, PerKmRate: 4, PerHourRate: 120, LoadingPerQuintal: 15, TollPerKm: 0, ReturnFactor: 1.0, MaxRangeKm: 60},
+	{Vehicle: "mini_truck", CapacityKg: 1500, SpeedFactor: 1.1, KmPerLitre: 12, DieselPerLitre: 90, PerKmRate: 6, PerHourRate: 100, LoadingPerQuintal: 12, TollPerKm: 1.2, ReturnFactor: 0.5},
+	{Vehicle: "reefer_truck", CapacityKg: 5000, SpeedFactor: 1.1, KmPerLitre: 5, DieselPerLitre: 90, PerKmRate: 14, PerHourRate: 250, LoadingPerQuintal: 18, TollPerKm: 2.5, ReturnFactor: 0.5, Refrigerated: true},
+}
+
+// fetchVehicleProfiles returns the profiles for the farmer's region: the
+// state of the nearest verified mandi, falling back to national defaults.
+func fetchVehicleProfiles(lat, lon float64) []VehicleProfile {
+	if db == nil {
+		return defaultVehicleProfiles
+	}
+	var profiles []VehicleProfile
+	err := db.Select(&profiles, `
+		WITH region AS (
+			SELECT state FROM mandis
+			WHERE location_resolved AND state <> ''
+			ORDER BY location <-> ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography
+			LIMIT 1
+		)
+		SELECT DISTINCT ON (vehicle) region, vehicle, capacity_kg, speed_factor, km_per_litre, diesel_per_litre,
+		       per_km_rate, per_hour_rate, loading_per_quintal, toll_per_km, return_factor, refrigerated, max_range_km
+		FROM transport_rates
+		WHERE region = '' OR region = (SELECT state FROM region)
+		ORDER BY vehicle, region DESC`, lat, lon)
+	if err != nil || len(profiles) == 0 {
+		log.Printf("⚠ DB fetch transport rates failed: %v – using defaults", err)
+		return defaultVehicleProfiles
+	}
+	return profiles
+}
+
+// TransportBreakdown itemises the cost of moving the whole load to a market.
+type TransportBreakdown struct {
+	Vehicle        string  `json:"vehicle"`
+	Refrigerated   bool    `json:"refrigerated"`
+	LoadKg         float64 `json:"load_kg"`
+	Trips          int     `json:"trips"`
+	DistanceKm     float64 `json:"distance_km"`
+	Fuel           float64 `json:"fuel"`
+	DistanceCharge float64 `json:"distance_charge"`
+	TimeCharge     float64 `json:"time_charge"`
+	Tolls          float64 `json:"tolls"`
+	ReturnTrip     float64 `json:"return_trip"`
+	Loading        float64 `json:"loading"`
+	Total          float64 `json:"total"`
+	PerQuintal     float64 `json:"per_quintal"`
+}
+
+// quoteTransport costs a load on one vehicle over a routed leg. Transit
+// hours are the vehicle's, not the car-speed route's.
+func quoteTransport(p VehicleProfile, loadKg float64, leg TransitEstimate) (TransportBreakdown, float64) {
+	hours := leg.Hours * p.SpeedFactor
+	trips := int(math.Ceil(loadKg / p.CapacityKg))
+	if trips < 1 {
+		trips = 1
+	}
+
+	fuel := leg.DistanceKm / p.KmPerLitre * p.DieselPerLitre
+	distanceCharge := leg.DistanceKm * p.PerKmRate
+	timeCharge := hours * p.PerHourRate
+	tolls := leg.DistanceKm * p.TollPerKm
+	returnTrip := (fuel + distanceCharge + timeCharge + tolls) * p.ReturnFactor
+	loading := loadKg / 100 * p.LoadingPerQuintal
+
+	n := float64(trips)
+	b := TransportBreakdown{
+		Vehicle:        p.Vehicle,
+		Refrigerated:   p.Refrigerated,
+		LoadKg:         loadKg,
+		Trips:          trips,
+		DistanceKm:     round2(leg.DistanceKm),
+		Fuel:           round2(fuel * n),
+		DistanceCharge: round2(distanceCharge * n),
+		TimeCharge:     round2(timeCharge * n),
+		Tolls:          round2(tolls * n),
+		ReturnTrip:     round2(returnTrip * n),
+		Loading:        round2(loading),
+	}
+	total := (fuel+distanceCharge+timeCharge+tolls+returnTrip)*n + loading
+	b.Total = round2(total)
+	b.PerQuintal = round2(total / (loadKg / 100))
+	return b, hours
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
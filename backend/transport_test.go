@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuoteTransportTrips(t *testing.T) {
+	// Round numbers: ₹10/l at 10 km/l, ₹2/km, ₹100/h, ₹1/km tolls,
+	// a half-price return and ₹10/quintal loading on a 1000 kg truck.
+	truck := VehicleProfile{
+		Vehicle: "test_truck", CapacityKg: 1000, SpeedFactor: 1.5,
+		KmPerLitre: 10, DieselPerLitre: 10, PerKmRate: 2, PerHourRate: 100,
+		LoadingPerQuintal: 10, TollPerKm: 1, ReturnFactor: 0.5,
+	}
+	leg := TransitEstimate{Hours: 2, DistanceKm: 100, Source: "routed"}
+	// One trip: fuel 100 + distance 200 + time 300 (3 h) + tolls 100 = 700, return 350
+	const perTrip = 1050.0
+
+	tests := []struct {
+		name   string
+		loadKg float64
+		trips  int
+	}{
+		{"part load", 400, 1},
+		{"exactly full", 1000, 1},
+		{"just over capacity", 1001, 2},
+		{"two and a half loads", 2500, 3},
+		{"ten loads", 10000, 10},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b, hours := quoteTransport(truck, tc.loadKg, leg)
+			if b.Trips != tc.trips {
+				t.Fatalf("trips = %d, want %d", b.Trips, tc.trips)
+			}
+			if hours != 3 {
+				t.Errorf("hours = %v, want 3 (2 h route × 1.5)", hours)
+			}
+
+			n := float64(tc.trips)
+			loading := tc.loadKg / 100 * 10
+			want := TransportBreakdown{
+				Vehicle: "test_truck", LoadKg: tc.loadKg, Trips: tc.trips, DistanceKm: 100,
+				Fuel: 100 * n, DistanceCharge: 200 * n, TimeCharge: 300 * n, Tolls: 100 * n,
+				ReturnTrip: 350 * n, Loading: loading,
+				Total:      round2(perTrip*n + loading),
+				PerQuintal: round2((perTrip*n + loading) / (tc.loadKg / 100)),
+			}
+			if b != want {
+				t.Errorf("got  %+v\nwant %+v", b, want)
+			}
+
+			// Itemised lines add up to the total
+			sum := b.Fuel + b.DistanceCharge + b.TimeCharge + b.Tolls + b.ReturnTrip + b.Loading
+			if math.Abs(sum-b.Total) > 0.01 {
+				t.Errorf("items sum to %.2f, total %.2f", sum, b.Total)
+			}
+		})
+	}
+}
+
+func TestQuoteTransportVehicles(t *testing.T) {
+	leg := TransitEstimate{Hours: 1, DistanceKm: 50, Source: "routed"}
+	for _, p := range defaultVehicleProfiles {
+		t.Run(p.Vehicle, func(t *testing.T) {
+			b, _ := quoteTransport(p, 4500, leg)
+			if want := int(math.Ceil(4500 / p.CapacityKg)); b.Trips != want {
+				t.Errorf("trips = %d, want %d", b.Trips, want)
+			}
+			if b.Refrigerated != p.Refrigerated {
+				t.Errorf("refrigerated = %v, want %v", b.Refrigerated, p.Refrigerated)
+			}
+			if b.Total <= 0 || b.PerQuintal <= 0 {
+				t.Errorf("total %.2f, per quintal %.2f", b.Total, b.PerQuintal)
+			}
+		})
+	}
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ══════════════════════════════════════════════
+//  GRIDDED WEATHER CACHE (Open-Meteo → weather_cache)
+// ══════════════════════════════════════════════
+
+const (
+	weatherCellPrecision  = 5                // geohash length: ~4.9 km × 4.9 km cells
+	weatherFreshFor       = 3 * time.Hour    // older readings are not served
+	weatherRefreshAfter   = 45 * time.Minute // skip cells fetched this recently
+	weatherMaxCellKm      = 25.0             // farther cached cells are not representative
+	weatherMaxCellsPerRun = 400              // keeps hourly runs inside Open-Meteo's free daily quota
+	weatherRequestGap     = 150 * time.Millisecond
+	weatherForecastDays   = 7
+	weatherRetentionDays  = 30
+)
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// encodeGeohash returns the standard base-32 geohash of a point.
+func encodeGeohash(lat, lon float64, precision int) string {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+	var sb strings.Builder
+	bit, ch, even := 0, 0, true
+	for sb.Len() < precision {
+		rng, v := &latRange, lat
+		if even {
+			rng, v = &lonRange, lon
+		}
+		mid := (rng[0] + rng[1]) / 2
+		if v >= mid {
+			ch |= 1 << (4 - bit)
+			rng[0] = mid
+		} else {
+			rng[1] = mid
+		}
+		even = !even
+		if bit < 4 {
+			bit++
+		} else {
+			sb.WriteByte(geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return sb.String()
+}
+
+// geohashCenter returns the centre point of a geohash cell.
+func geohashCenter(hash string) (float64, float64) {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+	even := true
+	for _, c := range hash {
+		idx := strings.IndexRune(geohashAlphabet, c)
+		for b := 4; b >= 0; b-- {
+			rng := &latRange
+			if even {
+				rng = &lonRange
+			}
+			mid := (rng[0] + rng[1]) / 2
+			if idx&(1<<b) != 0 {
+				rng[0] = mid
+			} else {
+				rng[1] = mid
+			}
+			even = !even
+		}
+	}
+	return (latRange[0] + latRange[1]) / 2, (lonRange[0] + lonRange[1]) / 2
+}
+
+// weatherCells returns the grid cells covering every registered farmer,
+// farm plot and mandi with a verified location, stalest first.
+func weatherCells(db *sqlx.DB) ([]string, error) {
+	var points []struct {
+		Lat float64 `db:"lat"`
+		Lon float64 `db:"lon"`
+	}
+	err := db.Select(&points, `
+		SELECT location_lat AS lat, location_lon AS lon FROM farmers
+		UNION
+		SELECT ST_Y(location::geometry), ST_X(location::geometry) FROM farm_plots
+		UNION
+		SELECT ST_Y(location::geometry), ST_X(location::geometry) FROM mandis WHERE location_resolved`)
+	if err != nil {
+		return nil, fmt.Errorf("load grid points: %w", err)
+	}
+
+	var fetched []struct {
+		Geohash string    `db:"geohash"`
+		Last    time.Time `db:"last"`
+	}
+	err = db.Select(&fetched, `
+		SELECT geohash, MAX(recorded_at) AS last
+		FROM weather_cache
+		WHERE recorded_at > NOW() - INTERVAL '1 day'
+		GROUP BY geohash`)
+	if err != nil {
+		return nil, fmt.Errorf("load cell freshness: %w", err)
+	}
+	lastFetched := make(map[string]time.Time, len(fetched))
+	for _, f := range fetched {
+		lastFetched[f.Geohash] = f.Last
+	}
+
+	seen := map[string]bool{}
+	var cells []string
+	for _, p := range points {
+		cell := encodeGeohash(p.Lat, p.Lon, weatherCellPrecision)
+		if seen[cell] || time.Since(lastFetched[cell]) < weatherRefreshAfter {
+			continue
+		}
+		seen[cell] = true
+		cells = append(cells, cell)
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		return lastFetched[cells[i]].Before(lastFetched[cells[j]])
+	})
+	return cells, nil
+}
+
+func ingestWeatherGrid(db *sqlx.DB) {
+	log.Println("[worker] Fetching Open-Meteo weather updates...")
+	cells, err := weatherCells(db)
+	if err != nil {
+		log.Printf("[worker] %v", err)
+		return
+	}
+	if len(cells) > weatherMaxCellsPerRun {
+		log.Printf("[worker] %d weather cells due; fetching the %d stalest this run", len(cells), weatherMaxCellsPerRun)
+		cells = cells[:weatherMaxCellsPerRun]
+	}
+
+	stored := 0
+	for i, cell := range cells {
+		if i > 0 {
+			time.Sleep(weatherRequestGap)
+		}
+		lat, lon := geohashCenter(cell)
+		r, err := fetchCurrentWeather(lat, lon)
+		if err != nil {
+			log.Printf("[worker] Weather fetch for cell %s failed: %v", cell, err)
+			continue
+		}
+		_, err = db.Exec(`
+			INSERT INTO weather_cache
+				(geohash, location, temp, humidity, weather_code, precipitation_mm, wind_kmh, dew_point, recorded_at)
+			VALUES ($1, ST_SetSRID(ST_MakePoint($2, $3), 4326)::geography, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (geohash, recorded_at) DO NOTHING`,
+			cell, lon, lat, r.Temp, r.Humidity, r.WeatherCode, r.PrecipMm, r.WindKmh, r.DewPointC, r.ObservedAt,
+		)
+		if err != nil {
+			log.Printf("[worker] Weather ingestion for cell %s failed: %v", cell, err)
+			continue
+		}
+		if err := storeDailyForecast(db, cell, lat, lon, r.Daily); err != nil {
+			log.Printf("[worker] Forecast ingestion for cell %s failed: %v", cell, err)
+		}
+		stored++
+	}
+	log.Printf("[worker] Stored weather for %d/%d cells.", stored, len(cells))
+	backfillPlotHistory(db)
+	pruneWeatherHistory(db)
+}
+
+// weatherReading is one Open-Meteo observation for a grid cell plus its
+// daily forecast for the coming week.
+type weatherReading struct {
+	Temp        float64
+	Humidity    float64
+	WeatherCode int
+	PrecipMm    float64
+	WindKmh     float64
+	DewPointC   float64
+	ObservedAt  time.Time
+	Daily       []DailyForecast
+}
+
+// fetchCurrentWeather returns the current conditions and a 7-day daily
+// forecast at a point. Unlike the request path it never substitutes mock
+// data – the cache must stay honest.
+func fetchCurrentWeather(lat, lon float64) (weatherReading, error) {
+	url := fmt.Sprintf(
+		"https://api.open-meteo.com/v1/forecast?latitude=%.4f&longitude=%.4f"+
+			"&current=temperature_2m,relative_humidity_2m,weather_code,precipitation,wind_speed_10m,dew_point_2m"+
+			"&daily=precipitation_probability_max,precipitation_sum,temperature_2m_max,temperature_2m_min,wind_speed_10m_max,et0_fao_evapotranspiration"+
+			"&forecast_days=%d&timezone=auto",
+		lat, lon, weatherForecastDays,
+	)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return weatherReading{}, fmt.Errorf("open-meteo request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return weatherReading{}, fmt.Errorf("open-meteo returned status %d", resp.StatusCode)
+	}
+
+	// Times and daily buckets are local to the point (timezone=auto).
+	var result struct {
+		UTCOffsetSeconds int `json:"utc_offset_seconds"`
+		Current          struct {
+			Time        string  `json:"time"`
+			Temperature float64 `json:"temperature_2m"`
+			Humidity    float64 `json:"relative_humidity_2m"`
+			WeatherCode int     `json:"weather_code"`
+			Precip      float64 `json:"precipitation"`
+			Wind        float64 `json:"wind_speed_10m"`
+			DewPoint    float64 `json:"dew_point_2m"`
+		} `json:"current"`
+		Daily struct {
+			Time       []string    `json:"time"`
+			PrecipProb []apiNumber `json:"precipitation_probability_max"`
+			PrecipSum  []apiNumber `json:"precipitation_sum"`
+			TempMax    []apiNumber `json:"temperature_2m_max"`
+			TempMin    []apiNumber `json:"temperature_2m_min"`
+			WindMax    []apiNumber `json:"wind_speed_10m_max"`
+			ET0        []apiNumber `json:"et0_fao_evapotranspiration"`
+		} `json:"daily"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return weatherReading{}, fmt.Errorf("failed to parse open-meteo JSON: %w", err)
+	}
+	loc := time.FixedZone("local", result.UTCOffsetSeconds)
+	observed, err := time.ParseInLocation("2006-01-02T15:04", result.Current.Time, loc)
+	if err != nil {
+		return weatherReading{}, fmt.Errorf("unexpected observation time %q", result.Current.Time)
+	}
+
+	d := result.Daily
+	at := func(series []apiNumber, i int) float64 {
+		if i < len(series) {
+			return float64(series[i])
+		}
+		return 0
+	}
+	daily := make([]DailyForecast, 0, len(d.Time))
+	for i, date := range d.Time {
+		daily = append(daily, DailyForecast{
+			Date:          date,
+			PrecipProbPct: int(math.Round(at(d.PrecipProb, i))),
+			PrecipMm:      at(d.PrecipSum, i),
+			TempMaxC:      at(d.TempMax, i),
+			TempMinC:      at(d.TempMin, i),
+			WindMaxKmh:    at(d.WindMax, i),
+			ET0Mm:         at(d.ET0, i),
+		})
+	}
+
+	return weatherReading{
+		Temp:        result.Current.Temperature,
+		Humidity:    result.Current.Humidity,
+		WeatherCode: result.Current.WeatherCode,
+		PrecipMm:    result.Current.Precip,
+		WindKmh:     result.Current.Wind,
+		DewPointC:   result.Current.DewPoint,
+		ObservedAt:  observed.UTC(),
+		Daily:       daily,
+	}, nil
+}
+
+// storeDailyForecast replaces a cell's forecast with the latest run.
+func storeDailyForecast(db *sqlx.DB, cell string, lat, lon float64, daily []DailyForecast) error {
+	for _, f := range daily {
+		_, err := db.Exec(`
+			INSERT INTO weather_forecast_daily
+				(geohash, forecast_date, location, precip_prob_pct, precip_mm, temp_max, temp_min, wind_max_kmh, et0_mm, fetched_at)
+			VALUES ($1, $2, ST_SetSRID(ST_MakePoint($3, $4), 4326)::geography, $5, $6, $7, $8, $9, $10, NOW())
+			ON CONFLICT (geohash, forecast_date) DO UPDATE SET
+				precip_prob_pct = EXCLUDED.precip_prob_pct, precip_mm = EXCLUDED.precip_mm,
+				temp_max = EXCLUDED.temp_max, temp_min = EXCLUDED.temp_min,
+				wind_max_kmh = EXCLUDED.wind_max_kmh, et0_mm = EXCLUDED.et0_mm, fetched_at = NOW()`,
+			cell, f.Date, lon, lat, f.PrecipProbPct, f.PrecipMm, f.TempMaxC, f.TempMinC, f.WindMaxKmh, f.ET0Mm)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchDailyForecast loads a cell's forecast from today (IST) onwards.
+func fetchDailyForecast(cell string) []DailyForecast {
+	var daily []DailyForecast
+	err := db.Select(&daily, `
+		SELECT to_char(forecast_date, 'YYYY-MM-DD') AS date, precip_prob_pct, precip_mm,
+		       temp_max, temp_min, wind_max_kmh, et0_mm
+		FROM weather_forecast_daily
+		WHERE geohash = $1 AND forecast_date >= (NOW() AT TIME ZONE 'Asia/Kolkata')::date
+		ORDER BY forecast_date
+		LIMIT $2`, cell, weatherForecastDays)
+	if err != nil {
+		log.Printf("⚠ DB fetch daily forecast for cell %s failed: %v", cell, err)
+		return nil
+	}
+	return daily
+}
+
+// ── Recent Exposure ─────────────────────────
+
+const (
+	exposureWindowHours = 72
+	humidHourRH         = 85.0 // RH at which surface moisture and mould take hold
+)
+
+// fetchWeatherExposure summarises the cell's hourly readings over the last
+// three days. Readings are roughly hourly, so counts approximate hours.
+func fetchWeatherExposure(cell string, idealTemp float64) *WeatherExposure {
+	var e WeatherExposure
+	err := db.Get(&e, `
+		SELECT COUNT(*) AS readings,
+		       COALESCE(AVG(temp), 0) AS mean_temp,
+		       COALESCE(MAX(temp), 0) AS max_temp,
+		       COALESCE(AVG(humidity), 0) AS mean_humidity,
+		       COUNT(*) FILTER (WHERE humidity >= $3) AS humid_hours,
+		       COALESCE(SUM(precipitation_mm), 0) AS rain_mm,
+		       COALESCE(SUM(GREATEST(temp - $4, 0)), 0) AS heat_degree_hours
+		FROM weather_cache
+		WHERE geohash = $1 AND recorded_at > NOW() - make_interval(hours => $2)`,
+		cell, exposureWindowHours, humidHourRH, idealTemp)
+	if err != nil {
+		log.Printf("⚠ DB fetch weather exposure for cell %s failed: %v", cell, err)
+		return nil
+	}
+	if e.Readings == 0 {
+		return nil
+	}
+	e.WindowHours = exposureWindowHours
+	e.MeanTempC = math.Round(e.MeanTempC*10) / 10
+	e.MeanHumidity = math.Round(e.MeanHumidity*10) / 10
+	e.RainMm = math.Round(e.RainMm*10) / 10
+	e.HeatDegreeHours = math.Round(e.HeatDegreeHours*10) / 10
+	return &e
+}
+
+// pruneWeatherHistory drops readings past the retention window. Daily
+// temperatures are kept for the longest season the growth model tracks.
+func pruneWeatherHistory(db *sqlx.DB) {
+	for _, q := range []string{
+		`DELETE FROM weather_forecast_daily WHERE forecast_date < CURRENT_DATE - $1::int`,
+		`DELETE FROM weather_daily_history WHERE obs_date < CURRENT_DATE - $1::int`,
+	} {
+		if _, err := db.Exec(q, maxSeasonDays); err != nil {
+			log.Printf("[worker] Daily weather pruning failed: %v", err)
+		}
+	}
+
+	res, err := db.Exec(`DELETE FROM weather_cache WHERE recorded_at < NOW() - make_interval(days => $1)`, weatherRetentionDays)
+	if err != nil {
+		log.Printf("[worker] Weather history pruning failed: %v", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("[worker] Pruned %d weather readings older than %d days.", n, weatherRetentionDays)
+	}
+}
+
+// ── Harvest Window ──────────────────────────
+
+const (
+	harvestMaxRainProbPct = 30   // above this a day is not considered dry
+	harvestMaxPrecipMm    = 2.0  // forecast rain total tolerated on a harvest day
+	harvestMaxWindKmh     = 40.0 // gusty days bruise produce
+	harvestDelayPenaltyC  = 1.0  // each day of waiting weighs like 1°C off ideal
+)
+
+// isDryDay reports whether a forecast day is suitable for harvesting.
+func isDryDay(f DailyForecast) bool {
+	return f.PrecipProbPct <= harvestMaxRainProbPct && f.PrecipMm <= harvestMaxPrecipMm && f.WindMaxKmh <= harvestMaxWindKmh
+}
+
+// pickHarvestDay scans the forecast from day `earliest` for the best dry day:
+// mean temperature closest to the crop's ideal, lowest rain chance, soonest.
+func pickHarvestDay(forecast []DailyForecast, idealTemp float64, earliest int) (int, bool) {
+	best, bestScore := -1, math.MaxFloat64
+	for i := earliest; i < len(forecast); i++ {
+		f := forecast[i]
+		if !isDryDay(f) {
+			continue
+		}
+		meanTemp := (f.TempMaxC + f.TempMinC) / 2
+		score := math.Abs(meanTemp-idealTemp) + float64(f.PrecipProbPct)/10 + float64(i-earliest)*harvestDelayPenaltyC
+		if score < bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best, best >= 0
+}
+
+// harvestDayLabel renders a forecast day as a harvest window string.
+func harvestDayLabel(day int, f DailyForecast) string {
+	date := f.Date
+	if t, err := time.Parse("2006-01-02", f.Date); err == nil {
+		date = t.Format("Mon 2 Jan")
+	}
+	switch day {
+	case 0:
+		return "Harvest Today"
+	case 1:
+		return "Harvest Tomorrow (" + date + ")"
+	default:
+		return "Harvest on " + date
+	}
+}
+
+// rainProbabilityTomorrow prefers the forecast's precipitation probability
+// and only estimates from the current condition when no forecast exists.
+func rainProbabilityTomorrow(weather WeatherInfo) int {
+	if len(weather.Forecast) > 1 {
+		return weather.Forecast[1].PrecipProbPct
+	}
+	switch weather.Condition {
+	case "Rain", "Rain Showers", "Thunderstorm":
+		return 80
+	case "Drizzle":
+		return 50
+	case "Partly Cloudy":
+		return 20
+	}
+	return 0
+}
+
+// fetchWeatherFromDB serves the nearest fresh grid cell and reports its age.
+// Without one it asks Open-Meteo directly, then falls back to demo values.
+func fetchWeatherFromDB(lat, lon, idealTemp float64) WeatherInfo {
+	if db != nil {
+		var w struct {
+			Geohash     string    `db:"geohash"`
+			Temp        float64   `db:"temp"`
+			Humidity    float64   `db:"humidity"`
+			WeatherCode *int      `db:"weather_code"` // NULL on rows cached before codes were stored
+			PrecipMm    float64   `db:"precipitation_mm"`
+			WindKmh     float64   `db:"wind_kmh"`
+			DewPointC   float64   `db:"dew_point"`
+			RecordedAt  time.Time `db:"recorded_at"`
+			DistanceM   float64   `db:"distance_m"`
+		}
+		err := db.Get(&w, `
+			SELECT geohash, temp, humidity, weather_code, COALESCE(precipitation_mm, 0) AS precipitation_mm,
+			       COALESCE(wind_kmh, 0) AS wind_kmh, COALESCE(dew_point, 0) AS dew_point, recorded_at,
+			       ST_Distance(location, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography) AS distance_m
+			FROM weather_cache
+			WHERE recorded_at > NOW() - make_interval(secs => $3)
+			  AND ST_DWithin(location, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, $4)
+			ORDER BY location <-> ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, recorded_at DESC
+			LIMIT 1`, lon, lat, weatherFreshFor.Seconds(), weatherMaxCellKm*1000)
+		if err == nil {
+			condition := "Unknown"
+			if w.WeatherCode != nil {
+				condition = weatherCodeToCondition(*w.WeatherCode)
+			}
+			return WeatherInfo{
+				CurrentTemp:    w.Temp,
+				Humidity:       w.Humidity,
+				TempDelta:      w.Temp - idealTemp,
+				Condition:      condition,
+				PrecipMm:       w.PrecipMm,
+				WindKmh:        w.WindKmh,
+				DewPointC:      w.DewPointC,
+				Recent:         fetchWeatherExposure(w.Geohash, idealTemp),
+				Source:         "cache",
+				Cell:           w.Geohash,
+				CellDistanceKm: math.Round(w.DistanceM/100) / 10,
+				ObservedAt:     w.RecordedAt,
+				AgeMinutes:     int(time.Since(w.RecordedAt).Minutes()),
+				Forecast:       fetchDailyForecast(w.Geohash),
+			}
+		}
+		log.Printf("⚠ No fresh weather cell within %.0f km of (%.4f, %.4f): %v", weatherMaxCellKm, lat, lon, err)
+	}
+
+	if r, err := fetchCurrentWeather(lat, lon); err == nil {
+		return WeatherInfo{
+			CurrentTemp: r.Temp,
+			Humidity:    r.Humidity,
+			TempDelta:   r.Temp - idealTemp,
+			Condition:   weatherCodeToCondition(r.WeatherCode),
+			PrecipMm:    r.PrecipMm,
+			WindKmh:     r.WindKmh,
+			DewPointC:   r.DewPointC,
+			Source:      "live",
+			ObservedAt:  r.ObservedAt,
+			AgeMinutes:  int(time.Since(r.ObservedAt).Minutes()),
+			Forecast:    r.Daily,
+		}
+	}
+
+	// Mock fallback
+	return WeatherInfo{
+		CurrentTemp: 32.4,
+		Humidity:    68.0,
+		TempDelta:   32.4 - idealTemp,
+		Condition:   "Partly Cloudy",
+		Source:      "fallback",
+	}
+}